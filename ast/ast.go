@@ -91,6 +91,11 @@ func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
 type StringLiteral struct {
 	Token token.Token
 	Value string
+	// Raw and Multiline record how the literal was written: r"..." keeps
+	// backslashes as they are, and """...""" spans lines with its
+	// indentation stripped. Value is the same either way.
+	Raw       bool
+	Multiline bool
 }
 
 func (sl *StringLiteral) expressionNode()      {}
@@ -3,9 +3,12 @@ package ast
 import (
 	"encoding/json"
 	"fmt"
+
+	"karl/token"
 )
 
-// FormatJSON returns a pretty-printed JSON view of the AST.
+// FormatJSON returns a pretty-printed JSON view of the AST, with the source
+// position of each node; FromJSON reads it back.
 func FormatJSON(node Node) (string, error) {
 	value := toJSON(node)
 	data, err := json.MarshalIndent(value, "", "  ")
@@ -16,10 +19,31 @@ func FormatJSON(node Node) (string, error) {
 }
 
 func toJSON(node Node) interface{} {
-	if node == nil {
+	if isNil(node) {
 		return nil
 	}
+	value := nodeToJSON(node)
+	if tok := NodeToken(node); tok != nil {
+		addPosition(value, *tok)
+	}
+	return value
+}
 
+// addPosition records where tok starts, for nodes, match arms and entries
+// parsed from source.
+func addPosition(value map[string]interface{}, tok token.Token) map[string]interface{} {
+	if tok.Line > 0 {
+		value["pos"] = map[string]interface{}{
+			"line":   tok.Line,
+			"column": tok.Column,
+			"offset": tok.Offset,
+		}
+	}
+	return value
+}
+
+// nodeToJSON returns the fields of one node; toJSON adds its position.
+func nodeToJSON(node Node) map[string]interface{} {
 	switch n := node.(type) {
 	case *Program:
 		return map[string]interface{}{
@@ -47,20 +71,35 @@ func toJSON(node Node) interface{} {
 			"type": "Placeholder",
 		}
 	case *IntegerLiteral:
-		return map[string]interface{}{
+		out := map[string]interface{}{
 			"type":  "IntegerLiteral",
 			"value": n.Value,
 		}
+		if lit := writtenInteger(n); lit != "" {
+			out["literal"] = lit
+		}
+		return out
 	case *FloatLiteral:
-		return map[string]interface{}{
+		out := map[string]interface{}{
 			"type":  "FloatLiteral",
 			"value": n.Value,
 		}
+		if lit := writtenFloat(n); lit != "" {
+			out["literal"] = lit
+		}
+		return out
 	case *StringLiteral:
-		return map[string]interface{}{
+		out := map[string]interface{}{
 			"type":  "StringLiteral",
 			"value": n.Value,
 		}
+		if n.Raw {
+			out["raw"] = true
+		}
+		if n.Multiline {
+			out["multiline"] = true
+		}
+		return out
 	case *CharLiteral:
 		return map[string]interface{}{
 			"type":  "CharLiteral",
@@ -130,11 +169,11 @@ func toJSON(node Node) interface{} {
 	case *MatchExpression:
 		arms := make([]interface{}, 0, len(n.Arms))
 		for _, arm := range n.Arms {
-			arms = append(arms, map[string]interface{}{
+			arms = append(arms, addPosition(map[string]interface{}{
 				"pattern": toJSON(arm.Pattern),
 				"guard":   toJSON(arm.Guard),
 				"body":    toJSON(arm.Body),
-			})
+			}, arm.Token))
 		}
 		return map[string]interface{}{
 			"type":  "MatchExpression",
@@ -201,12 +240,12 @@ func toJSON(node Node) interface{} {
 	case *ObjectLiteral:
 		entries := make([]interface{}, 0, len(n.Entries))
 		for _, entry := range n.Entries {
-			entries = append(entries, map[string]interface{}{
+			entries = append(entries, addPosition(map[string]interface{}{
 				"key":       entry.Key,
 				"value":     toJSON(entry.Value),
 				"shorthand": entry.Shorthand,
 				"spread":    entry.Spread,
-			})
+			}, entry.Token))
 		}
 		return map[string]interface{}{
 			"type":    "ObjectLiteral",
@@ -267,10 +306,10 @@ func toJSON(node Node) interface{} {
 	case *ObjectPattern:
 		entries := make([]interface{}, 0, len(n.Entries))
 		for _, entry := range n.Entries {
-			entries = append(entries, map[string]interface{}{
+			entries = append(entries, addPosition(map[string]interface{}{
 				"key":     entry.Key,
 				"pattern": toJSON(entry.Pattern),
-			})
+			}, entry.Token))
 		}
 		return map[string]interface{}{
 			"type":    "ObjectPattern",
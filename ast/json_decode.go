@@ -0,0 +1,487 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"karl/token"
+)
+
+// FromJSON decodes the output of FormatJSON back into a node, usually a
+// *Program. Positions ("pos") are optional, so generated ASTs may leave them
+// out; optional children may be null or missing.
+func FromJSON(data []byte) (Node, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("ast: unexpected data after the JSON value")
+	}
+	return decodeNode(value, "$")
+}
+
+// jsonObject is one decoded JSON object with the path to it, for errors.
+type jsonObject struct {
+	fields map[string]interface{}
+	path   string
+}
+
+func (o jsonObject) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("ast: %s: %s", o.path, fmt.Sprintf(format, args...))
+}
+
+func (o jsonObject) fieldPath(key string) string {
+	return o.path + "." + key
+}
+
+func (o jsonObject) str(key string) (string, error) {
+	s, ok := o.fields[key].(string)
+	if !ok {
+		return "", o.errorf("%q must be a string", key)
+	}
+	return s, nil
+}
+
+func (o jsonObject) boolean(key string) (bool, error) {
+	switch v := o.fields[key].(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return v, nil
+	}
+	return false, o.errorf("%q must be a boolean", key)
+}
+
+func (o jsonObject) number(key string) (json.Number, error) {
+	n, ok := o.fields[key].(json.Number)
+	if !ok {
+		return "", o.errorf("%q must be a number", key)
+	}
+	return n, nil
+}
+
+func (o jsonObject) list(key string) ([]interface{}, error) {
+	switch v := o.fields[key].(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		return v, nil
+	}
+	return nil, o.errorf("%q must be an array", key)
+}
+
+func (o jsonObject) objects(key string) ([]jsonObject, error) {
+	items, err := o.list(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]jsonObject, 0, len(items))
+	for i, item := range items {
+		path := fmt.Sprintf("%s[%d]", o.fieldPath(key), i)
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("ast: %s: must be an object", path)
+		}
+		out = append(out, jsonObject{fields: fields, path: path})
+	}
+	return out, nil
+}
+
+// token rebuilds a token from the object's position. The type and literal
+// are what the parser would have produced where that is known; the
+// evaluator only reads positions.
+func (o jsonObject) token(typ token.TokenType, literal string) (token.Token, error) {
+	tok := token.Token{Type: typ, Literal: literal}
+	pos, ok := o.fields["pos"]
+	if !ok || pos == nil {
+		return tok, nil
+	}
+	fields, ok := pos.(map[string]interface{})
+	if !ok {
+		return tok, o.errorf(`"pos" must be an object`)
+	}
+	p := jsonObject{fields: fields, path: o.fieldPath("pos")}
+	for key, dst := range map[string]*int{"line": &tok.Line, "column": &tok.Column, "offset": &tok.Offset} {
+		if _, ok := fields[key]; !ok {
+			continue
+		}
+		n, err := p.number(key)
+		if err != nil {
+			return tok, err
+		}
+		v, err := strconv.Atoi(n.String())
+		if err != nil {
+			return tok, p.errorf("%q must be an integer", key)
+		}
+		*dst = v
+	}
+	return tok, nil
+}
+
+func (o jsonObject) expression(key string) (Expression, error) {
+	return decodeAs[Expression](o.fields[key], o.fieldPath(key), "an expression")
+}
+
+func (o jsonObject) pattern(key string) (Pattern, error) {
+	return decodeAs[Pattern](o.fields[key], o.fieldPath(key), "a pattern")
+}
+
+func (o jsonObject) identifier(key string) (*Identifier, error) {
+	return decodeAs[*Identifier](o.fields[key], o.fieldPath(key), "an Identifier")
+}
+
+func (o jsonObject) block(key string) (*BlockExpression, error) {
+	return decodeAs[*BlockExpression](o.fields[key], o.fieldPath(key), "a BlockExpression")
+}
+
+func (o jsonObject) statements(key string) ([]Statement, error) {
+	return decodeList[Statement](o, key, "a statement")
+}
+
+func (o jsonObject) expressions(key string) ([]Expression, error) {
+	return decodeList[Expression](o, key, "an expression")
+}
+
+func (o jsonObject) patterns(key string) ([]Pattern, error) {
+	return decodeList[Pattern](o, key, "a pattern")
+}
+
+// decodeAs decodes an optional child that must be a T; null gives T's zero
+// value.
+func decodeAs[T Node](value interface{}, path, what string) (T, error) {
+	var zero T
+	node, err := decodeNode(value, path)
+	if err != nil || node == nil {
+		return zero, err
+	}
+	t, ok := node.(T)
+	if !ok {
+		return zero, fmt.Errorf("ast: %s: %T is not %s", path, node, what)
+	}
+	return t, nil
+}
+
+func decodeList[T Node](o jsonObject, key, what string) ([]T, error) {
+	items, err := o.list(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]T, 0, len(items))
+	for i, item := range items {
+		path := fmt.Sprintf("%s[%d]", o.fieldPath(key), i)
+		if item == nil {
+			return nil, fmt.Errorf("ast: %s: must not be null", path)
+		}
+		t, err := decodeAs[T](item, path, what)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+func decodeNode(value interface{}, path string) (Node, error) {
+	if value == nil {
+		return nil, nil
+	}
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("ast: %s: a node must be an object", path)
+	}
+	o := jsonObject{fields: fields, path: path}
+	typ, err := o.str("type")
+	if err != nil {
+		return nil, err
+	}
+	node, err := o.decode(typ)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, o.errorf("unknown node type %q", typ)
+	}
+	return node, nil
+}
+
+// decode builds a node of the given JSON type; it mirrors nodeToJSON. The
+// returned node is nil for an unknown type.
+func (o jsonObject) decode(typ string) (Node, error) {
+	var err error
+	// check records the first error from the field decoders below, so each
+	// case can read its fields in one go.
+	check := func(e error) {
+		if err == nil {
+			err = e
+		}
+	}
+	tok := func(typ token.TokenType, literal string) token.Token {
+		t, e := o.token(typ, literal)
+		check(e)
+		return t
+	}
+	expr := func(key string) Expression {
+		e, de := o.expression(key)
+		check(de)
+		return e
+	}
+	pat := func(key string) Pattern {
+		p, de := o.pattern(key)
+		check(de)
+		return p
+	}
+	ident := func(key string) *Identifier {
+		id, de := o.identifier(key)
+		check(de)
+		return id
+	}
+	exprs := func(key string) []Expression {
+		list, de := o.expressions(key)
+		check(de)
+		return list
+	}
+	pats := func(key string) []Pattern {
+		list, de := o.patterns(key)
+		check(de)
+		return list
+	}
+	stmts := func(key string) []Statement {
+		list, de := o.statements(key)
+		check(de)
+		return list
+	}
+	str := func(key string) string {
+		s, de := o.str(key)
+		check(de)
+		return s
+	}
+	op := func() string { return str("operator") }
+	// literal returns how a number was written, which defaults to its value.
+	literal := func(value string) string {
+		if _, ok := o.fields["literal"]; !ok {
+			return value
+		}
+		return str("literal")
+	}
+
+	var node Node
+	switch typ {
+	case "Program":
+		node = &Program{Statements: stmts("statements")}
+	case "LetStatement":
+		node = &LetStatement{Token: tok(token.LET, "let"), Name: pat("name"), Value: expr("value")}
+	case "ExpressionStatement":
+		node = &ExpressionStatement{Token: tok("", ""), Expression: expr("expression")}
+	case "Identifier":
+		value := str("value")
+		node = &Identifier{Token: tok(token.IDENT, value), Value: value}
+	case "Placeholder":
+		node = &Placeholder{Token: tok(token.IDENT, "_")}
+	case "IntegerLiteral":
+		n, e := o.number("value")
+		check(e)
+		value, e := strconv.ParseInt(n.String(), 10, 64)
+		if e != nil && err == nil {
+			err = o.errorf(`"value" must be an integer`)
+		}
+		lit := literal(n.String())
+		if v, e := strconv.ParseInt(lit, 0, 64); (e != nil || v != value) && err == nil {
+			err = o.errorf(`"literal" %q does not match "value"`, lit)
+		}
+		node = &IntegerLiteral{Token: tok(token.INT, lit), Value: value}
+	case "FloatLiteral":
+		n, e := o.number("value")
+		check(e)
+		value, e := n.Float64()
+		if e != nil && err == nil {
+			err = o.errorf(`"value" must be a number`)
+		}
+		lit := literal(n.String())
+		if v, e := strconv.ParseFloat(lit, 64); (e != nil || v != value) && err == nil {
+			err = o.errorf(`"literal" %q does not match "value"`, lit)
+		}
+		node = &FloatLiteral{Token: tok(token.FLOAT, lit), Value: value}
+	case "StringLiteral":
+		value := str("value")
+		raw, e := o.boolean("raw")
+		check(e)
+		multiline, e := o.boolean("multiline")
+		check(e)
+		node = &StringLiteral{Token: tok(token.STRING, value), Value: value, Raw: raw, Multiline: multiline}
+	case "CharLiteral":
+		value := str("value")
+		node = &CharLiteral{Token: tok(token.CHAR, value), Value: value}
+	case "BooleanLiteral":
+		value, e := o.boolean("value")
+		check(e)
+		node = &BooleanLiteral{Token: tok(token.TRUE, strconv.FormatBool(value)), Value: value}
+		if !value {
+			node.(*BooleanLiteral).Token.Type = token.FALSE
+		}
+	case "NullLiteral":
+		node = &NullLiteral{Token: tok(token.NULL, "null")}
+	case "UnitLiteral":
+		node = &UnitLiteral{Token: tok(token.LPAREN, "(")}
+	case "PrefixExpression":
+		operator := op()
+		node = &PrefixExpression{Token: tok(token.TokenType(operator), operator), Operator: operator, Right: expr("right")}
+	case "InfixExpression":
+		operator := op()
+		node = &InfixExpression{Token: tok(token.TokenType(operator), operator), Left: expr("left"), Operator: operator, Right: expr("right")}
+	case "AssignExpression":
+		operator := op()
+		node = &AssignExpression{Token: tok(token.TokenType(operator), operator), Left: expr("left"), Operator: operator, Right: expr("right")}
+	case "PostfixExpression":
+		operator := op()
+		node = &PostfixExpression{Token: tok(token.TokenType(operator), operator), Left: expr("left"), Operator: operator}
+	case "WaitExpression":
+		node = &AwaitExpression{Token: tok(token.WAIT, "wait"), Value: expr("value")}
+	case "ImportExpression":
+		path, e := decodeAs[*StringLiteral](o.fields["path"], o.fieldPath("path"), "a StringLiteral")
+		check(e)
+		node = &ImportExpression{Token: tok(token.IMPORT, "import"), Path: path}
+	case "IfExpression":
+		consequence, e := o.block("consequence")
+		check(e)
+		node = &IfExpression{Token: tok(token.IF, "if"), Condition: expr("condition"), Consequence: consequence, Alternative: expr("alternative")}
+	case "BlockExpression":
+		node = &BlockExpression{Token: tok(token.LBRACE, "{"), Statements: stmts("statements")}
+	case "MatchExpression":
+		arms, e := o.objects("arms")
+		check(e)
+		match := &MatchExpression{Token: tok(token.MATCH, "match"), Value: expr("value"), Arms: []MatchArm{}}
+		for _, arm := range arms {
+			armTok, e := arm.token(token.CASE, "case")
+			check(e)
+			pattern, e := arm.pattern("pattern")
+			check(e)
+			guard, e := arm.expression("guard")
+			check(e)
+			body, e := arm.expression("body")
+			check(e)
+			match.Arms = append(match.Arms, MatchArm{Token: armTok, Pattern: pattern, Guard: guard, Body: body})
+		}
+		node = match
+	case "ForExpression":
+		bindings, e := o.objects("bindings")
+		check(e)
+		body, e := o.block("body")
+		check(e)
+		loop := &ForExpression{Token: tok(token.FOR, "for"), Condition: expr("condition"), Bindings: []Binding{}, Body: body, Then: expr("then")}
+		for _, b := range bindings {
+			pattern, e := b.pattern("pattern")
+			check(e)
+			value, e := b.expression("value")
+			check(e)
+			loop.Bindings = append(loop.Bindings, Binding{Pattern: pattern, Value: value})
+		}
+		node = loop
+	case "LambdaExpression":
+		node = &LambdaExpression{Token: tok(token.ARROW, "->"), Params: pats("params"), Body: expr("body")}
+	case "CallExpression":
+		node = &CallExpression{Token: tok(token.LPAREN, "("), Function: expr("function"), Arguments: exprs("args")}
+	case "RecoverExpression":
+		node = &RecoverExpression{Token: tok(token.QUESTION, "?"), Target: expr("target"), Fallback: expr("fallback")}
+	case "MemberExpression":
+		node = &MemberExpression{Token: tok(token.DOT, "."), Object: expr("object"), Property: ident("property")}
+	case "IndexExpression":
+		node = &IndexExpression{Token: tok(token.LBRACKET, "["), Left: expr("left"), Index: expr("index")}
+	case "SliceExpression":
+		node = &SliceExpression{Token: tok(token.LBRACKET, "["), Left: expr("left"), Start: expr("start"), End: expr("end")}
+	case "ArrayLiteral":
+		node = &ArrayLiteral{Token: tok(token.LBRACKET, "["), Elements: exprs("elements")}
+	case "ObjectLiteral":
+		node, err = o.objectLiteral()
+	case "StructInitExpression":
+		value, e := decodeAs[*ObjectLiteral](o.fields["value"], o.fieldPath("value"), "an ObjectLiteral")
+		check(e)
+		node = &StructInitExpression{Token: tok(token.IDENT, ""), TypeName: ident("typeName"), Value: value}
+	case "RangeExpression":
+		node = &RangeExpression{Token: tok(token.DOTDOT, ".."), Start: expr("start"), End: expr("end"), Step: expr("step")}
+	case "QueryExpression":
+		node = &QueryExpression{Token: tok(token.FROM, "from"), Var: ident("var"), Source: expr("source"), Where: exprs("where"), OrderBy: expr("orderBy"), Select: expr("select")}
+	case "RaceExpression":
+		node = &RaceExpression{Token: tok(token.RACE, "!&"), Tasks: exprs("tasks")}
+	case "SpawnExpression":
+		spawn := &SpawnExpression{Token: tok(token.AMPERSAND, "&"), Task: expr("task")}
+		if _, ok := o.fields["group"]; ok && spawn.Task == nil {
+			spawn.Group = exprs("group")
+		}
+		node = spawn
+	case "BreakExpression":
+		node = &BreakExpression{Token: tok(token.BREAK, "break"), Value: expr("value")}
+	case "ContinueExpression":
+		node = &ContinueExpression{Token: tok(token.CONTINUE, "continue")}
+	case "WildcardPattern":
+		node = &WildcardPattern{Token: tok(token.IDENT, "_")}
+	case "RangePattern":
+		node = &RangePattern{Token: tok("", ""), Start: pat("start"), End: pat("end")}
+	case "ObjectPattern":
+		entries, e := o.objects("entries")
+		check(e)
+		pattern := &ObjectPattern{Token: tok(token.LBRACE, "{"), Entries: []PatternEntry{}}
+		for _, entry := range entries {
+			key, e := entry.str("key")
+			check(e)
+			value, e := entry.pattern("pattern")
+			check(e)
+			entryTok, e := entry.token(token.IDENT, key)
+			check(e)
+			pattern.Entries = append(pattern.Entries, PatternEntry{Token: entryTok, Key: key, Pattern: value})
+		}
+		node = pattern
+	case "ArrayPattern":
+		node = &ArrayPattern{Token: tok(token.LBRACKET, "["), Elements: pats("elements"), Rest: pat("rest")}
+	case "TuplePattern":
+		node = &TuplePattern{Token: tok(token.LPAREN, "("), Elements: pats("elements")}
+	case "CallPattern":
+		node = &CallPattern{Token: tok(token.LPAREN, "("), Name: ident("name"), Args: pats("args")}
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+func (o jsonObject) objectLiteral() (Node, error) {
+	tok, err := o.token(token.LBRACE, "{")
+	if err != nil {
+		return nil, err
+	}
+	entries, err := o.objects("entries")
+	if err != nil {
+		return nil, err
+	}
+	lit := &ObjectLiteral{Token: tok, Entries: []ObjectEntry{}}
+	for _, entry := range entries {
+		key, err := entry.str("key")
+		if err != nil {
+			return nil, err
+		}
+		value, err := entry.expression("value")
+		if err != nil {
+			return nil, err
+		}
+		shorthand, err := entry.boolean("shorthand")
+		if err != nil {
+			return nil, err
+		}
+		spread, err := entry.boolean("spread")
+		if err != nil {
+			return nil, err
+		}
+		entryTok, err := entry.token(token.IDENT, key)
+		if err != nil {
+			return nil, err
+		}
+		lit.Entries = append(lit.Entries, ObjectEntry{Token: entryTok, Key: key, Value: value, Shorthand: shorthand, Spread: spread})
+	}
+	return lit, nil
+}
@@ -3,6 +3,8 @@ package ast
 import (
 	"bytes"
 	"fmt"
+	"strconv"
+	"strings"
 )
 
 // Format returns a multi-line, indented view of the AST.
@@ -56,11 +58,28 @@ func (p *printer) writeNode(node Node) {
 	case *Placeholder:
 		p.line("Placeholder")
 	case *IntegerLiteral:
-		p.line("Integer(%d)", n.Value)
+		if lit := writtenInteger(n); lit != "" {
+			p.line("Integer(%d, %s)", n.Value, lit)
+		} else {
+			p.line("Integer(%d)", n.Value)
+		}
 	case *FloatLiteral:
-		p.line("Float(%g)", n.Value)
+		if lit := writtenFloat(n); lit != "" {
+			p.line("Float(%g, %s)", n.Value, lit)
+		} else {
+			p.line("Float(%g)", n.Value)
+		}
 	case *StringLiteral:
-		p.line("String(%q)", n.Value)
+		switch {
+		case n.Raw && n.Multiline:
+			p.line("String(%q, raw multiline)", n.Value)
+		case n.Raw:
+			p.line("String(%q, raw)", n.Value)
+		case n.Multiline:
+			p.line("String(%q, multiline)", n.Value)
+		default:
+			p.line("String(%q)", n.Value)
+		}
 	case *CharLiteral:
 		p.line("Char(%q)", n.Value)
 	case *BooleanLiteral:
@@ -473,3 +492,21 @@ func (p *printer) writeNode(node Node) {
 		p.line("Unknown(%T)", node)
 	}
 }
+
+// writtenInteger returns how an integer literal was written when that is not
+// plain decimal (0xFF, 0b1010, 1_000), and "" otherwise.
+func writtenInteger(n *IntegerLiteral) string {
+	if lit := n.Token.Literal; lit != "" && lit != strconv.FormatInt(n.Value, 10) {
+		return lit
+	}
+	return ""
+}
+
+// writtenFloat returns how a float literal was written when it has digit
+// separators or an exponent, and "" otherwise.
+func writtenFloat(n *FloatLiteral) string {
+	if lit := n.Token.Literal; strings.ContainsAny(lit, "_eE") {
+		return lit
+	}
+	return ""
+}
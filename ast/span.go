@@ -0,0 +1,172 @@
+package ast
+
+import (
+	"strings"
+
+	"karl/token"
+)
+
+// Position is a location in source text. Line and Column are 1-based;
+// Offset is the 0-based byte offset.
+type Position struct {
+	Line   int
+	Column int
+	Offset int
+}
+
+// IsValid reports whether the position came from the source (nodes built
+// by hand have none).
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+// Span is the source range of a node; End is just past its last character.
+type Span struct {
+	Start Position
+	End   Position
+}
+
+// NodeToken returns the token a node was parsed from: the operator of an
+// infix expression, the opening delimiter of a literal or call, the keyword
+// of a statement. It returns nil for nodes without one (Program) and for nil.
+func NodeToken(node Node) *token.Token {
+	if isNil(node) {
+		return nil
+	}
+	switch n := node.(type) {
+	case *LetStatement:
+		return &n.Token
+	case *ExpressionStatement:
+		return &n.Token
+	case *Identifier:
+		return &n.Token
+	case *Placeholder:
+		return &n.Token
+	case *IntegerLiteral:
+		return &n.Token
+	case *FloatLiteral:
+		return &n.Token
+	case *StringLiteral:
+		return &n.Token
+	case *CharLiteral:
+		return &n.Token
+	case *BooleanLiteral:
+		return &n.Token
+	case *NullLiteral:
+		return &n.Token
+	case *UnitLiteral:
+		return &n.Token
+	case *PrefixExpression:
+		return &n.Token
+	case *InfixExpression:
+		return &n.Token
+	case *AssignExpression:
+		return &n.Token
+	case *PostfixExpression:
+		return &n.Token
+	case *AwaitExpression:
+		return &n.Token
+	case *ImportExpression:
+		return &n.Token
+	case *IfExpression:
+		return &n.Token
+	case *BlockExpression:
+		return &n.Token
+	case *MatchExpression:
+		return &n.Token
+	case *ForExpression:
+		return &n.Token
+	case *LambdaExpression:
+		return &n.Token
+	case *CallExpression:
+		return &n.Token
+	case *RecoverExpression:
+		return &n.Token
+	case *MemberExpression:
+		return &n.Token
+	case *IndexExpression:
+		return &n.Token
+	case *SliceExpression:
+		return &n.Token
+	case *ArrayLiteral:
+		return &n.Token
+	case *ObjectLiteral:
+		return &n.Token
+	case *StructInitExpression:
+		return &n.Token
+	case *RangeExpression:
+		return &n.Token
+	case *QueryExpression:
+		return &n.Token
+	case *RaceExpression:
+		return &n.Token
+	case *SpawnExpression:
+		return &n.Token
+	case *BreakExpression:
+		return &n.Token
+	case *ContinueExpression:
+		return &n.Token
+	case *WildcardPattern:
+		return &n.Token
+	case *RangePattern:
+		return &n.Token
+	case *ObjectPattern:
+		return &n.Token
+	case *ArrayPattern:
+		return &n.Token
+	case *TuplePattern:
+		return &n.Token
+	case *CallPattern:
+		return &n.Token
+	}
+	return nil
+}
+
+// NodeSpan returns the source range covered by the tokens of node and its
+// descendants. Closing delimiters are not kept in the AST, so a span ends
+// at the last token inside them: the span of f(x) ends after x. The result
+// is the zero Span when the node has no positions.
+func NodeSpan(node Node) Span {
+	var span Span
+	Inspect(node, func(n Node) bool {
+		tok := NodeToken(n)
+		if tok == nil || tok.Line == 0 {
+			return true
+		}
+		ts := TokenSpan(*tok)
+		if !span.Start.IsValid() || ts.Start.Offset < span.Start.Offset {
+			span.Start = ts.Start
+		}
+		if !span.End.IsValid() || ts.End.Offset > span.End.Offset {
+			span.End = ts.End
+		}
+		return true
+	})
+	return span
+}
+
+// NodeStart returns where node starts; see NodeSpan.
+func NodeStart(node Node) Position { return NodeSpan(node).Start }
+
+// NodeEnd returns where node ends; see NodeSpan.
+func NodeEnd(node Node) Position { return NodeSpan(node).End }
+
+// TokenSpan returns the source range of one token. String and character
+// tokens from the lexer record their source text; for ones built without it
+// the width is the value plus the quotes, short by one per escape sequence.
+func TokenSpan(tok token.Token) Span {
+	start := Position{Line: tok.Line, Column: tok.Column, Offset: tok.Offset}
+	text := tok.Literal
+	switch {
+	case tok.Source != "":
+		text = tok.Source
+	case tok.Type == token.STRING:
+		text = `"` + text + `"`
+	case tok.Type == token.CHAR:
+		text = "'" + text + "'"
+	}
+	end := Position{Line: tok.Line, Column: tok.Column + len(text), Offset: tok.Offset + len(text)}
+	if i := strings.LastIndexByte(text, '\n'); i >= 0 {
+		end.Line += strings.Count(text, "\n")
+		end.Column = len(text) - i
+	}
+	return Span{Start: start, End: end}
+}
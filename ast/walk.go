@@ -0,0 +1,351 @@
+package ast
+
+import "fmt"
+
+// A Visitor's Visit method is called for each node Walk encounters. If the
+// returned visitor w is not nil, Walk visits each child of node with w and
+// then calls w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses the AST in depth-first order, in source order. Match arms,
+// for bindings, object entries and pattern entries are not nodes themselves;
+// their patterns and expressions are visited as children of the enclosing
+// node. Nil children are skipped.
+func Walk(v Visitor, node Node) {
+	if isNil(node) {
+		return
+	}
+	if v = v.Visit(node); v == nil {
+		return
+	}
+	eachChild(node, func(child Node) { Walk(v, child) })
+	v.Visit(nil)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if node != nil && f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect calls f for node and, while f returns true, for each of its
+// children, depth-first.
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// eachChild calls fn for every non-nil child of node, in source order.
+func eachChild(node Node, fn func(Node)) {
+	visit := func(nodes ...Node) {
+		for _, n := range nodes {
+			if !isNil(n) {
+				fn(n)
+			}
+		}
+	}
+	switch n := node.(type) {
+	case *Program:
+		for _, stmt := range n.Statements {
+			visit(stmt)
+		}
+	case *LetStatement:
+		visit(n.Name, n.Value)
+	case *ExpressionStatement:
+		visit(n.Expression)
+	case *Identifier, *Placeholder, *IntegerLiteral, *FloatLiteral, *StringLiteral,
+		*CharLiteral, *BooleanLiteral, *NullLiteral, *UnitLiteral,
+		*ContinueExpression, *WildcardPattern:
+	case *PrefixExpression:
+		visit(n.Right)
+	case *InfixExpression:
+		visit(n.Left, n.Right)
+	case *AssignExpression:
+		visit(n.Left, n.Right)
+	case *PostfixExpression:
+		visit(n.Left)
+	case *AwaitExpression:
+		visit(n.Value)
+	case *ImportExpression:
+		visit(n.Path)
+	case *IfExpression:
+		visit(n.Condition, n.Consequence, n.Alternative)
+	case *BlockExpression:
+		for _, stmt := range n.Statements {
+			visit(stmt)
+		}
+	case *MatchExpression:
+		visit(n.Value)
+		for _, arm := range n.Arms {
+			visit(arm.Pattern, arm.Guard, arm.Body)
+		}
+	case *ForExpression:
+		visit(n.Condition)
+		for _, b := range n.Bindings {
+			visit(b.Pattern, b.Value)
+		}
+		visit(n.Body, n.Then)
+	case *LambdaExpression:
+		for _, param := range n.Params {
+			visit(param)
+		}
+		visit(n.Body)
+	case *CallExpression:
+		visit(n.Function)
+		for _, arg := range n.Arguments {
+			visit(arg)
+		}
+	case *RecoverExpression:
+		visit(n.Target, n.Fallback)
+	case *MemberExpression:
+		visit(n.Object, n.Property)
+	case *IndexExpression:
+		visit(n.Left, n.Index)
+	case *SliceExpression:
+		visit(n.Left, n.Start, n.End)
+	case *ArrayLiteral:
+		for _, el := range n.Elements {
+			visit(el)
+		}
+	case *ObjectLiteral:
+		for _, entry := range n.Entries {
+			visit(entry.Value)
+		}
+	case *StructInitExpression:
+		visit(n.TypeName, n.Value)
+	case *RangeExpression:
+		visit(n.Start, n.End, n.Step)
+	case *QueryExpression:
+		visit(n.Var, n.Source)
+		for _, where := range n.Where {
+			visit(where)
+		}
+		visit(n.OrderBy, n.Select)
+	case *RaceExpression:
+		for _, task := range n.Tasks {
+			visit(task)
+		}
+	case *SpawnExpression:
+		visit(n.Task)
+		for _, task := range n.Group {
+			visit(task)
+		}
+	case *BreakExpression:
+		visit(n.Value)
+	case *RangePattern:
+		visit(n.Start, n.End)
+	case *ObjectPattern:
+		for _, entry := range n.Entries {
+			visit(entry.Pattern)
+		}
+	case *ArrayPattern:
+		for _, el := range n.Elements {
+			visit(el)
+		}
+		visit(n.Rest)
+	case *TuplePattern:
+		for _, el := range n.Elements {
+			visit(el)
+		}
+	case *CallPattern:
+		visit(n.Name)
+		for _, arg := range n.Args {
+			visit(arg)
+		}
+	default:
+		panic(fmt.Sprintf("ast: unexpected node type %T", node))
+	}
+}
+
+// isNil reports whether node is nil or a typed nil pointer, as held by an
+// unset field such as IfExpression.Alternative.
+func isNil(node Node) bool {
+	switch n := node.(type) {
+	case nil:
+		return true
+	case *BlockExpression:
+		return n == nil
+	case *Identifier:
+		return n == nil
+	case *StringLiteral:
+		return n == nil
+	case *ObjectLiteral:
+		return n == nil
+	}
+	return false
+}
+
+// Rewrite rewrites the AST bottom-up: it rewrites the children of node, then
+// returns f applied to node. f returns the node to use in its place, which may
+// be the node itself (children already rewritten), a new node, or nil to drop
+// an optional child. Replacements must fit the field they go into: an
+// Expression for an expression, a Pattern for a pattern, a Statement for a
+// statement, and the same type for fields with a concrete type such as
+// IfExpression.Consequence. Rewrite panics otherwise.
+//
+// Rewrite updates nodes in place; copy the tree first to keep the original.
+func Rewrite(node Node, f func(Node) Node) Node {
+	if isNil(node) {
+		return node
+	}
+	expr := func(e Expression) Expression { return rewriteAs(e, f) }
+	pat := func(p Pattern) Pattern { return rewriteAs(p, f) }
+	switch n := node.(type) {
+	case *Program:
+		n.Statements = rewriteStatements(n.Statements, f)
+	case *LetStatement:
+		n.Name = pat(n.Name)
+		n.Value = expr(n.Value)
+	case *ExpressionStatement:
+		n.Expression = expr(n.Expression)
+	case *PrefixExpression:
+		n.Right = expr(n.Right)
+	case *InfixExpression:
+		n.Left = expr(n.Left)
+		n.Right = expr(n.Right)
+	case *AssignExpression:
+		n.Left = expr(n.Left)
+		n.Right = expr(n.Right)
+	case *PostfixExpression:
+		n.Left = expr(n.Left)
+	case *AwaitExpression:
+		n.Value = expr(n.Value)
+	case *ImportExpression:
+		n.Path = rewriteAs[*StringLiteral](n.Path, f)
+	case *IfExpression:
+		n.Condition = expr(n.Condition)
+		n.Consequence = rewriteAs[*BlockExpression](n.Consequence, f)
+		n.Alternative = expr(n.Alternative)
+	case *BlockExpression:
+		n.Statements = rewriteStatements(n.Statements, f)
+	case *MatchExpression:
+		n.Value = expr(n.Value)
+		for i := range n.Arms {
+			arm := &n.Arms[i]
+			arm.Pattern = pat(arm.Pattern)
+			arm.Guard = expr(arm.Guard)
+			arm.Body = expr(arm.Body)
+		}
+	case *ForExpression:
+		n.Condition = expr(n.Condition)
+		for i := range n.Bindings {
+			b := &n.Bindings[i]
+			b.Pattern = pat(b.Pattern)
+			b.Value = expr(b.Value)
+		}
+		n.Body = rewriteAs[*BlockExpression](n.Body, f)
+		n.Then = expr(n.Then)
+	case *LambdaExpression:
+		for i := range n.Params {
+			n.Params[i] = pat(n.Params[i])
+		}
+		n.Body = expr(n.Body)
+	case *CallExpression:
+		n.Function = expr(n.Function)
+		for i := range n.Arguments {
+			n.Arguments[i] = expr(n.Arguments[i])
+		}
+	case *RecoverExpression:
+		n.Target = expr(n.Target)
+		n.Fallback = expr(n.Fallback)
+	case *MemberExpression:
+		n.Object = expr(n.Object)
+		n.Property = rewriteAs[*Identifier](n.Property, f)
+	case *IndexExpression:
+		n.Left = expr(n.Left)
+		n.Index = expr(n.Index)
+	case *SliceExpression:
+		n.Left = expr(n.Left)
+		n.Start = expr(n.Start)
+		n.End = expr(n.End)
+	case *ArrayLiteral:
+		for i := range n.Elements {
+			n.Elements[i] = expr(n.Elements[i])
+		}
+	case *ObjectLiteral:
+		for i := range n.Entries {
+			n.Entries[i].Value = expr(n.Entries[i].Value)
+		}
+	case *StructInitExpression:
+		n.TypeName = rewriteAs[*Identifier](n.TypeName, f)
+		n.Value = rewriteAs[*ObjectLiteral](n.Value, f)
+	case *RangeExpression:
+		n.Start = expr(n.Start)
+		n.End = expr(n.End)
+		n.Step = expr(n.Step)
+	case *QueryExpression:
+		n.Var = rewriteAs[*Identifier](n.Var, f)
+		n.Source = expr(n.Source)
+		for i := range n.Where {
+			n.Where[i] = expr(n.Where[i])
+		}
+		n.OrderBy = expr(n.OrderBy)
+		n.Select = expr(n.Select)
+	case *RaceExpression:
+		for i := range n.Tasks {
+			n.Tasks[i] = expr(n.Tasks[i])
+		}
+	case *SpawnExpression:
+		n.Task = expr(n.Task)
+		for i := range n.Group {
+			n.Group[i] = expr(n.Group[i])
+		}
+	case *BreakExpression:
+		n.Value = expr(n.Value)
+	case *RangePattern:
+		n.Start = pat(n.Start)
+		n.End = pat(n.End)
+	case *ObjectPattern:
+		for i := range n.Entries {
+			n.Entries[i].Pattern = pat(n.Entries[i].Pattern)
+		}
+	case *ArrayPattern:
+		for i := range n.Elements {
+			n.Elements[i] = pat(n.Elements[i])
+		}
+		n.Rest = pat(n.Rest)
+	case *TuplePattern:
+		for i := range n.Elements {
+			n.Elements[i] = pat(n.Elements[i])
+		}
+	case *CallPattern:
+		n.Name = rewriteAs[*Identifier](n.Name, f)
+		for i := range n.Args {
+			n.Args[i] = pat(n.Args[i])
+		}
+	}
+	return f(node)
+}
+
+func rewriteStatements(stmts []Statement, f func(Node) Node) []Statement {
+	out := stmts[:0]
+	for _, stmt := range stmts {
+		if r := rewriteAs(stmt, f); r != nil {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// rewriteAs rewrites node and checks that the result fits a field of type T.
+// A nil result becomes T's zero value; nil statements are dropped by
+// rewriteStatements.
+func rewriteAs[T Node](node T, f func(Node) Node) T {
+	var zero T
+	if isNil(node) {
+		return node
+	}
+	r := Rewrite(node, f)
+	if r == nil {
+		return zero
+	}
+	t, ok := r.(T)
+	if !ok {
+		panic(fmt.Sprintf("ast.Rewrite: cannot replace %T with %T", node, r))
+	}
+	return t
+}
@@ -6,9 +6,14 @@ func RegisterBuiltins() {
 	builtins = map[string]*Builtin{}
 	registerRuntimeBuiltins()
 	registerFSBuiltins()
+	registerLoggerBuiltins()
+	registerMetricsBuiltins()
+	registerCheckpointBuiltins()
 	registerHTTPBuiltins()
+	registerSecretBuiltins()
 	registerJSONBuiltins()
 	registerAsyncBuiltins()
+	registerSyncBuiltins()
 	registerStringBuiltins()
 	registerCollectionBuiltins()
 	registerListBuiltins()
@@ -5,7 +5,20 @@ func registerAsyncBuiltins() {
 	builtins["send"] = &Builtin{Name: "send", Fn: builtinSend}
 	builtins["recv"] = &Builtin{Name: "recv", Fn: builtinRecv}
 	builtins["done"] = &Builtin{Name: "done", Fn: builtinDone}
+	builtins["trySend"] = &Builtin{Name: "trySend", Fn: builtinTrySend}
+	builtins["tryRecv"] = &Builtin{Name: "tryRecv", Fn: builtinTryRecv}
+	builtins["broadcast"] = &Builtin{Name: "broadcast", Fn: builtinBroadcast}
+	builtins["merge"] = &Builtin{Name: "merge", Fn: builtinMerge}
+	builtins["fanOut"] = &Builtin{Name: "fanOut", Fn: builtinFanOut}
 	builtins["spawn"] = &Builtin{Name: "spawn", Fn: builtinSpawn}
+	builtins["parallelMap"] = &Builtin{Name: "parallelMap", Fn: builtinParallelMap}
+	builtins["pool"] = &Builtin{Name: "pool", Fn: builtinPool}
+	builtins["tasks"] = &Builtin{Name: "tasks", Fn: builtinTasks}
+	builtins["currentTask"] = &Builtin{Name: "currentTask", Fn: builtinCurrentTask}
+	builtins["supervise"] = &Builtin{Name: "supervise", Fn: builtinSupervise}
+	builtins["every"] = &Builtin{Name: "every", Fn: builtinEvery}
+	builtins["after"] = &Builtin{Name: "after", Fn: builtinAfter}
+	builtins["cron"] = &Builtin{Name: "cron", Fn: builtinCron}
 }
 
 func builtinSpawn(e *Evaluator, args []Value) (Value, error) {
@@ -13,13 +26,20 @@ func builtinSpawn(e *Evaluator, args []Value) (Value, error) {
 		return nil, &RuntimeError{Message: "spawn expects at least 1 argument (function)"}
 	}
 
+	// An optional leading string names the task for tasks() and task dumps.
+	name := ""
+	if label, ok := args[0].(*String); ok && len(args) > 1 {
+		name = label.Value
+		args = args[1:]
+	}
+
 	fn := args[0]
 	
 	// Internal helper to spawn the task
 	spawnTask := func(targetFn Value, callArgs []Value) (Value, error) {
-		task := e.newTask(e.currentTask, false)
+		task := e.newNamedTask(e.currentTask, false, name)
 		taskEval := e.cloneForTask(task)
-		go func() {
+		e.runtime.goAsync(func() {
 			res, sig, err := taskEval.applyFunction(targetFn, callArgs)
 			if err != nil {
 				taskEval.handleAsyncError(task, err)
@@ -30,7 +50,7 @@ func builtinSpawn(e *Evaluator, args []Value) (Value, error) {
 				return
 			}
 			task.complete(res, nil)
-		}()
+		})
 		return task, nil
 	}
 
@@ -62,7 +82,7 @@ func builtinThen(e *Evaluator, args []Value) (Value, error) {
 	fn := args[1]
 	thenTask := e.newTask(e.currentTask, false)
 	thenEval := e.cloneForTask(thenTask)
-	go func() {
+	e.runtime.goAsync(func() {
 		val, sig, err := taskAwaitWithCancel(task, thenTask.cancelCh, e.runtime)
 		if err != nil {
 			thenEval.handleAsyncError(thenTask, err)
@@ -82,6 +102,6 @@ func builtinThen(e *Evaluator, args []Value) (Value, error) {
 			return
 		}
 		thenTask.complete(res, nil)
-	}()
+	})
 	return thenTask, nil
 }
@@ -1,71 +1,275 @@
 package interpreter
 
+import "time"
+
 func builtinSend(e *Evaluator, args []Value) (Value, error) {
 	if len(args) != 2 {
 		return nil, &RuntimeError{Message: "send expects channel and value"}
 	}
+	var err error
+	switch ch := args[0].(type) {
+	case *Channel:
+		err = e.channelSend(ch, args[1])
+	case *Broadcast:
+		err = ch.send(e, args[1])
+	default:
+		return nil, &RuntimeError{Message: "send expects channel"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return UnitValue, nil
+}
+
+// builtinRecv accepts an optional `{ timeout }` (milliseconds or a duration
+// string). A receive that times out fails with a recoverable "recv" error.
+func builtinRecv(e *Evaluator, args []Value) (Value, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, &RuntimeError{Message: "recv expects channel"}
+	}
 	ch, ok := args[0].(*Channel)
 	if !ok {
-		return nil, &RuntimeError{Message: "send expects channel"}
+		return nil, &RuntimeError{Message: "recv expects channel"}
 	}
-	if ch.Closed {
-		return nil, &RuntimeError{Message: "send on closed channel"}
+	timeout := int64(-1)
+	if len(args) == 2 {
+		opts, ok := args[1].(*Object)
+		if !ok {
+			return nil, &RuntimeError{Message: "recv options must be an object"}
+		}
+		for key, val := range opts.Pairs {
+			if key != "timeout" {
+				return nil, &RuntimeError{Message: "recv unknown option: " + key}
+			}
+			ms, err := durationArg("recv timeout", val)
+			if err != nil {
+				return nil, err
+			}
+			if ms < 0 {
+				return nil, &RuntimeError{Message: "recv timeout must be non-negative"}
+			}
+			timeout = ms
+		}
+	}
+	val, okRecv, timedOut, err := e.channelRecv(ch, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if timedOut {
+		return nil, recoverableError("recv", "recv timed out after "+time.Duration(timeout*int64(time.Millisecond)).String())
 	}
+	return recvResult(val, okRecv), nil
+}
 
-	fatalCh := runtimeFatalSignal(e)
-	cancelCh := runtimeCancelSignal(e)
+func recvResult(val Value, ok bool) Value {
+	if !ok {
+		return &Array{Elements: []Value{NullValue, &Boolean{Value: true}}}
+	}
+	return &Array{Elements: []Value{val, &Boolean{Value: false}}}
+}
 
-	if cancelCh == nil && fatalCh == nil {
+func builtinDone(_ *Evaluator, args []Value) (Value, error) {
+	if len(args) != 1 {
+		return nil, &RuntimeError{Message: "done expects channel"}
+	}
+	switch ch := args[0].(type) {
+	case *Channel:
+		ch.Close()
+	case *Broadcast:
+		ch.close()
+	default:
+		return nil, &RuntimeError{Message: "done expects channel"}
+	}
+	return UnitValue, nil
+}
+
+// builtinTrySend hands the value over only if that needs no waiting: a
+// buffered channel has room, or a receiver is already blocked on a
+// rendezvous. It reports whether the value was sent.
+func builtinTrySend(e *Evaluator, args []Value) (Value, error) {
+	if len(args) != 2 {
+		return nil, &RuntimeError{Message: "trySend expects channel and value"}
+	}
+	ch, ok := args[0].(*Channel)
+	if !ok {
+		return nil, &RuntimeError{Message: "trySend expects channel"}
+	}
+	if ch.Closed {
+		return nil, &RuntimeError{Message: "send on closed channel"}
+	}
+	if runtimeScheduler(e) != nil {
+		if ch.rendezvous && (len(ch.Ch) > 0 || ch.receivers == 0) {
+			return &Boolean{Value: false}, nil
+		}
+		if len(ch.Ch) == cap(ch.Ch) {
+			return &Boolean{Value: false}, nil
+		}
 		ch.Ch <- args[1]
-		return UnitValue, nil
+		ch.sent++
+		return &Boolean{Value: true}, nil
 	}
 	select {
 	case ch.Ch <- args[1]:
-		return UnitValue, nil
-	case <-cancelCh:
-		return nil, canceledError()
-	case <-fatalCh:
-		return nil, runtimeFatalError(e)
+		return &Boolean{Value: true}, nil
+	default:
+		return &Boolean{Value: false}, nil
 	}
 }
 
-func builtinRecv(e *Evaluator, args []Value) (Value, error) {
+// builtinTryRecv returns `[value, ok]` without blocking; ok is false when no
+// value was ready, whether or not the channel is closed.
+func builtinTryRecv(e *Evaluator, args []Value) (Value, error) {
 	if len(args) != 1 {
-		return nil, &RuntimeError{Message: "recv expects channel"}
+		return nil, &RuntimeError{Message: "tryRecv expects channel"}
 	}
 	ch, ok := args[0].(*Channel)
 	if !ok {
-		return nil, &RuntimeError{Message: "recv expects channel"}
+		return nil, &RuntimeError{Message: "tryRecv expects channel"}
+	}
+	var val Value = NullValue
+	got := false
+	if runtimeScheduler(e) != nil {
+		if len(ch.Ch) > 0 {
+			val = <-ch.Ch
+			ch.received++
+			got = true
+		}
+	} else {
+		select {
+		case val = <-ch.Ch:
+			got = true
+		default:
+		}
+	}
+	return &Array{Elements: []Value{val, &Boolean{Value: got}}}, nil
+}
+
+// channelSend blocks until val is buffered or taken by a receiver. Sending on
+// a closed channel, or one closed while the send waits, is a runtime error.
+func (e *Evaluator) channelSend(ch *Channel, val Value) error {
+	if ch.Closed {
+		return &RuntimeError{Message: "send on closed channel"}
+	}
+
+	fatalCh := runtimeFatalSignal(e)
+	cancelCh := runtimeCancelSignal(e)
+	defer e.waitSpan("send", "channel", nil)()
+
+	if runtimeScheduler(e) != nil {
+		return deterministicSend(e, ch, val, cancelCh, fatalCh)
 	}
-	var val Value
-	var okRecv bool
+	var err error
+	e.blocking(func() {
+		select {
+		case ch.Ch <- val:
+		case <-ch.closedCh:
+			err = &RuntimeError{Message: "send on closed channel"}
+		case <-cancelCh:
+			err = canceledError()
+		case <-fatalCh:
+			err = runtimeFatalError(e)
+		}
+	})
+	return err
+}
+
+// channelRecv blocks until a value arrives or the channel is closed and
+// drained (ok is false). A non-negative timeout in milliseconds bounds the
+// wait; timedOut reports that it ran out.
+func (e *Evaluator) channelRecv(ch *Channel, timeout int64) (val Value, ok bool, timedOut bool, err error) {
 	fatalCh := runtimeFatalSignal(e)
 	cancelCh := runtimeCancelSignal(e)
-	if cancelCh == nil && fatalCh == nil {
-		val, okRecv = <-ch.Ch
-	} else {
+	defer e.waitSpan("recv", "channel", nil)()
+	if runtimeScheduler(e) != nil {
+		return deterministicRecv(e, ch, timeout, cancelCh, fatalCh)
+	}
+	e.blocking(func() {
+		var timer <-chan time.Time
+		if timeout >= 0 {
+			t := time.NewTimer(time.Duration(timeout) * time.Millisecond)
+			defer t.Stop()
+			timer = t.C
+		}
 		select {
-		case val, okRecv = <-ch.Ch:
+		case val = <-ch.Ch:
+			ok = true
+		case <-ch.closedCh:
+			// Closing races with buffered values; hand those out first.
+			select {
+			case val = <-ch.Ch:
+				ok = true
+			default:
+			}
+		case <-timer:
+			timedOut = true
 		case <-cancelCh:
-			return nil, canceledError()
+			err = canceledError()
 		case <-fatalCh:
-			return nil, runtimeFatalError(e)
+			err = runtimeFatalError(e)
 		}
+	})
+	return val, ok, timedOut, err
+}
+
+// deterministicSend parks until the channel has room, so the Go send below
+// never blocks. Rendezvous channels then wait for a receiver to take the value.
+func deterministicSend(e *Evaluator, ch *Channel, val Value, cancelCh <-chan struct{}, fatalCh <-chan struct{}) error {
+	if err := e.runtime.park(func() bool {
+		return ch.Closed || len(ch.Ch) < cap(ch.Ch) || isClosed(cancelCh) || isClosed(fatalCh)
+	}); err != nil {
+		return err
+	}
+	if err := e.runtime.interruptedError(cancelCh, fatalCh); err != nil {
+		return err
 	}
-	if !okRecv {
-		return &Array{Elements: []Value{NullValue, &Boolean{Value: true}}}, nil
+	if ch.Closed {
+		return &RuntimeError{Message: "send on closed channel"}
+	}
+	ch.Ch <- val
+	ch.sent++
+	if !ch.rendezvous {
+		return nil
+	}
+
+	ticket := ch.sent
+	if err := e.runtime.park(func() bool {
+		return ch.received >= ticket || ch.Closed || isClosed(cancelCh) || isClosed(fatalCh)
+	}); err != nil {
+		return err
+	}
+	if ch.received < ticket {
+		// Interrupted or closed before any receiver took the value: withdraw it.
+		<-ch.Ch
+		ch.sent--
+		if err := e.runtime.interruptedError(cancelCh, fatalCh); err != nil {
+			return err
+		}
+		return &RuntimeError{Message: "send on closed channel"}
 	}
-	return &Array{Elements: []Value{val, &Boolean{Value: false}}}, nil
+	return nil
 }
 
-func builtinDone(_ *Evaluator, args []Value) (Value, error) {
-	if len(args) != 1 {
-		return nil, &RuntimeError{Message: "done expects channel"}
+func deterministicRecv(e *Evaluator, ch *Channel, timeout int64, cancelCh <-chan struct{}, fatalCh <-chan struct{}) (Value, bool, bool, error) {
+	ready := func() bool {
+		return ch.Closed || len(ch.Ch) > 0 || isClosed(cancelCh) || isClosed(fatalCh)
 	}
-	ch, ok := args[0].(*Channel)
-	if !ok {
-		return nil, &RuntimeError{Message: "done expects channel"}
+	ch.receivers++
+	var err error
+	if timeout >= 0 {
+		err = e.runtime.sleepVirtual(timeout, ready)
+	} else {
+		err = e.runtime.park(ready)
 	}
-	ch.Close()
-	return UnitValue, nil
+	ch.receivers--
+	if err != nil {
+		return nil, false, false, err
+	}
+	if err := e.runtime.interruptedError(cancelCh, fatalCh); err != nil {
+		return nil, false, false, err
+	}
+	if len(ch.Ch) > 0 {
+		ch.received++
+		return <-ch.Ch, true, false, nil
+	}
+	return nil, false, !ch.Closed, nil
 }
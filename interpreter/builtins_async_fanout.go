@@ -0,0 +1,184 @@
+package interpreter
+
+import "sync/atomic"
+
+// builtinBroadcast creates a Broadcast. The optional size is the buffer of
+// each subscription; with the default of 0 a send waits for every
+// subscriber in turn.
+func builtinBroadcast(_ *Evaluator, args []Value) (Value, error) {
+	if len(args) > 1 {
+		return nil, &RuntimeError{Message: "broadcast expects optional buffer size"}
+	}
+	size := 0
+	if len(args) == 1 {
+		n, ok := args[0].(*Integer)
+		if !ok || n.Value < 0 {
+			return nil, &RuntimeError{Message: "broadcast expects non-negative integer buffer size"}
+		}
+		if n.Value > 1000000 {
+			return nil, &RuntimeError{Message: "broadcast buffer size too large (max 1000000)"}
+		}
+		size = int(n.Value)
+	}
+	return &Broadcast{size: size}, nil
+}
+
+// subscribe returns a channel that receives every message sent from now on.
+// Subscribing to a closed broadcast yields a closed channel.
+func (b *Broadcast) subscribe(e *Evaluator) *Channel {
+	ch := newChannel(e, b.size)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		ch.Close()
+		return ch
+	}
+	b.subs = append(b.subs, ch)
+	return ch
+}
+
+// send delivers val to each current subscriber. A subscriber that closed its
+// channel is dropped instead of failing the send.
+func (b *Broadcast) send(e *Evaluator, val Value) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return &RuntimeError{Message: "send on closed broadcast"}
+	}
+	subs := append([]*Channel(nil), b.subs...)
+	b.mu.Unlock()
+
+	for _, ch := range subs {
+		if ch.Closed {
+			b.unsubscribe(ch)
+			continue
+		}
+		if err := e.channelSend(ch, val); err != nil {
+			if !ch.Closed {
+				return err
+			}
+			b.unsubscribe(ch)
+		}
+	}
+	return nil
+}
+
+func (b *Broadcast) unsubscribe(ch *Channel) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, sub := range b.subs {
+		if sub == ch {
+			b.subs = append(b.subs[:i], b.subs[i+1:]...)
+			return
+		}
+	}
+}
+
+// close closes every subscription; subscribers still receive what was
+// buffered before they see the close.
+func (b *Broadcast) close() {
+	b.mu.Lock()
+	subs := b.subs
+	b.subs = nil
+	b.closed = true
+	b.mu.Unlock()
+	for _, ch := range subs {
+		ch.Close()
+	}
+}
+
+func (b *Broadcast) subscriberCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+// builtinMerge forwards values from every input channel into one new channel,
+// which is closed once all inputs are closed. Inputs are given as arguments
+// or as a single array.
+func builtinMerge(e *Evaluator, args []Value) (Value, error) {
+	if len(args) == 1 {
+		if arr, ok := args[0].(*Array); ok {
+			args = arr.Elements
+		}
+	}
+	inputs := make([]*Channel, 0, len(args))
+	for _, arg := range args {
+		ch, ok := arg.(*Channel)
+		if !ok {
+			return nil, &RuntimeError{Message: "merge expects channels"}
+		}
+		inputs = append(inputs, ch)
+	}
+	out := newChannel(e, 0)
+	if len(inputs) == 0 {
+		out.Close()
+		return out, nil
+	}
+	var remaining atomic.Int64
+	remaining.Store(int64(len(inputs)))
+	for _, in := range inputs {
+		e.spawnForwarder("merge", in, out, func() {
+			if remaining.Add(-1) == 0 {
+				out.Close()
+			}
+		})
+	}
+	return out, nil
+}
+
+// builtinFanOut spreads the values of one channel over n new channels. Each
+// value goes to exactly one output, whichever is ready first; all outputs are
+// closed once the input is closed and drained.
+func builtinFanOut(e *Evaluator, args []Value) (Value, error) {
+	if len(args) != 2 {
+		return nil, &RuntimeError{Message: "fanOut expects channel and count"}
+	}
+	in, ok := args[0].(*Channel)
+	if !ok {
+		return nil, &RuntimeError{Message: "fanOut expects channel"}
+	}
+	n, ok := args[1].(*Integer)
+	if !ok || n.Value < 1 {
+		return nil, &RuntimeError{Message: "fanOut expects positive integer count"}
+	}
+	if n.Value > 10000 {
+		return nil, &RuntimeError{Message: "fanOut count too large (max 10000)"}
+	}
+	outs := make([]Value, n.Value)
+	for i := range outs {
+		out := newChannel(e, 0)
+		outs[i] = out
+		e.spawnForwarder("fanOut", in, out, out.Close)
+	}
+	return &Array{Elements: outs}, nil
+}
+
+// spawnForwarder starts an internal child task of the current task that
+// copies values from in to out until in is closed, the task is canceled or
+// out is closed by its reader. finish runs however the loop ends.
+func (e *Evaluator) spawnForwarder(name string, in, out *Channel, finish func()) {
+	forward := &Builtin{Name: name, Fn: func(te *Evaluator, _ []Value) (Value, error) {
+		defer finish()
+		for {
+			val, ok, _, err := te.channelRecv(in, -1)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return UnitValue, nil
+			}
+			if err := te.channelSend(out, val); err != nil {
+				if out.Closed {
+					return UnitValue, nil
+				}
+				return nil, err
+			}
+		}
+	}}
+	task := e.newNamedTask(e.currentTask, true, name)
+	taskEval := e.cloneForTask(task)
+	e.runtime.goAsync(func() {
+		taskEval.completeWithCall(task, forward, nil)
+	})
+}
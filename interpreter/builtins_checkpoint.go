@@ -0,0 +1,193 @@
+package interpreter
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const checkpointFormatVersion = 1
+
+// Checkpoint is a durable key/value store for resumable runs. Every put and
+// every completed step rewrites the backing file atomically (temp file, fsync,
+// rename), so a crash leaves either the previous or the new state on disk.
+//
+// Values are kept in their encoded form: put snapshots the value, and get
+// returns a fresh copy that callers may mutate freely.
+type Checkpoint struct {
+	path   string
+	values map[string]any
+	steps  map[string]any
+
+	// version counts in-memory changes; written is the newest version on
+	// disk. writeMu orders file writes that run without the interpreter lock.
+	version uint64
+	writeMu sync.Mutex
+	written uint64
+}
+
+func (c *Checkpoint) Type() ValueType { return CHECKPOINT }
+func (c *Checkpoint) Inspect() string { return "<checkpoint " + c.path + ">" }
+
+type checkpointFile struct {
+	Version int            `json:"version"`
+	Values  map[string]any `json:"values"`
+	Steps   map[string]any `json:"steps"`
+}
+
+func registerCheckpointBuiltins() {
+	builtins["checkpoint"] = &Builtin{Name: "checkpoint", Fn: builtinCheckpoint}
+}
+
+func builtinCheckpoint(_ *Evaluator, args []Value) (Value, error) {
+	if len(args) != 1 {
+		return nil, &RuntimeError{Message: "checkpoint expects path"}
+	}
+	path, ok := stringArg(args[0])
+	if !ok {
+		return nil, &RuntimeError{Message: "checkpoint expects string path"}
+	}
+	c := &Checkpoint{path: path, values: map[string]any{}, steps: map[string]any{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, recoverableError("checkpoint", "checkpoint error: "+err.Error())
+	}
+	var file checkpointFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, corruptCheckpointError()
+	}
+	if file.Version != checkpointFormatVersion {
+		return nil, recoverableError("checkpoint", "checkpoint file has unsupported version")
+	}
+	if file.Values != nil {
+		c.values = file.Values
+	}
+	if file.Steps != nil {
+		c.steps = file.Steps
+	}
+	return c, nil
+}
+
+func corruptCheckpointError() *RecoverableError {
+	return recoverableError("checkpoint", "checkpoint file is corrupt")
+}
+
+func (c *Checkpoint) get(key string) (Value, bool, error) {
+	tree, ok := c.values[key]
+	if !ok {
+		return nil, false, nil
+	}
+	val, err := decodeCheckpointValue(tree)
+	return val, true, err
+}
+
+func (c *Checkpoint) put(e *Evaluator, key string, val Value) error {
+	tree, err := encodeCheckpointValue(val)
+	if err != nil {
+		return err
+	}
+	c.values[key] = tree
+	return c.persist(e)
+}
+
+func (c *Checkpoint) remove(e *Evaluator, key string) (bool, error) {
+	if _, ok := c.values[key]; !ok {
+		return false, nil
+	}
+	delete(c.values, key)
+	return true, c.persist(e)
+}
+
+// step returns the recorded result of name, or runs fn and records its
+// result. A failing fn records nothing, so the step runs again on resume.
+func (c *Checkpoint) step(e *Evaluator, name string, fn Value) (Value, error) {
+	if tree, ok := c.steps[name]; ok {
+		return decodeCheckpointValue(tree)
+	}
+	val, _, err := e.applyFunction(fn, nil)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := encodeCheckpointValue(val)
+	if err != nil {
+		return nil, err
+	}
+	c.steps[name] = tree
+	if err := c.persist(e); err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+func (c *Checkpoint) persist(e *Evaluator) error {
+	c.version++
+	version := c.version
+	data, err := json.Marshal(checkpointFile{
+		Version: checkpointFormatVersion,
+		Values:  c.values,
+		Steps:   c.steps,
+	})
+	if err != nil {
+		return recoverableError("checkpoint", "checkpoint error: "+err.Error())
+	}
+	var writeErr error
+	e.blocking(func() {
+		c.writeMu.Lock()
+		defer c.writeMu.Unlock()
+		if version <= c.written {
+			// A newer snapshot already reached the disk.
+			return
+		}
+		writeErr = writeFileAtomic(c.path, data)
+		if writeErr == nil {
+			c.written = version
+		}
+	})
+	if writeErr != nil {
+		return recoverableError("checkpoint", "checkpoint error: "+writeErr.Error())
+	}
+	return nil
+}
+
+// writeFileAtomic replaces path with data so that readers and crashes only
+// ever observe the old or the new content.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	cleanup := func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmpName)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		cleanup()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		cleanup()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	// Persist the rename itself. Not every platform can fsync a directory,
+	// so failures here are ignored.
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		_ = d.Close()
+	}
+	return nil
+}
@@ -12,6 +12,30 @@ func registerCollectionBuiltins() {
 	builtins["keys"] = &Builtin{Name: "keys", Fn: builtinMapKeys}
 	builtins["values"] = &Builtin{Name: "values", Fn: builtinMapValues}
 	builtins["len"] = &Builtin{Name: "len", Fn: builtinLen}
+	builtins["new"] = &Builtin{Name: "new", Fn: builtinNew}
+}
+
+// builtinNew makes an object that falls back to proto for the properties it
+// lacks, so methods written once on proto serve every object made from it.
+func builtinNew(_ *Evaluator, args []Value) (Value, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, &RuntimeError{Message: "new expects prototype object and optional fields object"}
+	}
+	proto, ok := args[0].(*Object)
+	if !ok {
+		return nil, &RuntimeError{Message: "new expects prototype object"}
+	}
+	obj := &Object{Pairs: map[string]Value{}, Proto: proto}
+	if len(args) == 2 {
+		fields, ok := objectPairs(args[1])
+		if !ok {
+			return nil, &RuntimeError{Message: "new expects fields object"}
+		}
+		for k, v := range fields {
+			obj.Pairs[k] = v
+		}
+	}
+	return obj, nil
 }
 
 func builtinLen(_ *Evaluator, args []Value) (Value, error) {
@@ -0,0 +1,171 @@
+package interpreter
+
+import "strings"
+
+// Task-local context is an immutable string-keyed map carried by each
+// evaluator. withContext runs a function on a copy of the evaluator with more
+// values, and tasks spawned from there start with the values of their
+// spawner, so correlation IDs follow the work across the task tree.
+
+func registerContextBuiltins() {
+	builtins["withContext"] = &Builtin{Name: "withContext", Fn: builtinWithContext}
+	builtins["ctx"] = &Builtin{Name: "ctx", Fn: builtinCtx}
+	builtins["logContext"] = &Builtin{Name: "logContext", Fn: builtinLogContext}
+}
+
+func builtinWithContext(e *Evaluator, args []Value) (Value, error) {
+	if len(args) != 2 {
+		return nil, &RuntimeError{Message: "withContext expects values object and function"}
+	}
+	values, ok := objectPairs(args[0])
+	if !ok {
+		return nil, &RuntimeError{Message: "withContext expects values object"}
+	}
+	if !isCallable(args[1]) {
+		return nil, &RuntimeError{Message: "withContext expects function"}
+	}
+	scoped := *e
+	scoped.context = extendContext(e.context, values)
+	val, sig, err := scoped.applyFunction(args[1], nil)
+	if err != nil {
+		return nil, err
+	}
+	if sig != nil {
+		return nil, &RuntimeError{Message: "break/continue outside loop"}
+	}
+	return val, nil
+}
+
+// extendContext returns a new map so contexts already handed to tasks never
+// change. A null value removes the key.
+func extendContext(base map[string]Value, values map[string]Value) map[string]Value {
+	next := make(map[string]Value, len(base)+len(values))
+	for k, v := range base {
+		next[k] = v
+	}
+	for k, v := range values {
+		if v == NullValue {
+			delete(next, k)
+			continue
+		}
+		next[k] = v
+	}
+	return next
+}
+
+// builtinCtx returns one context value (null when unset), or all of them as
+// an object when called without arguments.
+func builtinCtx(e *Evaluator, args []Value) (Value, error) {
+	switch len(args) {
+	case 0:
+		return contextObject(e.context), nil
+	case 1:
+		key, ok := stringArg(args[0])
+		if !ok {
+			return nil, &RuntimeError{Message: "ctx expects string key"}
+		}
+		if val, ok := e.context[key]; ok {
+			return val, nil
+		}
+		return NullValue, nil
+	default:
+		return nil, &RuntimeError{Message: "ctx expects optional key"}
+	}
+}
+
+func contextObject(context map[string]Value) *Object {
+	pairs := make(map[string]Value, len(context))
+	for k, v := range context {
+		pairs[k] = v
+	}
+	return &Object{Pairs: pairs}
+}
+
+// builtinLogContext selects the context keys log appends to every line.
+// Calling it without keys turns that off again.
+func builtinLogContext(e *Evaluator, args []Value) (Value, error) {
+	if len(args) == 1 {
+		if arr, ok := args[0].(*Array); ok {
+			args = arr.Elements
+		}
+	}
+	keys := make([]string, 0, len(args))
+	for _, arg := range args {
+		key, ok := stringArg(arg)
+		if !ok {
+			return nil, &RuntimeError{Message: "logContext expects string keys"}
+		}
+		keys = append(keys, key)
+	}
+	e.SetLogContextKeys(keys)
+	return UnitValue, nil
+}
+
+// SetLogContextKeys makes log append `key=value` for each of keys that is
+// set in the logging task's context, and loggers add them as fields.
+func (e *Evaluator) SetLogContextKeys(keys []string) {
+	if e.runtime == nil {
+		e.runtime = newRuntimeState()
+	}
+	r := e.runtime
+	r.mu.Lock()
+	r.logContextKeys = append([]string(nil), keys...)
+	r.mu.Unlock()
+}
+
+func (e *Evaluator) logContextSuffix() string {
+	var b strings.Builder
+	for _, key := range e.logContextKeys() {
+		val, ok := e.context[key]
+		if !ok {
+			continue
+		}
+		b.WriteString(" ")
+		b.WriteString(key)
+		b.WriteString("=")
+		b.WriteString(formatLogValue(val))
+	}
+	return b.String()
+}
+
+// logContextFields returns the selected context keys that are set, for
+// structured loggers to add as fields.
+func (e *Evaluator) logContextFields() map[string]Value {
+	fields := map[string]Value{}
+	for _, key := range e.logContextKeys() {
+		if val, ok := e.context[key]; ok {
+			fields[key] = val
+		}
+	}
+	return fields
+}
+
+func (e *Evaluator) logContextKeys() []string {
+	if e.runtime == nil || len(e.context) == 0 {
+		return nil
+	}
+	r := e.runtime
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.logContextKeys
+}
+
+// contextHeaders maps the http `contextHeaders` option, `{ key: "Header" }`,
+// to the headers for the context keys that are set.
+func contextHeaders(e *Evaluator, option Value) (map[string]string, error) {
+	mapping, ok := objectPairs(option)
+	if !ok {
+		return nil, &RuntimeError{Message: "http contextHeaders must be an object"}
+	}
+	headers := map[string]string{}
+	for key, nameVal := range mapping {
+		name, ok := stringArg(nameVal)
+		if !ok {
+			return nil, &RuntimeError{Message: "http contextHeaders values must be header names"}
+		}
+		if val, ok := e.context[key]; ok {
+			headers[name] = formatLogValue(val)
+		}
+	}
+	return headers, nil
+}
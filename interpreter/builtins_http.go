@@ -5,7 +5,9 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 )
 
 func registerHTTPBuiltins() {
@@ -38,7 +40,7 @@ func builtinHTTP(e *Evaluator, args []Value) (Value, error) {
 	}
 	var body io.Reader
 	if bodyVal, ok := reqObj["body"]; ok && bodyVal != NullValue {
-		bodyStr, ok := stringArg(bodyVal)
+		bodyStr, ok := sinkStringArg(bodyVal)
 		if !ok {
 			return nil, &RuntimeError{Message: "http body must be string"}
 		}
@@ -66,6 +68,15 @@ func builtinHTTP(e *Evaluator, args []Value) (Value, error) {
 	if err != nil {
 		return nil, recoverableError("http", "http request error: "+err.Error())
 	}
+	if mapping, ok := reqObj["contextHeaders"]; ok && mapping != NullValue {
+		headers, err := contextHeaders(e, mapping)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+	}
 	if headersVal, ok := reqObj["headers"]; ok && headersVal != NullValue {
 		headers, err := extractHeaders(headersVal)
 		if err != nil {
@@ -75,7 +86,13 @@ func builtinHTTP(e *Evaluator, args []Value) (Value, error) {
 			req.Header.Set(k, v)
 		}
 	}
-	resp, err := http.DefaultClient.Do(req)
+	var resp *http.Response
+	defer e.waitSpan("http", "http", map[string]interface{}{"method": strings.ToUpper(method), "url": urlStr})()
+	start := time.Now()
+	e.blocking(func() {
+		resp, err = http.DefaultClient.Do(req)
+	})
+	e.recordHTTPMetrics(req.Method, resp, time.Since(start))
 	if err != nil {
 		if errors.Is(err, context.Canceled) {
 			if cancelCh != nil {
@@ -97,9 +114,26 @@ func builtinHTTP(e *Evaluator, args []Value) (Value, error) {
 		return nil, recoverableError("http", "http error: "+err.Error())
 	}
 	defer resp.Body.Close()
-	data, err := io.ReadAll(resp.Body)
+	var data []byte
+	e.blocking(func() {
+		data, err = io.ReadAll(resp.Body)
+	})
 	if err != nil {
 		return nil, recoverableError("http", "http read error: "+err.Error())
 	}
 	return httpResponseObject(resp, data), nil
 }
+
+func (e *Evaluator) recordHTTPMetrics(method string, resp *http.Response, elapsed time.Duration) {
+	if e.runtime == nil {
+		return
+	}
+	method = strings.ToUpper(method)
+	code := "error"
+	if resp != nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	metrics := e.runtime.metrics
+	metrics.count(metricHTTPRequests, map[string]string{"method": method, "code": code}, 1)
+	metrics.observe(metricHTTPDuration, map[string]string{"method": method}, elapsed.Seconds())
+}
@@ -10,7 +10,7 @@ func extractHeaders(val Value) (map[string]string, error) {
 	case *Object:
 		out := make(map[string]string, len(headers.Pairs))
 		for k, v := range headers.Pairs {
-			str, ok := stringArg(v)
+			str, ok := sinkStringArg(v)
 			if !ok {
 				return nil, &RuntimeError{Message: "http headers values must be strings"}
 			}
@@ -23,7 +23,7 @@ func extractHeaders(val Value) (map[string]string, error) {
 		}
 		out := make(map[string]string)
 		for k, v := range headers.Env.Snapshot() {
-			str, ok := stringArg(v)
+			str, ok := sinkStringArg(v)
 			if !ok {
 				return nil, &RuntimeError{Message: "http headers values must be strings"}
 			}
@@ -36,7 +36,7 @@ func extractHeaders(val Value) (map[string]string, error) {
 			if k.Type != STRING && k.Type != CHAR {
 				return nil, &RuntimeError{Message: "http headers keys must be strings"}
 			}
-			str, ok := stringArg(v)
+			str, ok := sinkStringArg(v)
 			if !ok {
 				return nil, &RuntimeError{Message: "http headers values must be strings"}
 			}
@@ -17,6 +17,8 @@ func encodeJSONValue(value Value) (interface{}, error) {
 		return v.Value, nil
 	case *String:
 		return v.Value, nil
+	case *Secret:
+		return secretMask, nil
 	case *Char:
 		return v.Value, nil
 	case *Array:
@@ -0,0 +1,76 @@
+package interpreter
+
+func registerLoggerBuiltins() {
+	builtins["logger"] = &Builtin{Name: "logger", Fn: builtinLogger}
+}
+
+// builtinLogger returns the default logger, or one configured by
+// `{ level, format, sink, fields }`. Options left out keep their defaults.
+func builtinLogger(e *Evaluator, args []Value) (Value, error) {
+	if len(args) > 1 {
+		return nil, &RuntimeError{Message: "logger expects optional options object"}
+	}
+	l := e.defaultLogger()
+	if len(args) == 0 {
+		return l, nil
+	}
+	opts, ok := objectPairs(args[0])
+	if !ok {
+		return nil, &RuntimeError{Message: "logger options must be an object"}
+	}
+	for key, val := range opts {
+		switch key {
+		case "level":
+			name, ok := stringArg(val)
+			if !ok {
+				return nil, &RuntimeError{Message: "logger level must be a string"}
+			}
+			level, ok := ParseLogLevel(name)
+			if !ok {
+				return nil, &RuntimeError{Message: "logger level must be \"debug\", \"info\", \"warn\" or \"error\""}
+			}
+			l.level = level
+		case "format":
+			format, ok := stringArg(val)
+			if !ok || (format != LogFormatText && format != LogFormatJSON) {
+				return nil, &RuntimeError{Message: "logger format must be \"text\" or \"json\""}
+			}
+			l.format = format
+		case "sink":
+			sink, err := loggerSink(val)
+			if err != nil {
+				return nil, err
+			}
+			l.sink = sink
+		case "fields":
+			fields, ok := objectPairs(val)
+			if !ok {
+				return nil, &RuntimeError{Message: "logger fields must be an object"}
+			}
+			l = l.child(fields)
+		default:
+			return nil, &RuntimeError{Message: "logger unknown option: " + key}
+		}
+	}
+	return l, nil
+}
+
+// loggerSink accepts "stderr", "stdout", a channel, or `{ file: path }`.
+func loggerSink(val Value) (logSink, error) {
+	switch v := val.(type) {
+	case *String:
+		switch v.Value {
+		case "stderr":
+			return streamSink{}, nil
+		case "stdout":
+			return streamSink{stdout: true}, nil
+		}
+	case *Channel:
+		return channelSink{ch: v}, nil
+	case *Object:
+		if path, ok := stringArg(v.Pairs["file"]); ok && len(v.Pairs) == 1 {
+			return fileSink{path: path}, nil
+		}
+	}
+	return nil, &RuntimeError{Message: "logger sink must be \"stderr\", \"stdout\", a channel or { file: path }"}
+}
@@ -0,0 +1,165 @@
+package interpreter
+
+func registerMetricsBuiltins() {
+	builtins["counter"] = &Builtin{Name: "counter", Fn: builtinCounter}
+	builtins["gauge"] = &Builtin{Name: "gauge", Fn: builtinGauge}
+	builtins["histogram"] = &Builtin{Name: "histogram", Fn: builtinHistogram}
+	builtins["metricsText"] = &Builtin{Name: "metricsText", Fn: builtinMetricsText}
+}
+
+func builtinCounter(e *Evaluator, args []Value) (Value, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, &RuntimeError{Message: "counter expects name and optional labels object"}
+	}
+	return e.metricArg(metricCounter, args[0], optionalArg(args, 1), nil)
+}
+
+func builtinGauge(e *Evaluator, args []Value) (Value, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, &RuntimeError{Message: "gauge expects name and optional labels object"}
+	}
+	return e.metricArg(metricGauge, args[0], optionalArg(args, 1), nil)
+}
+
+// builtinHistogram takes histogram(name, buckets?, labels?). Buckets are
+// upper bounds in increasing order; null or none means the Prometheus
+// defaults.
+func builtinHistogram(e *Evaluator, args []Value) (Value, error) {
+	if len(args) < 1 || len(args) > 3 {
+		return nil, &RuntimeError{Message: "histogram expects name, optional buckets array and optional labels object"}
+	}
+	var buckets []float64
+	if arg := optionalArg(args, 1); arg != nil {
+		arr, ok := arg.(*Array)
+		if !ok {
+			return nil, &RuntimeError{Message: "histogram buckets must be an array of numbers"}
+		}
+		for i, el := range arr.Elements {
+			bound, _, ok := numberArg(el)
+			if !ok {
+				return nil, &RuntimeError{Message: "histogram buckets must be an array of numbers"}
+			}
+			if i > 0 && bound <= buckets[i-1] {
+				return nil, &RuntimeError{Message: "histogram buckets must be increasing"}
+			}
+			buckets = append(buckets, bound)
+		}
+		if len(buckets) == 0 {
+			return nil, &RuntimeError{Message: "histogram buckets must not be empty"}
+		}
+	}
+	return e.metricArg(metricHistogram, args[0], optionalArg(args, 2), buckets)
+}
+
+func optionalArg(args []Value, i int) Value {
+	if i >= len(args) || args[i] == NullValue {
+		return nil
+	}
+	return args[i]
+}
+
+func (e *Evaluator) metricArg(kind string, nameVal Value, labelsVal Value, buckets []float64) (Value, error) {
+	name, ok := stringArg(nameVal)
+	if !ok {
+		return nil, &RuntimeError{Message: kind + " expects string name"}
+	}
+	labels := map[string]string{}
+	if labelsVal != nil {
+		pairs, ok := objectPairs(labelsVal)
+		if !ok {
+			return nil, &RuntimeError{Message: kind + " labels must be an object"}
+		}
+		for k, v := range pairs {
+			labels[k] = formatLogValue(v)
+		}
+	}
+	if e.runtime == nil {
+		e.runtime = newRuntimeState()
+	}
+	m, err := e.runtime.metrics.metric(kind, name, labels, buckets)
+	if err != nil {
+		return nil, &RuntimeError{Message: kind + ": " + err.Error()}
+	}
+	return m, nil
+}
+
+func builtinMetricsText(e *Evaluator, args []Value) (Value, error) {
+	if len(args) != 0 {
+		return nil, &RuntimeError{Message: "metricsText expects no arguments"}
+	}
+	return &String{Value: e.MetricsText()}, nil
+}
+
+func (e *Evaluator) metricMethod(m *Metric, name string) (Value, *Signal, error) {
+	kind := m.family.kind
+	switch {
+	case name == "name":
+		return &String{Value: m.family.name}, nil, nil
+	case name == "labels":
+		pairs := make(map[string]Value, len(m.labels))
+		for _, l := range m.labels {
+			pairs[l.name] = &String{Value: l.value}
+		}
+		return &Object{Pairs: pairs}, nil, nil
+	case name == "value" && kind != metricHistogram:
+		return metricNumber(m.snapshot().value), nil, nil
+	case name == "count" && kind == metricHistogram:
+		return &Integer{Value: int64(m.snapshot().count)}, nil, nil
+	case name == "sum" && kind == metricHistogram:
+		return metricNumber(m.snapshot().sum), nil, nil
+	case name == "inc" && kind != metricHistogram, name == "dec" && kind == metricGauge:
+		return &Builtin{
+			Name: name,
+			Fn: func(_ *Evaluator, args []Value) (Value, error) {
+				delta := 1.0
+				if len(args) > 1 {
+					return nil, &RuntimeError{Message: name + " expects optional amount"}
+				}
+				if len(args) == 1 {
+					n, _, ok := numberArg(args[0])
+					if !ok {
+						return nil, &RuntimeError{Message: name + " expects number"}
+					}
+					delta = n
+				}
+				if kind == metricCounter && delta < 0 {
+					return nil, &RuntimeError{Message: "counter " + m.family.name + " cannot decrease"}
+				}
+				if name == "dec" {
+					delta = -delta
+				}
+				m.add(delta)
+				return UnitValue, nil
+			},
+		}, nil, nil
+	case name == "set" && kind == metricGauge, name == "observe" && kind == metricHistogram:
+		return &Builtin{
+			Name: name,
+			Fn: func(_ *Evaluator, args []Value) (Value, error) {
+				if len(args) != 1 {
+					return nil, &RuntimeError{Message: name + " expects number"}
+				}
+				n, _, ok := numberArg(args[0])
+				if !ok {
+					return nil, &RuntimeError{Message: name + " expects number"}
+				}
+				if name == "set" {
+					m.set(n)
+				} else {
+					m.observe(n)
+				}
+				return UnitValue, nil
+			},
+		}, nil, nil
+	default:
+		return nil, nil, &RuntimeError{Message: "unknown " + kind + " member: " + name}
+	}
+}
+
+// metricNumber shows whole values as integers, so counters read naturally.
+func metricNumber(v float64) Value {
+	if v == float64(int64(v)) && v < 1<<53 && v > -(1<<53) {
+		return &Integer{Value: int64(v)}
+	}
+	return &Float{Value: v}
+}
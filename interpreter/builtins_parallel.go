@@ -0,0 +1,190 @@
+package interpreter
+
+// defaultParallelism is the parallelMap concurrency when none is given. It is
+// a constant rather than the CPU count so deterministic runs replay the same
+// way on every machine.
+const defaultParallelism = 8
+
+type poolJob struct {
+	task *Task
+	eval *Evaluator
+	fn   Value
+	args []Value
+}
+
+func builtinParallelMap(e *Evaluator, args []Value) (Value, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, &RuntimeError{Message: "parallelMap expects array, function and optional options"}
+	}
+	arr, ok := args[0].(*Array)
+	if !ok {
+		return nil, &RuntimeError{Message: "parallelMap expects array as first argument"}
+	}
+	concurrency := defaultParallelism
+	ordered := true
+	if len(args) == 3 {
+		pairs, ok := objectPairs(args[2])
+		if !ok {
+			return nil, &RuntimeError{Message: "parallelMap expects options object"}
+		}
+		if v, ok := pairs["concurrency"]; ok {
+			n, ok := v.(*Integer)
+			if !ok || n.Value < 1 {
+				return nil, &RuntimeError{Message: "parallelMap concurrency must be a positive integer"}
+			}
+			concurrency = int(n.Value)
+		}
+		if v, ok := pairs["ordered"]; ok {
+			b, ok := v.(*Boolean)
+			if !ok {
+				return nil, &RuntimeError{Message: "parallelMap ordered must be a boolean"}
+			}
+			ordered = b.Value
+		}
+	}
+	items := append([]Value(nil), arr.Elements...)
+	if concurrency > len(items) {
+		concurrency = len(items)
+	}
+
+	task := e.newTask(e.currentTask, false)
+	taskEval := e.cloneForTask(task)
+	fn := args[1]
+	e.runtime.goAsync(func() {
+		out, err := taskEval.runParallelMap(task, items, fn, concurrency, ordered)
+		if err != nil {
+			// Fail fast like a spawn group: stop the remaining items and
+			// surface the first error on the parallelMap task.
+			task.cancelChildren()
+			taskEval.handleAsyncError(task, err)
+			return
+		}
+		task.complete(&Array{Elements: out}, nil)
+	})
+	return task, nil
+}
+
+func (e *Evaluator) runParallelMap(task *Task, items []Value, fn Value, concurrency int, ordered bool) ([]Value, error) {
+	reports := make(chan taskReport, concurrency)
+	next := 0
+	running := 0
+	start := func() {
+		child := e.spawnCall(fn, []Value{items[next]}, task, true)
+		e.runtime.awaitChild(task, child, next, reports)
+		next++
+		running++
+	}
+	for running < concurrency && next < len(items) {
+		start()
+	}
+
+	out := make([]Value, 0, len(items))
+	if ordered {
+		out = out[:len(items)]
+	}
+	for running > 0 {
+		report, err := e.runtime.nextReport(task, reports)
+		if err != nil {
+			return nil, err
+		}
+		running--
+		if report.err != nil {
+			return nil, report.err
+		}
+		if ordered {
+			out[report.idx] = report.value
+		} else {
+			out = append(out, report.value)
+		}
+		if next < len(items) {
+			start()
+		}
+	}
+	return out, nil
+}
+
+func builtinPool(_ *Evaluator, args []Value) (Value, error) {
+	if len(args) != 1 {
+		return nil, &RuntimeError{Message: "pool expects 1 argument (size)"}
+	}
+	size, ok := args[0].(*Integer)
+	if !ok || size.Value < 1 {
+		return nil, &RuntimeError{Message: "pool expects positive integer size"}
+	}
+	return &Pool{size: int(size.Value), tasks: make(map[*Task]struct{})}, nil
+}
+
+// builtinPoolSubmit creates the task right away, as a child of the submitting
+// task, so it can be awaited or canceled while it is still queued.
+func builtinPoolSubmit(e *Evaluator, args []Value) (Value, error) {
+	if len(args) < 2 {
+		return nil, &RuntimeError{Message: "submit expects a function"}
+	}
+	p, ok := args[0].(*Pool)
+	if !ok {
+		return nil, &RuntimeError{Message: "submit expects pool as receiver"}
+	}
+	task := e.newTask(e.currentTask, false)
+	job := &poolJob{
+		task: task,
+		eval: e.cloneForTask(task),
+		fn:   args[1],
+		args: append([]Value(nil), args[2:]...),
+	}
+
+	p.mu.Lock()
+	p.tasks[task] = struct{}{}
+	if p.running >= p.size {
+		p.queue = append(p.queue, job)
+		p.mu.Unlock()
+		return task, nil
+	}
+	p.running++
+	p.mu.Unlock()
+	p.start(e.runtime, job)
+	return task, nil
+}
+
+func (p *Pool) start(runtime *runtimeState, job *poolJob) {
+	runtime.goAsync(func() {
+		if !job.task.isDone() {
+			job.eval.completeWithCall(job.task, job.fn, job.args)
+		}
+		p.release(runtime, job.task)
+	})
+}
+
+// release hands the finished job's slot to the next queued task that has not
+// been canceled in the meantime.
+func (p *Pool) release(runtime *runtimeState, finished *Task) {
+	p.mu.Lock()
+	delete(p.tasks, finished)
+	for len(p.queue) > 0 {
+		job := p.queue[0]
+		p.queue[0] = nil
+		p.queue = p.queue[1:]
+		if job.task.isDone() {
+			delete(p.tasks, job.task)
+			continue
+		}
+		p.mu.Unlock()
+		p.start(runtime, job)
+		return
+	}
+	p.running--
+	p.mu.Unlock()
+}
+
+// Cancel cancels every queued and running task of the pool. The pool stays
+// usable for later submissions.
+func (p *Pool) Cancel() {
+	p.mu.Lock()
+	tasks := make([]*Task, 0, len(p.tasks))
+	for t := range p.tasks {
+		tasks = append(tasks, t)
+	}
+	p.mu.Unlock()
+	for _, t := range tasks {
+		t.Cancel()
+	}
+}
@@ -4,4 +4,6 @@ func registerRuntimeBuiltins() {
 	registerRuntimeCoreBuiltins()
 	registerRuntimeUtilityBuiltins()
 	registerRuntimeSystemBuiltins()
+	registerShutdownBuiltins()
+	registerContextBuiltins()
 }
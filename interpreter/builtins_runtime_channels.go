@@ -1,10 +1,10 @@
 package interpreter
 
-func builtinChannel(_ *Evaluator, _ []Value) (Value, error) {
-	return &Channel{Ch: make(chan Value)}, nil
+func builtinChannel(e *Evaluator, _ []Value) (Value, error) {
+	return newChannel(e, 0), nil
 }
 
-func builtinBufferedChannel(_ *Evaluator, args []Value) (Value, error) {
+func builtinBufferedChannel(e *Evaluator, args []Value) (Value, error) {
 	if len(args) != 1 {
 		return nil, &RuntimeError{Message: "buffered expects 1 argument (buffer size)"}
 	}
@@ -18,5 +18,12 @@ func builtinBufferedChannel(_ *Evaluator, args []Value) (Value, error) {
 	if size.Value > 1000000 {
 		return nil, &RuntimeError{Message: "buffered buffer size too large (max 1000000)"}
 	}
-	return &Channel{Ch: make(chan Value, size.Value)}, nil
+	return newChannel(e, int(size.Value)), nil
+}
+
+func newChannel(e *Evaluator, size int) *Channel {
+	if size == 0 && runtimeScheduler(e) != nil {
+		return &Channel{Ch: make(chan Value, 1), closedCh: make(chan struct{}), rendezvous: true}
+	}
+	return &Channel{Ch: make(chan Value, size), closedCh: make(chan struct{})}
 }
@@ -29,6 +29,13 @@ func runtimeCancelSignal(e *Evaluator) <-chan struct{} {
 	return e.currentTask.cancelCh
 }
 
+func runtimeScheduler(e *Evaluator) *scheduler {
+	if e == nil || e.runtime == nil {
+		return nil
+	}
+	return e.runtime.scheduler()
+}
+
 func runtimeFatalError(e *Evaluator) error {
 	if e != nil && e.runtime != nil {
 		if err := e.runtime.getFatalTaskFailure(); err != nil {
@@ -38,26 +45,54 @@ func runtimeFatalError(e *Evaluator) error {
 	return &RuntimeError{Message: "runtime terminated"}
 }
 
+// builtinExit stops the whole program: exit() with status 0, exit(code),
+// exit(message) with status 1, or exit(message, code). The ExitError unwinds
+// the evaluation and, raised from a task, terminates every other task at its
+// next safepoint; the embedder (the CLI) runs atExit hooks and exits.
 func builtinExit(_ *Evaluator, args []Value) (Value, error) {
-	msg := ""
+	if len(args) > 2 {
+		return nil, &RuntimeError{Message: "exit expects optional message and code"}
+	}
+	exit := &ExitError{}
 	if len(args) > 0 {
-		msg = args[0].Inspect()
+		if n, ok := args[0].(*Integer); ok && len(args) == 1 {
+			exit.Code = int(n.Value)
+			if n.Value < 0 || n.Value > 255 {
+				return nil, &RuntimeError{Message: "exit code must be between 0 and 255"}
+			}
+			return nil, exit
+		}
+		if s, ok := args[0].(*String); ok {
+			exit.Message = s.Value
+		} else {
+			exit.Message = args[0].Inspect()
+		}
+		exit.Code = 1
 	}
-	exitProcess(msg)
-	return nil, &ExitError{Message: msg}
+	if len(args) == 2 {
+		n, ok := args[1].(*Integer)
+		if !ok || n.Value < 0 || n.Value > 255 {
+			return nil, &RuntimeError{Message: "exit code must be between 0 and 255"}
+		}
+		exit.Code = int(n.Value)
+	}
+	return nil, exit
 }
 
-func builtinFail(_ *Evaluator, args []Value) (Value, error) {
+func builtinFail(e *Evaluator, args []Value) (Value, error) {
 	if len(args) > 1 {
 		return nil, &RuntimeError{Message: "fail expects 0 or 1 argument"}
 	}
 	msg := ""
 	if len(args) == 1 {
-		s, ok := args[0].(*String)
-		if !ok {
+		switch s := args[0].(type) {
+		case *String:
+			msg = s.Value
+		case *Secret:
+			msg = e.secretText(s)
+		default:
 			return nil, &RuntimeError{Message: "fail expects string message"}
 		}
-		msg = s.Value
 	}
 	return nil, recoverableError("fail", msg)
 }
@@ -71,25 +106,47 @@ func builtinSleep(e *Evaluator, args []Value) (Value, error) {
 		return nil, &RuntimeError{Message: "sleep expects integer milliseconds"}
 	}
 
-	d := time.Duration(ms.Value) * time.Millisecond
-	if d <= 0 {
-		return UnitValue, nil
-	}
 	fatalCh := runtimeFatalSignal(e)
 	cancelCh := runtimeCancelSignal(e)
-	if cancelCh == nil && fatalCh == nil {
-		time.Sleep(d)
+	defer e.waitSpan("sleep", "sleep", map[string]interface{}{"ms": ms.Value})()
+	if runtimeScheduler(e) != nil {
+		if err := e.runtime.sleepVirtual(ms.Value, func() bool {
+			return isClosed(cancelCh) || isClosed(fatalCh)
+		}); err != nil {
+			return nil, err
+		}
+		if err := e.runtime.interruptedError(cancelCh, fatalCh); err != nil {
+			return nil, err
+		}
 		return UnitValue, nil
 	}
 
-	timer := time.NewTimer(d)
-	defer timer.Stop()
-	select {
-	case <-timer.C:
+	d := time.Duration(ms.Value) * time.Millisecond
+	if d <= 0 {
+		if e.holdsLock {
+			e.runtime.yieldLock()
+		}
 		return UnitValue, nil
-	case <-cancelCh:
-		return nil, canceledError()
-	case <-fatalCh:
-		return nil, runtimeFatalError(e)
 	}
+
+	var err error
+	e.blocking(func() {
+		if cancelCh == nil && fatalCh == nil {
+			time.Sleep(d)
+			return
+		}
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-cancelCh:
+			err = canceledError()
+		case <-fatalCh:
+			err = runtimeFatalError(e)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return UnitValue, nil
 }
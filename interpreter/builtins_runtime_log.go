@@ -5,12 +5,12 @@ import (
 	"strings"
 )
 
-func builtinLog(_ *Evaluator, args []Value) (Value, error) {
+func builtinLog(e *Evaluator, args []Value) (Value, error) {
 	parts := make([]string, len(args))
 	for i, arg := range args {
 		parts[i] = formatLogValue(arg)
 	}
-	fmt.Println(strings.Join(parts, " "))
+	fmt.Println(strings.Join(parts, " ") + e.logContextSuffix())
 	return UnitValue, nil
 }
 
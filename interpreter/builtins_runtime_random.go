@@ -5,14 +5,37 @@ import (
 	"math/rand"
 )
 
-func builtinRand(_ *Evaluator, args []Value) (Value, error) {
+// randSource is satisfied by *rand.Rand; globalRand forwards to the shared
+// math/rand source used outside deterministic mode.
+type randSource interface {
+	Int63() int64
+	Int63n(n int64) int64
+	Float64() float64
+}
+
+type globalRand struct{}
+
+func (globalRand) Int63() int64         { return rand.Int63() }
+func (globalRand) Int63n(n int64) int64 { return rand.Int63n(n) }
+func (globalRand) Float64() float64     { return rand.Float64() }
+
+func runtimeRand(e *Evaluator) randSource {
+	if e != nil && e.runtime != nil {
+		if rng := e.runtime.seededRand(); rng != nil {
+			return rng
+		}
+	}
+	return globalRand{}
+}
+
+func builtinRand(e *Evaluator, args []Value) (Value, error) {
 	if len(args) != 0 {
 		return nil, &RuntimeError{Message: "rand expects no arguments"}
 	}
-	return &Integer{Value: rand.Int63()}, nil
+	return &Integer{Value: runtimeRand(e).Int63()}, nil
 }
 
-func builtinRandInt(_ *Evaluator, args []Value) (Value, error) {
+func builtinRandInt(e *Evaluator, args []Value) (Value, error) {
 	if len(args) != 2 {
 		return nil, &RuntimeError{Message: "randInt expects min and max"}
 	}
@@ -34,11 +57,11 @@ func builtinRandInt(_ *Evaluator, args []Value) (Value, error) {
 	if diff < 0 || diff == math.MaxInt64 {
 		return nil, &RuntimeError{Message: "randInt range too large"}
 	}
-	n := rand.Int63n(diff+1) + min.Value
+	n := runtimeRand(e).Int63n(diff+1) + min.Value
 	return &Integer{Value: n}, nil
 }
 
-func builtinRandFloat(_ *Evaluator, args []Value) (Value, error) {
+func builtinRandFloat(e *Evaluator, args []Value) (Value, error) {
 	if len(args) != 2 {
 		return nil, &RuntimeError{Message: "randFloat expects min and max"}
 	}
@@ -53,5 +76,5 @@ func builtinRandFloat(_ *Evaluator, args []Value) (Value, error) {
 	if max < min {
 		return nil, &RuntimeError{Message: "randFloat expects min <= max"}
 	}
-	return &Float{Value: min + rand.Float64()*(max-min)}, nil
+	return &Float{Value: min + runtimeRand(e).Float64()*(max-min)}, nil
 }
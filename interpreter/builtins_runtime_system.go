@@ -106,5 +106,11 @@ func runtimeReadLine(e *Evaluator) (string, bool, error) {
 	if e == nil || e.runtime == nil {
 		return "", false, nil
 	}
-	return e.runtime.readLine()
+	var line string
+	var ok bool
+	var err error
+	e.blocking(func() {
+		line, ok, err = e.runtime.readLine()
+	})
+	return line, ok, err
 }
@@ -28,9 +28,12 @@ func builtinParseInt(_ *Evaluator, args []Value) (Value, error) {
 	return &Integer{Value: n}, nil
 }
 
-func builtinNow(_ *Evaluator, args []Value) (Value, error) {
+func builtinNow(e *Evaluator, args []Value) (Value, error) {
 	if len(args) != 0 {
 		return nil, &RuntimeError{Message: "now expects no arguments"}
 	}
-	return &Integer{Value: time.Now().UnixNano() / int64(time.Millisecond)}, nil
+	if e == nil || e.runtime == nil {
+		return &Integer{Value: time.Now().UnixNano() / int64(time.Millisecond)}, nil
+	}
+	return &Integer{Value: e.runtime.nowMillis()}, nil
 }
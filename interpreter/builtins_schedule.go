@@ -0,0 +1,465 @@
+package interpreter
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	overlapSkip  = "skip"
+	overlapQueue = "queue"
+	overlapAllow = "allow"
+
+	missedSkip    = "skip"
+	missedCatchUp = "catchUp"
+
+	// maxCatchUpTicks bounds how many missed ticks one wake-up fires.
+	maxCatchUpTicks = 1000
+)
+
+// Schedule runs a function on a timer: once (after), at a fixed interval
+// (every) or on a cron expression (cron). The schedule lives on its own task,
+// so canceling it, or any of its ancestors, stops future runs and cancels the
+// runs in flight. Runs are internal child tasks of that task.
+type Schedule struct {
+	eval *Evaluator
+	task *Task
+	kind string
+	fn   Value
+
+	// due returns the first due time strictly after the given one, in epoch
+	// milliseconds, or false when the schedule has no further runs.
+	due func(after int64) (int64, bool)
+
+	overlap string
+	missed  string
+	limit   int64
+	oneShot bool
+
+	// Bookkeeping below is only touched while holding the interpreter lock
+	// (or the deterministic scheduler baton).
+	nextDue  int64
+	hasNext  bool
+	started  int64
+	skipped  int64
+	dropped  int64
+	queued   int64
+	running  map[*Task]struct{}
+	finished []*Task
+	last     Value
+
+	// wake is signaled after a run appends itself to finished.
+	wake chan struct{}
+}
+
+func (s *Schedule) Type() ValueType { return SCHEDULE }
+func (s *Schedule) Inspect() string {
+	return fmt.Sprintf("<schedule %s %s>", s.kind, s.task.status())
+}
+
+func builtinEvery(e *Evaluator, args []Value) (Value, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, &RuntimeError{Message: "every expects duration, function and optional options"}
+	}
+	interval, err := durationArg("every", args[0])
+	if err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		return nil, &RuntimeError{Message: "every expects a positive duration"}
+	}
+	s, err := newSchedule(e, fmt.Sprintf("every %dms", interval), args[1], args[2:])
+	if err != nil {
+		return nil, err
+	}
+	s.due = func(after int64) (int64, bool) { return after + interval, true }
+	return s.start(e.runtime.nowMillis()), nil
+}
+
+func builtinAfter(e *Evaluator, args []Value) (Value, error) {
+	if len(args) != 2 {
+		return nil, &RuntimeError{Message: "after expects duration and function"}
+	}
+	delay, err := durationArg("after", args[0])
+	if err != nil {
+		return nil, err
+	}
+	if delay < 0 {
+		return nil, &RuntimeError{Message: "after expects a non-negative duration"}
+	}
+	s, err := newSchedule(e, fmt.Sprintf("after %dms", delay), args[1], nil)
+	if err != nil {
+		return nil, err
+	}
+	s.limit = 1
+	s.oneShot = true
+	start := e.runtime.nowMillis()
+	s.due = func(after int64) (int64, bool) { return start + delay, after < start+delay }
+	return s.start(start - 1), nil
+}
+
+func builtinCron(e *Evaluator, args []Value) (Value, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, &RuntimeError{Message: "cron expects expression, function and optional options"}
+	}
+	expr, ok := stringArg(args[0])
+	if !ok {
+		return nil, &RuntimeError{Message: "cron expects expression string"}
+	}
+	loc := time.Local
+	if len(args) == 3 {
+		if pairs, ok := objectPairs(args[2]); ok {
+			if v, ok := pairs["timezone"]; ok {
+				name, ok := stringArg(v)
+				if !ok {
+					return nil, &RuntimeError{Message: "cron timezone must be a string"}
+				}
+				l, err := time.LoadLocation(name)
+				if err != nil {
+					return nil, &RuntimeError{Message: "cron unknown timezone: " + name}
+				}
+				loc = l
+			}
+		}
+	}
+	spec, err := parseCron(expr, loc)
+	if err != nil {
+		return nil, &RuntimeError{Message: err.Error()}
+	}
+	s, err := newSchedule(e, "cron "+expr, args[1], args[2:])
+	if err != nil {
+		return nil, err
+	}
+	s.due = func(after int64) (int64, bool) {
+		t, ok := spec.next(time.UnixMilli(after))
+		if !ok {
+			return 0, false
+		}
+		return t.UnixMilli(), true
+	}
+	return s.start(e.runtime.nowMillis()), nil
+}
+
+// durationArg accepts integer milliseconds or a Go-style duration string
+// such as "250ms", "5s" or "1h30m".
+func durationArg(name string, val Value) (int64, error) {
+	switch v := val.(type) {
+	case *Integer:
+		return v.Value, nil
+	case *String:
+		d, err := time.ParseDuration(v.Value)
+		if err != nil {
+			return 0, &RuntimeError{Message: name + " invalid duration: " + v.Value}
+		}
+		return d.Milliseconds(), nil
+	default:
+		return 0, &RuntimeError{Message: name + " expects duration as integer milliseconds or string"}
+	}
+}
+
+func newSchedule(e *Evaluator, kind string, fn Value, opts []Value) (*Schedule, error) {
+	if !isCallable(fn) {
+		return nil, &RuntimeError{Message: kind + " expects a function"}
+	}
+	s := &Schedule{
+		eval:    e,
+		kind:    kind,
+		fn:      fn,
+		overlap: overlapSkip,
+		missed:  missedSkip,
+		running: map[*Task]struct{}{},
+		wake:    make(chan struct{}, 1),
+	}
+	if len(opts) == 0 {
+		return s, nil
+	}
+	pairs, ok := objectPairs(opts[0])
+	if !ok {
+		return nil, &RuntimeError{Message: "schedule options must be an object"}
+	}
+	if v, ok := pairs["overlap"]; ok {
+		o, ok := stringArg(v)
+		if !ok || (o != overlapSkip && o != overlapQueue && o != overlapAllow) {
+			return nil, &RuntimeError{Message: "schedule overlap must be \"skip\", \"queue\" or \"allow\""}
+		}
+		s.overlap = o
+	}
+	if v, ok := pairs["missed"]; ok {
+		m, ok := stringArg(v)
+		if !ok || (m != missedSkip && m != missedCatchUp) {
+			return nil, &RuntimeError{Message: "schedule missed must be \"skip\" or \"catchUp\""}
+		}
+		s.missed = m
+	}
+	if v, ok := pairs["limit"]; ok {
+		n, ok := v.(*Integer)
+		if !ok || n.Value <= 0 {
+			return nil, &RuntimeError{Message: "schedule limit must be a positive integer"}
+		}
+		s.limit = n.Value
+	}
+	if v, ok := pairs["name"]; ok {
+		name, ok := stringArg(v)
+		if !ok {
+			return nil, &RuntimeError{Message: "schedule name must be a string"}
+		}
+		s.kind = name
+	}
+	return s, nil
+}
+
+// start creates the schedule task below the current task and begins waiting
+// for the first due time after from.
+func (s *Schedule) start(from int64) *Schedule {
+	e := s.eval
+	s.task = e.newNamedTask(e.currentTask, false, s.kind)
+	s.eval = e.cloneForTask(s.task)
+	s.nextDue, s.hasNext = s.due(from)
+	e.runtime.scheduleStarted()
+	e.runtime.goAsync(s.run)
+	return s
+}
+
+func (s *Schedule) run() {
+	defer s.eval.runtime.scheduleStopped()
+	for {
+		if err := s.collect(); err != nil {
+			s.stop(err)
+			return
+		}
+		if s.exhausted() {
+			if len(s.running) == 0 && s.queued == 0 {
+				s.finish()
+				return
+			}
+			if err := s.wait(0, false); err != nil {
+				s.stop(err)
+				return
+			}
+			continue
+		}
+		now := s.eval.runtime.nowMillis()
+		if now < s.nextDue {
+			if err := s.wait(s.nextDue, true); err != nil {
+				s.stop(err)
+				return
+			}
+			continue
+		}
+		s.fire(s.dueTicks(now))
+	}
+}
+
+// dueTicks advances nextDue past now and returns how many ticks to fire.
+// Ticks older than the most recent one are missed: they are dropped, or with
+// missed: "catchUp" fired back to back.
+func (s *Schedule) dueTicks(now int64) int64 {
+	var ticks int64
+	for s.hasNext && s.nextDue <= now {
+		if ticks == maxCatchUpTicks {
+			// Far behind (a suspended machine, say): stop walking missed
+			// ticks and realign on now.
+			s.nextDue, s.hasNext = s.due(now)
+			break
+		}
+		ticks++
+		s.nextDue, s.hasNext = s.due(s.nextDue)
+	}
+	if ticks > 1 && s.missed == missedSkip {
+		s.dropped += ticks - 1
+		ticks = 1
+	}
+	return ticks
+}
+
+func (s *Schedule) fire(ticks int64) {
+	for ; ticks > 0 && !s.limitReached(); ticks-- {
+		if len(s.running) == 0 || s.overlap == overlapAllow {
+			s.launch()
+			continue
+		}
+		if s.overlap == overlapQueue {
+			s.queued++
+			continue
+		}
+		s.skipped++
+	}
+}
+
+func (s *Schedule) launch() {
+	s.started++
+	run := s.eval.newTask(s.task, true)
+	runEval := s.eval.cloneForTask(run)
+	s.running[run] = struct{}{}
+	s.eval.runtime.goAsync(func() {
+		runEval.completeWithCall(run, s.fn, nil)
+		s.finished = append(s.finished, run)
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	})
+}
+
+// collect settles finished runs. A failed run stops the schedule and fails
+// its task with the run's error; queued ticks start as soon as the previous
+// run is done.
+func (s *Schedule) collect() error {
+	finished := s.finished
+	s.finished = nil
+	for _, run := range finished {
+		delete(s.running, run)
+		val, _, err := taskAwaitWithCancel(run, nil, s.eval.runtime)
+		if err != nil {
+			return err
+		}
+		s.last = val
+	}
+	if s.queued > 0 && len(s.running) == 0 {
+		s.queued--
+		s.launch()
+	}
+	return nil
+}
+
+func (s *Schedule) limitReached() bool {
+	return s.limit > 0 && s.started+s.queued >= s.limit
+}
+
+func (s *Schedule) exhausted() bool {
+	return !s.hasNext || s.limitReached()
+}
+
+// wait blocks until deadline (when timed), a run finishes, the schedule is
+// canceled or the runtime fails.
+func (s *Schedule) wait(deadline int64, timed bool) error {
+	r := s.eval.runtime
+	fatalCh := r.fatalSignal()
+	ready := func() bool {
+		return s.task.canceled() || isClosed(fatalCh) || len(s.finished) > 0
+	}
+	if r.scheduler() != nil {
+		var err error
+		if timed {
+			err = r.sleepVirtual(deadline-r.nowMillis(), ready)
+		} else {
+			err = r.park(ready)
+		}
+		if err != nil {
+			return err
+		}
+		return r.interruptedError(s.task.cancelCh, fatalCh)
+	}
+	if ready() {
+		return r.interruptedError(s.task.cancelCh, fatalCh)
+	}
+	var timerC <-chan time.Time
+	if timed {
+		timer := time.NewTimer(time.Duration(deadline-r.nowMillis()) * time.Millisecond)
+		defer timer.Stop()
+		timerC = timer.C
+	}
+	r.blocking(func() {
+		select {
+		case <-timerC:
+		case <-s.wake:
+		case <-s.task.cancelCh:
+		case <-fatalCh:
+		}
+	})
+	return r.interruptedError(s.task.cancelCh, fatalCh)
+}
+
+// finish completes the schedule task: after() yields the result of its run,
+// every() and cron() yield the number of runs.
+func (s *Schedule) finish() {
+	if s.oneShot {
+		s.task.complete(s.last, nil)
+		return
+	}
+	s.task.complete(&Integer{Value: s.started}, nil)
+}
+
+func (s *Schedule) stop(err error) {
+	s.task.cancelChildren()
+	s.eval.handleAsyncError(s.task, err)
+}
+
+func (s *Schedule) stats() Value {
+	next := Value(NullValue)
+	if s.hasNext && !s.limitReached() && !s.task.isDone() {
+		next = &Integer{Value: s.nextDue}
+	}
+	return &Object{Pairs: map[string]Value{
+		"runs":    &Integer{Value: s.started},
+		"running": &Integer{Value: int64(len(s.running))},
+		"queued":  &Integer{Value: s.queued},
+		"skipped": &Integer{Value: s.skipped},
+		"missed":  &Integer{Value: s.dropped},
+		"next":    next,
+	}}
+}
+
+func (r *runtimeState) scheduleStarted() {
+	r.scheduleMu.Lock()
+	r.liveSchedules++
+	r.scheduleMu.Unlock()
+}
+
+func (r *runtimeState) scheduleStopped() {
+	r.scheduleMu.Lock()
+	r.liveSchedules--
+	if r.liveSchedules == 0 && r.schedulesIdle != nil {
+		close(r.schedulesIdle)
+		r.schedulesIdle = nil
+	}
+	r.scheduleMu.Unlock()
+}
+
+// idleSchedules returns nil when no schedule is live, or a channel that is
+// closed once the last one stops.
+func (r *runtimeState) idleSchedules() <-chan struct{} {
+	r.scheduleMu.Lock()
+	defer r.scheduleMu.Unlock()
+	if r.liveSchedules == 0 {
+		return nil
+	}
+	if r.schedulesIdle == nil {
+		r.schedulesIdle = make(chan struct{})
+	}
+	return r.schedulesIdle
+}
+
+// WaitForSchedules blocks until every every/cron/after schedule has finished
+// or been canceled. `karl run --daemon` calls it after the program body so
+// the process stays up while schedules exist. It returns early with the
+// runtime failure when a task fails under the fail-fast policy.
+func (e *Evaluator) WaitForSchedules() error {
+	r := e.runtime
+	if r == nil {
+		return nil
+	}
+	fatalCh := r.fatalSignal()
+	if r.scheduler() != nil {
+		if err := r.park(func() bool {
+			return isClosed(fatalCh) || r.idleSchedules() == nil
+		}); err != nil {
+			return err
+		}
+		if isClosed(fatalCh) {
+			return r.terminatedError()
+		}
+		return nil
+	}
+	for {
+		idle := r.idleSchedules()
+		if idle == nil {
+			return nil
+		}
+		select {
+		case <-idle:
+		case <-fatalCh:
+			return r.terminatedError()
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package interpreter
+
+import (
+	"os"
+	"strings"
+)
+
+func registerSecretBuiltins() {
+	builtins["secret"] = &Builtin{Name: "secret", Fn: builtinSecret}
+	builtins["secretFile"] = &Builtin{Name: "secretFile", Fn: builtinSecretFile}
+}
+
+func builtinSecret(e *Evaluator, args []Value) (Value, error) {
+	if len(args) != 1 {
+		return nil, &RuntimeError{Message: "secret expects 1 argument"}
+	}
+	switch v := args[0].(type) {
+	case *Secret:
+		return v, nil
+	case *Null:
+		return nil, recoverableError("secret", "secret value is null")
+	}
+	value, ok := stringArg(args[0])
+	if !ok {
+		return nil, &RuntimeError{Message: "secret expects string value"}
+	}
+	return e.newSecret(value), nil
+}
+
+func builtinSecretFile(e *Evaluator, args []Value) (Value, error) {
+	if len(args) != 1 {
+		return nil, &RuntimeError{Message: "secretFile expects path"}
+	}
+	path, ok := stringArg(args[0])
+	if !ok {
+		return nil, &RuntimeError{Message: "secretFile expects string path"}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, recoverableError("secretFile", "secretFile error: "+err.Error())
+	}
+	return e.newSecret(strings.TrimRight(string(data), "\r\n")), nil
+}
@@ -0,0 +1,321 @@
+package interpreter
+
+import (
+	"fmt"
+	"math"
+)
+
+// supervisorSpec is the parsed options object of supervise().
+type supervisorSpec struct {
+	strategy      string
+	maxRestarts   int
+	window        int64
+	backoff       int64
+	backoffMax    int64
+	backoffFactor float64
+	onEvent       Value
+	events        *Channel
+}
+
+// supervisor owns the restart loop of one supervise() call. It runs on its own
+// task; children are internal tasks below it, so canceling the supervisor
+// cancels them and their failures never reach the fail-fast policy directly.
+type supervisor struct {
+	eval     *Evaluator
+	task     *Task
+	spec     supervisorSpec
+	fns      []Value
+	running  []*Task
+	results  []Value
+	restarts []int64
+	total    int64
+
+	// exits receives one report per running child. Every child is reported
+	// before it is started again, so len(fns) slots never fill up.
+	exits chan taskReport
+}
+
+func builtinSupervise(e *Evaluator, args []Value) (Value, error) {
+	if len(args) != 2 {
+		return nil, &RuntimeError{Message: "supervise expects options and array of functions"}
+	}
+	spec, err := parseSupervisorSpec(args[0])
+	if err != nil {
+		return nil, err
+	}
+	children, ok := args[1].(*Array)
+	if !ok {
+		return nil, &RuntimeError{Message: "supervise expects array of functions"}
+	}
+
+	task := e.newTask(e.currentTask, false)
+	sup := &supervisor{
+		eval:    e.cloneForTask(task),
+		task:    task,
+		spec:    spec,
+		fns:     append([]Value(nil), children.Elements...),
+		running: make([]*Task, len(children.Elements)),
+		results: make([]Value, len(children.Elements)),
+		exits:   make(chan taskReport, len(children.Elements)),
+	}
+	e.runtime.goAsync(sup.run)
+	return task, nil
+}
+
+func parseSupervisorSpec(val Value) (supervisorSpec, error) {
+	spec := supervisorSpec{
+		strategy:      "one-for-one",
+		maxRestarts:   3,
+		window:        5000,
+		backoffFactor: 1,
+	}
+	pairs, ok := objectPairs(val)
+	if !ok {
+		return spec, &RuntimeError{Message: "supervise expects options object"}
+	}
+	if v, ok := pairs["strategy"]; ok {
+		s, ok := stringArg(v)
+		if !ok || (s != "one-for-one" && s != "one-for-all") {
+			return spec, &RuntimeError{Message: "supervise strategy must be \"one-for-one\" or \"one-for-all\""}
+		}
+		spec.strategy = s
+	}
+	if v, ok := pairs["maxRestarts"]; ok {
+		n, ok := v.(*Integer)
+		if !ok || n.Value < 0 {
+			return spec, &RuntimeError{Message: "supervise maxRestarts must be a non-negative integer"}
+		}
+		spec.maxRestarts = int(n.Value)
+	}
+	if v, ok := pairs["window"]; ok {
+		n, ok := v.(*Integer)
+		if !ok || n.Value <= 0 {
+			return spec, &RuntimeError{Message: "supervise window must be a positive integer (milliseconds)"}
+		}
+		spec.window = n.Value
+	}
+	if v, ok := pairs["backoff"]; ok {
+		if err := parseSupervisorBackoff(v, &spec); err != nil {
+			return spec, err
+		}
+	}
+	if v, ok := pairs["onEvent"]; ok {
+		switch v.(type) {
+		case *Function, *Builtin, *Partial:
+			spec.onEvent = v
+		default:
+			return spec, &RuntimeError{Message: "supervise onEvent must be a function"}
+		}
+	}
+	if v, ok := pairs["events"]; ok {
+		ch, ok := v.(*Channel)
+		if !ok {
+			return spec, &RuntimeError{Message: "supervise events must be a channel"}
+		}
+		spec.events = ch
+	}
+	return spec, nil
+}
+
+// parseSupervisorBackoff accepts either a fixed delay in milliseconds or
+// { initial, factor, max } for exponential backoff.
+func parseSupervisorBackoff(val Value, spec *supervisorSpec) error {
+	if n, ok := val.(*Integer); ok {
+		if n.Value < 0 {
+			return &RuntimeError{Message: "supervise backoff must be non-negative"}
+		}
+		spec.backoff = n.Value
+		return nil
+	}
+	pairs, ok := objectPairs(val)
+	if !ok {
+		return &RuntimeError{Message: "supervise backoff must be milliseconds or { initial, factor, max }"}
+	}
+	spec.backoffFactor = 2
+	if v, ok := pairs["initial"]; ok {
+		n, ok := v.(*Integer)
+		if !ok || n.Value < 0 {
+			return &RuntimeError{Message: "supervise backoff initial must be a non-negative integer"}
+		}
+		spec.backoff = n.Value
+	}
+	if v, ok := pairs["factor"]; ok {
+		f, _, ok := numberArg(v)
+		if !ok || f < 1 {
+			return &RuntimeError{Message: "supervise backoff factor must be a number >= 1"}
+		}
+		spec.backoffFactor = f
+	}
+	if v, ok := pairs["max"]; ok {
+		n, ok := v.(*Integer)
+		if !ok || n.Value < 0 {
+			return &RuntimeError{Message: "supervise backoff max must be a non-negative integer"}
+		}
+		spec.backoffMax = n.Value
+	}
+	return nil
+}
+
+func (s *supervisor) run() {
+	for i := range s.fns {
+		s.start(i)
+	}
+	remaining := len(s.fns)
+	for remaining > 0 {
+		exit, err := s.eval.runtime.nextReport(s.task, s.exits)
+		if err != nil {
+			s.stop(err)
+			return
+		}
+		s.running[exit.idx] = nil
+		if exit.err == nil {
+			s.results[exit.idx] = exit.value
+			remaining--
+			if err := s.emit("completed", exit.idx, nil); err != nil {
+				s.stop(err)
+				return
+			}
+			continue
+		}
+
+		if err := s.emit("failed", exit.idx, exit.err); err != nil {
+			s.stop(err)
+			return
+		}
+		restart := []int{exit.idx}
+		if s.spec.strategy == "one-for-all" {
+			if err := s.stopSiblings(); err != nil {
+				s.stop(err)
+				return
+			}
+			restart = restart[:0]
+			for i := range s.fns {
+				s.results[i] = nil
+				restart = append(restart, i)
+			}
+			remaining = len(s.fns)
+		}
+
+		delay, ok := s.allowRestart()
+		if !ok {
+			s.escalate(exit.err)
+			return
+		}
+		if delay > 0 {
+			if _, err := builtinSleep(s.eval, []Value{&Integer{Value: delay}}); err != nil {
+				s.stop(err)
+				return
+			}
+		}
+		for _, i := range restart {
+			s.start(i)
+			if err := s.emit("restarted", i, nil); err != nil {
+				s.stop(err)
+				return
+			}
+		}
+	}
+	s.task.complete(&Array{Elements: s.results}, nil)
+}
+
+func (s *supervisor) start(idx int) {
+	child := s.eval.spawnCall(s.fns[idx], nil, s.task, true)
+	s.running[idx] = child
+	s.eval.runtime.awaitChild(s.task, child, idx, s.exits)
+}
+
+// stopSiblings cancels every running child and waits for their reports, so
+// one-for-all restarts always begin from a clean slate.
+func (s *supervisor) stopSiblings() error {
+	pending := 0
+	for _, child := range s.running {
+		if child != nil {
+			child.Cancel()
+			pending++
+		}
+	}
+	for pending > 0 {
+		exit, err := s.eval.runtime.nextReport(s.task, s.exits)
+		if err != nil {
+			return err
+		}
+		s.running[exit.idx] = nil
+		pending--
+	}
+	return nil
+}
+
+// allowRestart records a restart unless maxRestarts restarts already happened
+// within the window, and returns the backoff delay to apply before it.
+func (s *supervisor) allowRestart() (int64, bool) {
+	now := s.eval.runtime.nowMillis()
+	kept := s.restarts[:0]
+	for _, at := range s.restarts {
+		if now-at < s.spec.window {
+			kept = append(kept, at)
+		}
+	}
+	s.restarts = kept
+	if len(s.restarts) >= s.spec.maxRestarts {
+		return 0, false
+	}
+	delay := float64(s.spec.backoff) * math.Pow(s.spec.backoffFactor, float64(len(s.restarts)))
+	s.restarts = append(s.restarts, now)
+	s.total++
+	if s.spec.backoffMax > 0 && delay > float64(s.spec.backoffMax) {
+		delay = float64(s.spec.backoffMax)
+	}
+	if delay > math.MaxInt32 {
+		delay = math.MaxInt32
+	}
+	return int64(delay), true
+}
+
+func (s *supervisor) escalate(cause error) {
+	err := &RecoverableError{
+		Kind: "supervisor",
+		Message: fmt.Sprintf(
+			"supervisor gave up after %d restarts in %dms: %s",
+			s.spec.maxRestarts,
+			s.spec.window,
+			cause.Error(),
+		),
+	}
+	if emitErr := s.emit("escalated", -1, cause); emitErr != nil {
+		s.stop(emitErr)
+		return
+	}
+	s.stop(err)
+}
+
+// stop cancels the remaining children and fails the supervisor task. An
+// unobserved supervisor then falls under the task failure policy like any
+// other task, which is how escalation reaches the parent.
+func (s *supervisor) stop(err error) {
+	s.task.cancelChildren()
+	s.eval.handleAsyncError(s.task, err)
+}
+
+// emit reports a lifecycle event to the onEvent callback or events channel.
+func (s *supervisor) emit(kind string, idx int, cause error) error {
+	if s.spec.onEvent == nil && s.spec.events == nil {
+		return nil
+	}
+	pairs := map[string]Value{
+		"event":    &String{Value: kind},
+		"restarts": &Integer{Value: s.total},
+	}
+	if idx >= 0 {
+		pairs["child"] = &Integer{Value: int64(idx)}
+	}
+	if cause != nil {
+		pairs["error"] = errorValue(cause)
+	}
+	event := &Object{Pairs: pairs}
+	if s.spec.onEvent != nil {
+		_, _, err := s.eval.applyFunction(s.spec.onEvent, []Value{event})
+		return err
+	}
+	_, err := builtinSend(s.eval, []Value{s.spec.events, event})
+	return err
+}
@@ -0,0 +1,135 @@
+package interpreter
+
+func registerSyncBuiltins() {
+	builtins["mutex"] = &Builtin{Name: "mutex", Fn: builtinMutex}
+	builtins["semaphore"] = &Builtin{Name: "semaphore", Fn: builtinSemaphore}
+	builtins["atomic"] = &Builtin{Name: "atomic", Fn: builtinAtomic}
+}
+
+func builtinMutex(_ *Evaluator, args []Value) (Value, error) {
+	if len(args) != 0 {
+		return nil, &RuntimeError{Message: "mutex expects no arguments"}
+	}
+	return &Mutex{permits: newPermits(1)}, nil
+}
+
+func builtinSemaphore(_ *Evaluator, args []Value) (Value, error) {
+	if len(args) != 1 {
+		return nil, &RuntimeError{Message: "semaphore expects 1 argument (permits)"}
+	}
+	n, ok := args[0].(*Integer)
+	if !ok || n.Value < 1 {
+		return nil, &RuntimeError{Message: "semaphore expects positive integer permits"}
+	}
+	if n.Value > 1000000 {
+		return nil, &RuntimeError{Message: "semaphore permits too large (max 1000000)"}
+	}
+	return &Semaphore{permits: newPermits(int(n.Value))}, nil
+}
+
+func builtinAtomic(_ *Evaluator, args []Value) (Value, error) {
+	if len(args) != 1 {
+		return nil, &RuntimeError{Message: "atomic expects 1 argument (initial value)"}
+	}
+	return &Atomic{value: args[0], guard: newPermits(1)}, nil
+}
+
+// takePermit blocks until p has a free permit. Like other yield points it
+// gives up when the current task is canceled or the runtime shuts down.
+func takePermit(e *Evaluator, p *permits) error {
+	fatalCh := runtimeFatalSignal(e)
+	cancelCh := runtimeCancelSignal(e)
+	for {
+		if err := e.runtime.interruptedError(cancelCh, fatalCh); err != nil {
+			return err
+		}
+		if p.tryTake() {
+			return nil
+		}
+		if runtimeScheduler(e) != nil {
+			if err := e.runtime.park(func() bool {
+				return p.free() > 0 || isClosed(cancelCh) || isClosed(fatalCh)
+			}); err != nil {
+				return err
+			}
+			continue
+		}
+		p.mu.Lock()
+		changed := p.changed
+		free := p.available
+		p.mu.Unlock()
+		if free > 0 {
+			continue
+		}
+		e.blocking(func() {
+			select {
+			case <-changed:
+			case <-cancelCh:
+			case <-fatalCh:
+			}
+		})
+	}
+}
+
+// withPermit runs fn while holding a permit and always gives it back, even
+// when fn fails.
+func withPermit(e *Evaluator, p *permits, fn Value, args []Value) (Value, error) {
+	if err := takePermit(e, p); err != nil {
+		return nil, err
+	}
+	defer p.put()
+	val, _, err := e.applyFunction(fn, args)
+	return val, err
+}
+
+func atomicUpdate(e *Evaluator, a *Atomic, fn Value) (Value, error) {
+	if err := takePermit(e, a.guard); err != nil {
+		return nil, err
+	}
+	defer a.guard.put()
+	val, _, err := e.applyFunction(fn, []Value{a.value})
+	if err != nil {
+		return nil, err
+	}
+	a.value = val
+	return val, nil
+}
+
+func atomicAdd(e *Evaluator, a *Atomic, delta Value) (Value, error) {
+	if err := takePermit(e, a.guard); err != nil {
+		return nil, err
+	}
+	defer a.guard.put()
+	var sum Value
+	var err error
+	switch cur := a.value.(type) {
+	case *Integer:
+		sum, _, err = evalIntegerInfix("+", cur, delta)
+	case *Float:
+		sum, _, err = evalFloatInfix("+", cur, delta)
+	default:
+		err = &RuntimeError{Message: "atomic add expects numeric value"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	a.value = sum
+	return sum, nil
+}
+
+func atomicSwap(e *Evaluator, a *Atomic, next Value, expected Value, compare bool) (Value, error) {
+	if err := takePermit(e, a.guard); err != nil {
+		return nil, err
+	}
+	defer a.guard.put()
+	if compare {
+		if !StrictEqual(a.value, expected) {
+			return &Boolean{Value: false}, nil
+		}
+		a.value = next
+		return &Boolean{Value: true}, nil
+	}
+	old := a.value
+	a.value = next
+	return old, nil
+}
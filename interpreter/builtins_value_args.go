@@ -10,3 +10,12 @@ func stringArg(val Value) (string, bool) {
 		return "", false
 	}
 }
+
+// sinkStringArg is stringArg for explicit sinks (http headers and bodies),
+// which are the only places a Secret is unwrapped.
+func sinkStringArg(val Value) (string, bool) {
+	if s, ok := val.(*Secret); ok {
+		return s.value, true
+	}
+	return stringArg(val)
+}
@@ -0,0 +1,245 @@
+package interpreter
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Checkpoint values are stored as tagged JSON trees so that every Karl value
+// type round-trips exactly: {"i":"42"}, {"f":"0.1"}, {"s":"text"}, {"c":"x"},
+// {"b":true}, {"n":true} (null), {"u":true} (unit), {"a":[...]},
+// {"o":{...}}, {"m":[[key, value], ...]} and {"set":[...]}. Integers and
+// floats are kept as strings so no precision is lost to JSON numbers.
+
+func encodeCheckpointValue(val Value) (any, error) {
+	return encodeCheckpointTree(val, map[Value]bool{})
+}
+
+func encodeCheckpointTree(val Value, visiting map[Value]bool) (any, error) {
+	switch v := val.(type) {
+	case *Integer:
+		return map[string]any{"i": strconv.FormatInt(v.Value, 10)}, nil
+	case *Float:
+		return map[string]any{"f": strconv.FormatFloat(v.Value, 'g', -1, 64)}, nil
+	case *String:
+		return map[string]any{"s": v.Value}, nil
+	case *Char:
+		return map[string]any{"c": v.Value}, nil
+	case *Boolean:
+		return map[string]any{"b": v.Value}, nil
+	case *Null:
+		return map[string]any{"n": true}, nil
+	case *Unit:
+		return map[string]any{"u": true}, nil
+	}
+
+	if visiting[val] {
+		return nil, &RuntimeError{Message: "checkpoint cannot store cyclic value"}
+	}
+	visiting[val] = true
+	defer delete(visiting, val)
+
+	switch v := val.(type) {
+	case *Array:
+		out := make([]any, 0, len(v.Elements))
+		for _, el := range v.Elements {
+			enc, err := encodeCheckpointTree(el, visiting)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, enc)
+		}
+		return map[string]any{"a": out}, nil
+	case *Object:
+		out := make(map[string]any, len(v.Pairs))
+		for k, el := range v.Pairs {
+			enc, err := encodeCheckpointTree(el, visiting)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = enc
+		}
+		return map[string]any{"o": out}, nil
+	case *Map:
+		keys := sortedMapKeys(v.Pairs)
+		out := make([]any, 0, len(keys))
+		for _, key := range keys {
+			k, err := encodeCheckpointTree(mapKeyToValue(key), visiting)
+			if err != nil {
+				return nil, err
+			}
+			el, err := encodeCheckpointTree(v.Pairs[key], visiting)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, []any{k, el})
+		}
+		return map[string]any{"m": out}, nil
+	case *Set:
+		keys := make([]MapKey, 0, len(v.Elements))
+		for key := range v.Elements {
+			keys = append(keys, key)
+		}
+		sortMapKeys(keys)
+		out := make([]any, 0, len(keys))
+		for _, key := range keys {
+			enc, err := encodeCheckpointTree(mapKeyToValue(key), visiting)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, enc)
+		}
+		return map[string]any{"set": out}, nil
+	default:
+		return nil, &RuntimeError{Message: fmt.Sprintf("checkpoint cannot store %s", val.Type())}
+	}
+}
+
+func sortedMapKeys(pairs map[MapKey]Value) []MapKey {
+	keys := make([]MapKey, 0, len(pairs))
+	for key := range pairs {
+		keys = append(keys, key)
+	}
+	sortMapKeys(keys)
+	return keys
+}
+
+func sortMapKeys(keys []MapKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Type != keys[j].Type {
+			return keys[i].Type < keys[j].Type
+		}
+		return keys[i].Value < keys[j].Value
+	})
+}
+
+func decodeCheckpointValue(tree any) (Value, error) {
+	node, ok := tree.(map[string]any)
+	if !ok || len(node) != 1 {
+		return nil, corruptCheckpointError()
+	}
+	for tag, raw := range node {
+		switch tag {
+		case "i":
+			s, ok := raw.(string)
+			if !ok {
+				return nil, corruptCheckpointError()
+			}
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return nil, corruptCheckpointError()
+			}
+			return &Integer{Value: n}, nil
+		case "f":
+			s, ok := raw.(string)
+			if !ok {
+				return nil, corruptCheckpointError()
+			}
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, corruptCheckpointError()
+			}
+			return &Float{Value: f}, nil
+		case "s":
+			s, ok := raw.(string)
+			if !ok {
+				return nil, corruptCheckpointError()
+			}
+			return &String{Value: s}, nil
+		case "c":
+			s, ok := raw.(string)
+			if !ok {
+				return nil, corruptCheckpointError()
+			}
+			return &Char{Value: s}, nil
+		case "b":
+			b, ok := raw.(bool)
+			if !ok {
+				return nil, corruptCheckpointError()
+			}
+			return &Boolean{Value: b}, nil
+		case "n":
+			return NullValue, nil
+		case "u":
+			return UnitValue, nil
+		case "a":
+			items, ok := raw.([]any)
+			if !ok {
+				return nil, corruptCheckpointError()
+			}
+			out := make([]Value, 0, len(items))
+			for _, item := range items {
+				el, err := decodeCheckpointValue(item)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, el)
+			}
+			return &Array{Elements: out}, nil
+		case "o":
+			fields, ok := raw.(map[string]any)
+			if !ok {
+				return nil, corruptCheckpointError()
+			}
+			out := make(map[string]Value, len(fields))
+			for k, item := range fields {
+				el, err := decodeCheckpointValue(item)
+				if err != nil {
+					return nil, err
+				}
+				out[k] = el
+			}
+			return &Object{Pairs: out}, nil
+		case "m":
+			entries, ok := raw.([]any)
+			if !ok {
+				return nil, corruptCheckpointError()
+			}
+			out := make(map[MapKey]Value, len(entries))
+			for _, entry := range entries {
+				pair, ok := entry.([]any)
+				if !ok || len(pair) != 2 {
+					return nil, corruptCheckpointError()
+				}
+				key, err := decodeCheckpointKey(pair[0])
+				if err != nil {
+					return nil, err
+				}
+				el, err := decodeCheckpointValue(pair[1])
+				if err != nil {
+					return nil, err
+				}
+				out[key] = el
+			}
+			return &Map{Pairs: out}, nil
+		case "set":
+			items, ok := raw.([]any)
+			if !ok {
+				return nil, corruptCheckpointError()
+			}
+			out := make(map[MapKey]struct{}, len(items))
+			for _, item := range items {
+				key, err := decodeCheckpointKey(item)
+				if err != nil {
+					return nil, err
+				}
+				out[key] = struct{}{}
+			}
+			return &Set{Elements: out}, nil
+		}
+	}
+	return nil, corruptCheckpointError()
+}
+
+func decodeCheckpointKey(tree any) (MapKey, error) {
+	val, err := decodeCheckpointValue(tree)
+	if err != nil {
+		return MapKey{}, err
+	}
+	key, err := mapKeyForValue(val)
+	if err != nil {
+		return MapKey{}, corruptCheckpointError()
+	}
+	return key, nil
+}
@@ -0,0 +1,181 @@
+package interpreter
+
+import (
+	"sort"
+	"sync"
+
+	"karl/ast"
+)
+
+// coverage counts how often each statement and match arm runs, for
+// `karl run --cover`. Every program and imported module registers all of its
+// statements and arms up front so code that never runs still shows up.
+//
+// Statements are the statements of programs and blocks, plus the bodies of
+// lambdas that are a single expression (x -> x + 1).
+type coverage struct {
+	mu     sync.Mutex
+	files  map[string]*coverageFile
+	counts map[ast.Node]*coverageCounter
+	arms   map[*ast.MatchArm]*coverageCounter
+}
+
+type coverageFile struct {
+	name    string
+	source  string
+	stmts   []*coverageCounter
+	matches []*coverageMatch
+}
+
+type coverageCounter struct {
+	line  int
+	count int64
+}
+
+type coverageMatch struct {
+	line int
+	arms []*coverageCounter
+}
+
+func newCoverage() *coverage {
+	return &coverage{
+		files:  make(map[string]*coverageFile),
+		counts: make(map[ast.Node]*coverageCounter),
+		arms:   make(map[*ast.MatchArm]*coverageCounter),
+	}
+}
+
+// addProgram registers the statements and match arms of a file once.
+func (c *coverage) addProgram(filename, source string, program *ast.Program) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.files[filename]; ok {
+		return
+	}
+	file := &coverageFile{name: filename, source: source}
+	c.files[filename] = file
+	for _, stmt := range program.Statements {
+		c.addStatement(file, stmt)
+		ast.Inspect(stmt, func(node ast.Node) bool {
+			switch n := node.(type) {
+			case *ast.BlockExpression:
+				for _, stmt := range n.Statements {
+					c.addStatement(file, stmt)
+				}
+			case *ast.LambdaExpression:
+				if _, ok := n.Body.(*ast.BlockExpression); !ok && n.Body != nil {
+					c.addStatement(file, n.Body)
+				}
+			case *ast.MatchExpression:
+				match := &coverageMatch{line: n.Token.Line}
+				for i := range n.Arms {
+					arm := &coverageCounter{line: n.Arms[i].Token.Line}
+					c.arms[&n.Arms[i]] = arm
+					match.arms = append(match.arms, arm)
+				}
+				file.matches = append(file.matches, match)
+			}
+			return true
+		})
+	}
+}
+
+func (c *coverage) addStatement(file *coverageFile, node ast.Node) {
+	tok := ast.NodeToken(node)
+	if tok == nil {
+		return
+	}
+	counter := &coverageCounter{line: tok.Line}
+	c.counts[node] = counter
+	file.stmts = append(file.stmts, counter)
+}
+
+func (c *coverage) hit(node ast.Node) {
+	c.mu.Lock()
+	if counter, ok := c.counts[node]; ok {
+		counter.count++
+	}
+	c.mu.Unlock()
+}
+
+func (c *coverage) hitArm(arm *ast.MatchArm) {
+	c.mu.Lock()
+	if counter, ok := c.arms[arm]; ok {
+		counter.count++
+	}
+	c.mu.Unlock()
+}
+
+func (r *runtimeState) coverage() *coverage {
+	if r == nil {
+		return nil
+	}
+	return r.cover.Load()
+}
+
+// coverProgram registers a program or module for coverage, if enabled.
+func (e *Evaluator) coverProgram(filename, source string, program *ast.Program) {
+	if c := e.runtime.coverage(); c != nil {
+		c.addProgram(filename, source, program)
+	}
+}
+
+// coverHit counts one execution of a statement or lambda body.
+func (e *Evaluator) coverHit(node ast.Node) {
+	if c := e.runtime.coverage(); c != nil {
+		c.hit(node)
+	}
+}
+
+// coverArm counts one selection of a match arm.
+func (e *Evaluator) coverArm(arm *ast.MatchArm) {
+	if c := e.runtime.coverage(); c != nil {
+		c.hitArm(arm)
+	}
+}
+
+// EnableCoverage starts counting statement and match arm executions in every
+// file the evaluator runs, including imports and spawned tasks. Call it
+// before Eval; read the results with Coverage.
+func (e *Evaluator) EnableCoverage() {
+	if e.runtime == nil {
+		e.runtime = newRuntimeState()
+	}
+	e.runtime.cover.CompareAndSwap(nil, newCoverage())
+}
+
+// Coverage returns a snapshot of the counts so far, or nil when coverage is
+// not enabled.
+func (e *Evaluator) Coverage() *CoverageReport {
+	c := e.runtime.coverage()
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	report := &CoverageReport{}
+	for _, file := range c.files {
+		fc := CoverageFile{Name: file.name, Source: file.source, Lines: map[int]CoverageLine{}}
+		for _, stmt := range file.stmts {
+			line := fc.Lines[stmt.line]
+			line.Statements++
+			if stmt.count > 0 {
+				line.Covered++
+			}
+			if line.Statements == 1 || stmt.count < line.Count {
+				line.Count = stmt.count
+			}
+			fc.Lines[stmt.line] = line
+		}
+		for _, match := range file.matches {
+			m := CoverageMatch{Line: match.line}
+			for _, arm := range match.arms {
+				m.Arms = append(m.Arms, CoverageArm{Line: arm.line, Count: arm.count})
+			}
+			fc.Matches = append(fc.Matches, m)
+		}
+		report.Files = append(report.Files, fc)
+	}
+	sort.Slice(report.Files, func(i, j int) bool { return report.Files[i].Name < report.Files[j].Name })
+	return report
+}
@@ -0,0 +1,219 @@
+package interpreter
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// CoverageReport is a snapshot of statement and match arm counts per file,
+// as returned by Evaluator.Coverage.
+type CoverageReport struct {
+	Files []CoverageFile
+}
+
+// CoverageFile holds the counts of one program or module.
+type CoverageFile struct {
+	Name   string
+	Source string
+	// Lines maps a line number to the statements that start on it.
+	Lines   map[int]CoverageLine
+	Matches []CoverageMatch
+}
+
+// CoverageLine summarizes the statements on one line. Count is the lowest
+// count among them, so a line only counts as run when all of it ran.
+type CoverageLine struct {
+	Statements int
+	Covered    int
+	Count      int64
+}
+
+// CoverageMatch lists the arms of one match expression in source order.
+type CoverageMatch struct {
+	Line int
+	Arms []CoverageArm
+}
+
+type CoverageArm struct {
+	Line  int
+	Count int64
+}
+
+// StatementCounts returns how many statements ran at least once, and how many
+// there are.
+func (f CoverageFile) StatementCounts() (covered, total int) {
+	for _, line := range f.Lines {
+		covered += line.Covered
+		total += line.Statements
+	}
+	return covered, total
+}
+
+// ArmCounts returns how many match arms were taken at least once, and how
+// many there are.
+func (f CoverageFile) ArmCounts() (covered, total int) {
+	for _, m := range f.Matches {
+		for _, arm := range m.Arms {
+			total++
+			if arm.Count > 0 {
+				covered++
+			}
+		}
+	}
+	return covered, total
+}
+
+func coveragePercent(covered, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(covered)/float64(total))
+}
+
+// WriteText writes a per-file summary table with a total line.
+func (r *CoverageReport) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "file\tstatements\t\tmatch arms\t\t\n")
+	var stmts, stmtTotal, arms, armTotal int
+	row := func(name string, sc, st, ac, at int) {
+		fmt.Fprintf(tw, "%s\t%d/%d\t%s\t%d/%d\t%s\t\n", name, sc, st, coveragePercent(sc, st), ac, at, coveragePercent(ac, at))
+	}
+	for _, f := range r.Files {
+		sc, st := f.StatementCounts()
+		ac, at := f.ArmCounts()
+		row(f.Name, sc, st, ac, at)
+		stmts, stmtTotal, arms, armTotal = stmts+sc, stmtTotal+st, arms+ac, armTotal+at
+	}
+	row("total", stmts, stmtTotal, arms, armTotal)
+	return tw.Flush()
+}
+
+// WriteLCOV writes the report in the LCOV tracefile format: DA records for
+// lines with statements and BRDA records for match arms (one block per match).
+func (r *CoverageReport) WriteLCOV(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "TN:")
+	for _, f := range r.Files {
+		fmt.Fprintf(bw, "SF:%s\n", f.Name)
+		var branches, branchesHit int
+		for block, m := range f.Matches {
+			ran := false
+			for _, arm := range m.Arms {
+				ran = ran || arm.Count > 0
+			}
+			for i, arm := range m.Arms {
+				taken := "-"
+				if ran {
+					taken = fmt.Sprint(arm.Count)
+				}
+				fmt.Fprintf(bw, "BRDA:%d,%d,%d,%s\n", m.Line, block, i, taken)
+				branches++
+				if arm.Count > 0 {
+					branchesHit++
+				}
+			}
+		}
+		fmt.Fprintf(bw, "BRF:%d\nBRH:%d\n", branches, branchesHit)
+		lines := f.lineNumbers()
+		hit := 0
+		for _, n := range lines {
+			count := f.Lines[n].Count
+			fmt.Fprintf(bw, "DA:%d,%d\n", n, count)
+			if count > 0 {
+				hit++
+			}
+		}
+		fmt.Fprintf(bw, "LF:%d\nLH:%d\nend_of_record\n", len(lines), hit)
+	}
+	return bw.Flush()
+}
+
+func (f CoverageFile) lineNumbers() []int {
+	lines := make([]int, 0, len(f.Lines))
+	for n := range f.Lines {
+		lines = append(lines, n)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+const coverageHTMLStyle = `body { font-family: sans-serif; margin: 2em; }
+table.summary td, table.summary th { padding: 0.2em 1em; text-align: right; }
+table.summary td:first-child, table.summary th:first-child { text-align: left; }
+table.source { border-collapse: collapse; font-family: monospace; font-size: 13px; }
+table.source td { padding: 0 0.5em; white-space: pre; vertical-align: top; }
+td.num, td.count { color: #888; text-align: right; }
+tr.covered td.code { background: #dfd; }
+tr.partial td.code { background: #ffc; }
+tr.uncovered td.code { background: #fdd; }
+`
+
+// WriteHTML writes a single page with the summary and every file's source,
+// each line shaded by whether its statements and match arms ran.
+func (r *CoverageReport) WriteHTML(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>Karl coverage</title>\n<style>\n%s</style>\n</head>\n<body>\n", coverageHTMLStyle)
+	fmt.Fprintln(bw, "<h1>Karl coverage</h1>")
+	fmt.Fprintln(bw, `<table class="summary">`)
+	fmt.Fprintln(bw, "<tr><th>file</th><th>statements</th><th></th><th>match arms</th><th></th></tr>")
+	for i, f := range r.Files {
+		sc, st := f.StatementCounts()
+		ac, at := f.ArmCounts()
+		fmt.Fprintf(bw, "<tr><td><a href=\"#file%d\">%s</a></td><td>%d/%d</td><td>%s</td><td>%d/%d</td><td>%s</td></tr>\n",
+			i, html.EscapeString(f.Name), sc, st, coveragePercent(sc, st), ac, at, coveragePercent(ac, at))
+	}
+	fmt.Fprintln(bw, "</table>")
+	for i, f := range r.Files {
+		fmt.Fprintf(bw, "<h2 id=\"file%d\">%s</h2>\n<table class=\"source\">\n", i, html.EscapeString(f.Name))
+		arms := map[int][]CoverageArm{}
+		for _, m := range f.Matches {
+			for _, arm := range m.Arms {
+				arms[arm.Line] = append(arms[arm.Line], arm)
+			}
+		}
+		for n, text := range strings.Split(f.Source, "\n") {
+			class, count, title := f.lineStatus(n+1, arms[n+1])
+			fmt.Fprintf(bw, "<tr class=\"%s\" title=\"%s\"><td class=\"num\">%d</td><td class=\"count\">%s</td><td class=\"code\">%s</td></tr>\n",
+				class, title, n+1, count, html.EscapeString(strings.TrimRight(text, "\r")))
+		}
+		fmt.Fprintln(bw, "</table>")
+	}
+	fmt.Fprintln(bw, "</body>\n</html>")
+	return bw.Flush()
+}
+
+// lineStatus returns the CSS class, count column and tooltip for one line.
+func (f CoverageFile) lineStatus(n int, arms []CoverageArm) (string, string, string) {
+	line, hasStmts := f.Lines[n]
+	if !hasStmts && len(arms) == 0 {
+		return "", "", ""
+	}
+	units, covered := line.Statements, line.Covered
+	count := line.Count
+	for i, arm := range arms {
+		units++
+		if arm.Count > 0 {
+			covered++
+		}
+		if (!hasStmts && i == 0) || arm.Count < count {
+			count = arm.Count
+		}
+	}
+	title := fmt.Sprintf("%d/%d statements", line.Covered, line.Statements)
+	if len(arms) > 0 {
+		title += fmt.Sprintf(", %d/%d match arms", covered-line.Covered, len(arms))
+	}
+	class := "partial"
+	switch covered {
+	case 0:
+		class = "uncovered"
+	case units:
+		class = "covered"
+	}
+	return class, fmt.Sprint(count), title
+}
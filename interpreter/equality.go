@@ -19,8 +19,12 @@ func StrictEqual(left, right Value) bool {
 		return l.Value == right.(*String).Value
 	case *Char:
 		return l.Value == right.(*Char).Value
+	case *Secret:
+		return l.value == right.(*Secret).value
 	case *Null, *Unit:
 		return true
+	case *Function:
+		return sameFunction(l, right.(*Function))
 	case *Map:
 		return left == right
 	default:
@@ -21,6 +21,8 @@ func Equivalent(left, right Value) bool {
 		return l.Value == right.(*Char).Value
 	case *Null, *Unit:
 		return true
+	case *Function:
+		return sameFunction(l, right.(*Function))
 	case *Array:
 		r := right.(*Array)
 		if len(l.Elements) != len(r.Elements) {
@@ -20,6 +20,9 @@ type RecoverableError struct {
 	Message string
 	Kind    string
 	Token   *token.Token
+
+	// counted is set once the error is in karl_recoverable_errors_total.
+	counted bool
 }
 
 func (e *RecoverableError) Error() string {
@@ -38,9 +41,16 @@ func FormatRuntimeError(err error, source string, filename string) string {
 }
 
 func formatRuntimeError(message string, tok *token.Token, source string, filename string) string {
-	if tok == nil || tok.Line == 0 || source == "" {
+	if tok == nil || tok.Line == 0 {
 		return "runtime error: " + message
 	}
+	if source == "" {
+		// Programs decoded from a JSON AST have positions but no text.
+		if filename == "" {
+			return "runtime error: " + message
+		}
+		return fmt.Sprintf("runtime error: %s\n  at %s:%d:%d", message, filename, tok.Line, tok.Column)
+	}
 	lines := strings.Split(source, "\n")
 	line := tok.Line
 	col := tok.Column
@@ -69,8 +79,11 @@ func formatRuntimeError(message string, tok *token.Token, source string, filenam
 	)
 }
 
+// ExitError is returned when the program calls exit() or is shut down by a
+// signal. Code is the process exit status the embedder should use.
 type ExitError struct {
 	Message string
+	Code    int
 }
 
 func (e *ExitError) Error() string {
@@ -31,6 +31,10 @@ func (e *Evaluator) evalInfixExpression(node *ast.InfixExpression, env *Environm
 		return left, sig, err
 	}
 
+	if node.Operator == "|>" {
+		return e.evalPipeline(node, left, env)
+	}
+
 	if node.Operator == "&&" || node.Operator == "||" {
 		// Support truthy/falsy evaluation for logical operators
 		leftTruthy := isTruthy(left)
@@ -61,6 +65,12 @@ func (e *Evaluator) evalInfixExpression(node *ast.InfixExpression, env *Environm
 		return &Boolean{Value: Equivalent(left, right)}, nil, nil
 	}
 
+	if node.Operator == "+" {
+		if joined, ok := concatSecret(left, right); ok {
+			return joined, nil, nil
+		}
+	}
+
 	switch l := left.(type) {
 	case *Integer:
 		return evalIntegerInfix(node.Operator, l, right)
@@ -3,11 +3,21 @@ package interpreter
 import "karl/ast"
 
 func (e *Evaluator) applyFunction(fn Value, args []Value) (Value, *Signal, error) {
+	if err := e.safepoint(); err != nil {
+		return nil, nil, err
+	}
 	switch f := fn.(type) {
 	case *Builtin:
 		val, err := f.Fn(e, args)
+		if err != nil && e.runtime != nil {
+			e.runtime.redactError(err)
+			e.runtime.countRecoverable(err)
+		}
 		return val, nil, err
 	case *Function:
+		if f.Receiver != nil {
+			args = append([]Value{f.Receiver}, args...)
+		}
 		if len(args) != len(f.Params) {
 			return nil, nil, &RuntimeError{Message: "wrong number of arguments"}
 		}
@@ -21,6 +31,9 @@ func (e *Evaluator) applyFunction(fn Value, args []Value) (Value, *Signal, error
 				return nil, nil, &RuntimeError{Message: "parameter pattern did not match"}
 			}
 		}
+		if exit := e.enterFunction(f); exit != nil {
+			defer exit()
+		}
 		val, sig, err := e.Eval(f.Body, extended)
 		if err != nil {
 			return nil, nil, err
@@ -52,6 +65,35 @@ func (e *Evaluator) applyFunction(fn Value, args []Value) (Value, *Signal, error
 	}
 }
 
+// enterFunction records a call of f for coverage, profiling and tracing,
+// returning the function that ends it, or nil when none of them is on. It is
+// kept out of applyFunction so the common path does not grow every task's
+// stack.
+func (e *Evaluator) enterFunction(f *Function) func() {
+	e.coverHit(f.Body)
+	stack := e.profileStack()
+	tr := e.runtime.tracer()
+	if stack == nil && tr == nil {
+		return nil
+	}
+	depth := -1
+	if stack != nil {
+		depth = stack.push(functionFrame(f))
+	}
+	var endSpan func()
+	if tr != nil {
+		endSpan = tr.span(e.traceThread(), f.label(), "function", nil)
+	}
+	return func() {
+		if endSpan != nil {
+			endSpan()
+		}
+		if depth >= 0 {
+			stack.pop(depth)
+		}
+	}
+}
+
 func bindPattern(pattern ast.Pattern, value Value, env *Environment) (bool, error) {
 	return matchPattern(pattern, value, env)
 }
@@ -17,7 +17,10 @@ func (e *Evaluator) resolveAssignable(node ast.Expression, env *Environment) (Va
 		}
 		switch obj := objVal.(type) {
 		case *Object:
-			return obj.Pairs[n.Property.Value], func(v Value) { obj.Pairs[n.Property.Value] = v }, nil
+			// Read through the prototype so inherited defaults can be updated;
+			// the write always lands on the object itself.
+			val, _ := obj.lookup(n.Property.Value)
+			return val, func(v Value) { obj.Pairs[n.Property.Value] = v }, nil
 		case *ModuleObject:
 			if obj.Env == nil {
 				return nil, nil, &RuntimeError{Message: "member assignment requires object"}
@@ -1,6 +1,11 @@
 package interpreter
 
-import "karl/ast"
+import (
+	"fmt"
+
+	"karl/ast"
+	"karl/token"
+)
 
 func (e *Evaluator) evalCallExpression(node *ast.CallExpression, env *Environment) (Value, *Signal, error) {
 	function, sig, err := e.Eval(node.Function, env)
@@ -26,5 +31,60 @@ func (e *Evaluator) evalCallExpression(node *ast.CallExpression, env *Environmen
 	if hasPlaceholder {
 		return &Partial{Target: function, Args: args}, nil, nil
 	}
-	return e.applyFunction(function, args)
+	val, sig, err := e.applyFunction(function, args)
+	if _, ok := function.(*Builtin); ok && err == nil {
+		// Values built by user functions are attributed inside them.
+		e.recordAlloc(node.Token.Line, val)
+	}
+	return val, sig, err
+}
+
+// evalPipeline applies the right side of `x |> f` to the already evaluated
+// left value. A call with placeholders on the right evaluates to a Partial, so
+// `x |> f(_, 2)` is f(x, 2); anything else must evaluate to a function.
+func (e *Evaluator) evalPipeline(node *ast.InfixExpression, left Value, env *Environment) (Value, *Signal, error) {
+	function, sig, err := e.Eval(node.Right, env)
+	if err != nil || sig != nil {
+		return function, sig, err
+	}
+	switch function.(type) {
+	case *Builtin, *Function, *Partial:
+	default:
+		msg := fmt.Sprintf("right side of '|>' is %s, not a function", function.Type())
+		if call, ok := node.Right.(*ast.CallExpression); ok && !hasPlaceholderArg(call) {
+			msg += "; mark where the piped value goes with '_', as in f(_, x)"
+		}
+		return nil, nil, &RuntimeError{Message: msg, Token: firstToken(node.Right)}
+	}
+	val, sig, err := e.applyFunction(function, []Value{left})
+	annotateErrorToken(node.Right, err)
+	if _, ok := function.(*Builtin); ok && err == nil {
+		e.recordAlloc(node.Token.Line, val)
+	}
+	return val, sig, err
+}
+
+// firstToken returns the token node starts with, so an error about a whole
+// expression such as `inc == 2` points at its beginning rather than at the
+// operator the parser keeps as the node's token.
+func firstToken(node ast.Node) *token.Token {
+	start := ast.NodeStart(node)
+	first := ast.NodeToken(node)
+	ast.Inspect(node, func(n ast.Node) bool {
+		if tok := ast.NodeToken(n); tok != nil && tok.Line == start.Line && tok.Column == start.Column {
+			first = tok
+			return false
+		}
+		return true
+	})
+	return first
+}
+
+func hasPlaceholderArg(call *ast.CallExpression) bool {
+	for _, arg := range call.Arguments {
+		if _, ok := arg.(*ast.Placeholder); ok {
+			return true
+		}
+	}
+	return false
 }
@@ -6,11 +6,13 @@ import (
 )
 
 func (e *Evaluator) Eval(node ast.Node, env *Environment) (Value, *Signal, error) {
-	if err := e.checkRuntimeBeforeEval(); err != nil {
-		return nil, nil, err
+	if !e.holdsLock {
+		return e.evalWithLock(node, env)
 	}
-
 	val, sig, err := e.evalNode(node, env)
+	if err == nil && e.runtime.allocProfiler() != nil {
+		e.recordNodeAlloc(node, val)
+	}
 	annotateErrorToken(node, err)
 	if fatalErr := e.checkRuntimeAfterEval(sig, err); fatalErr != nil {
 		return nil, nil, fatalErr
@@ -18,6 +20,19 @@ func (e *Evaluator) Eval(node ast.Node, env *Environment) (Value, *Signal, error
 	return val, sig, err
 }
 
+func (e *Evaluator) evalWithLock(node ast.Node, env *Environment) (Value, *Signal, error) {
+	if e.runtime == nil {
+		e.runtime = newRuntimeState()
+	}
+	e.runtime.acquire()
+	e.holdsLock = true
+	defer func() {
+		e.holdsLock = false
+		e.runtime.release()
+	}()
+	return e.Eval(node, env)
+}
+
 func (e *Evaluator) evalNode(node ast.Node, env *Environment) (Value, *Signal, error) {
 	switch n := node.(type) {
 	case *ast.Program:
@@ -29,6 +44,11 @@ func (e *Evaluator) evalNode(node ast.Node, env *Environment) (Value, *Signal, e
 		if err != nil || sig != nil {
 			return val, sig, err
 		}
+		if fn, ok := val.(*Function); ok && fn.Name == "" {
+			if ident, ok := n.Name.(*ast.Identifier); ok {
+				fn.Name = ident.Value
+			}
+		}
 		if ok, err := bindPattern(n.Name, val, env); !ok || err != nil {
 			if err != nil {
 				return nil, nil, err
@@ -77,7 +97,7 @@ func (e *Evaluator) evalNode(node ast.Node, env *Environment) (Value, *Signal, e
 	case *ast.ForExpression:
 		return e.evalForExpression(n, env)
 	case *ast.LambdaExpression:
-		return &Function{Params: n.Params, Body: n.Body, Env: env}, nil, nil
+		return &Function{Params: n.Params, Body: n.Body, Env: env, Filename: e.filename, Line: n.Token.Line}, nil, nil
 	case *ast.CallExpression:
 		return e.evalCallExpression(n, env)
 	case *ast.MemberExpression:
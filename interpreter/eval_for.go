@@ -19,6 +19,9 @@ func (e *Evaluator) evalForExpression(node *ast.ForExpression, env *Environment)
 	}
 
 	for {
+		if err := e.safepoint(); err != nil {
+			return nil, nil, err
+		}
 		condVal, sig, err := e.Eval(node.Condition, loopEnv)
 		if err != nil || sig != nil {
 			return condVal, sig, err
@@ -20,6 +20,11 @@ func (e *Evaluator) evalBlockExpression(block *ast.BlockExpression, env *Environ
 	blockEnv := NewEnclosedEnvironment(env)
 	var result Value = UnitValue
 	for _, stmt := range block.Statements {
+		if err := e.safepoint(); err != nil {
+			return nil, nil, err
+		}
+		e.trackPosition(stmt)
+		e.coverHit(stmt)
 		val, sig, err := e.Eval(stmt, blockEnv)
 		if err != nil || sig != nil {
 			return val, sig, err
@@ -7,7 +7,8 @@ func (e *Evaluator) evalMatchExpression(node *ast.MatchExpression, env *Environm
 	if err != nil || sig != nil {
 		return value, sig, err
 	}
-	for _, arm := range node.Arms {
+	for i := range node.Arms {
+		arm := &node.Arms[i]
 		armEnv := NewEnclosedEnvironment(env)
 		ok, err := matchPattern(arm.Pattern, value, armEnv)
 		if err != nil {
@@ -25,6 +26,7 @@ func (e *Evaluator) evalMatchExpression(node *ast.MatchExpression, env *Environm
 				continue
 			}
 		}
+		e.coverArm(arm)
 		return e.Eval(arm.Body, armEnv)
 	}
 	return nil, nil, &RuntimeError{Message: "non-exhaustive match"}
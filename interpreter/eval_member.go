@@ -14,11 +14,11 @@ func (e *Evaluator) evalMemberExpression(node *ast.MemberExpression, env *Enviro
 
 	switch obj := object.(type) {
 	case *Object:
-		val, ok := obj.Pairs[node.Property.Value]
+		val, ok := obj.lookup(node.Property.Value)
 		if !ok {
 			return nil, nil, &RuntimeError{Message: "missing property: " + node.Property.Value}
 		}
-		return val, nil, nil
+		return bindMethod(obj, val), nil, nil
 	case *ModuleObject:
 		if obj.Env == nil {
 			return nil, nil, &RuntimeError{Message: "member access on invalid module object"}
@@ -47,8 +47,26 @@ func (e *Evaluator) evalMemberExpression(node *ast.MemberExpression, env *Enviro
 		return e.setMethod(obj, node.Property.Value)
 	case *Channel:
 		return e.channelMethod(obj, node.Property.Value)
+	case *Broadcast:
+		return e.broadcastMethod(obj, node.Property.Value)
 	case *Task:
 		return e.taskMethod(obj, node.Property.Value)
+	case *Pool:
+		return e.poolMethod(obj, node.Property.Value)
+	case *Mutex:
+		return e.mutexMethod(obj, node.Property.Value)
+	case *Semaphore:
+		return e.semaphoreMethod(obj, node.Property.Value)
+	case *Atomic:
+		return e.atomicMethod(obj, node.Property.Value)
+	case *Checkpoint:
+		return e.checkpointMethod(obj, node.Property.Value)
+	case *Schedule:
+		return e.scheduleMethod(obj, node.Property.Value)
+	case *Logger:
+		return e.loggerMethod(obj, node.Property.Value)
+	case *Metric:
+		return e.metricMethod(obj, node.Property.Value)
 	default:
 		if object == nil {
 			return nil, nil, &RuntimeError{Message: "member access on non-object (got <nil>)"}
@@ -0,0 +1,63 @@
+package interpreter
+
+func (e *Evaluator) loggerMethod(l *Logger, name string) (Value, *Signal, error) {
+	switch name {
+	case "debug", "info", "warn", "error":
+		level, _ := ParseLogLevel(name)
+		return &Builtin{
+			Name: name,
+			Fn: func(e *Evaluator, args []Value) (Value, error) {
+				if len(args) != 1 && len(args) != 2 {
+					return nil, &RuntimeError{Message: name + " expects message and optional fields object"}
+				}
+				var fields map[string]Value
+				if len(args) == 2 {
+					pairs, ok := objectPairs(args[1])
+					if !ok {
+						return nil, &RuntimeError{Message: name + " fields must be an object"}
+					}
+					fields = pairs
+				}
+				if err := l.log(e, level, formatLogValue(args[0]), fields); err != nil {
+					return nil, err
+				}
+				return UnitValue, nil
+			},
+		}, nil, nil
+	case "child":
+		return &Builtin{
+			Name: name,
+			Fn: func(_ *Evaluator, args []Value) (Value, error) {
+				if len(args) != 1 {
+					return nil, &RuntimeError{Message: "child expects fields object"}
+				}
+				fields, ok := objectPairs(args[0])
+				if !ok {
+					return nil, &RuntimeError{Message: "child expects fields object"}
+				}
+				return l.child(fields), nil
+			},
+		}, nil, nil
+	case "enabled":
+		return &Builtin{
+			Name: name,
+			Fn: func(_ *Evaluator, args []Value) (Value, error) {
+				if len(args) != 1 {
+					return nil, &RuntimeError{Message: "enabled expects level"}
+				}
+				levelName, _ := stringArg(args[0])
+				level, ok := ParseLogLevel(levelName)
+				if !ok {
+					return nil, &RuntimeError{Message: "enabled expects \"debug\", \"info\", \"warn\" or \"error\""}
+				}
+				return &Boolean{Value: level >= l.level}, nil
+			},
+		}, nil, nil
+	case "level":
+		return &String{Value: l.level.String()}, nil, nil
+	case "format":
+		return &String{Value: l.format}, nil, nil
+	default:
+		return nil, nil, &RuntimeError{Message: "unknown logger member: " + name}
+	}
+}
@@ -26,17 +26,61 @@ func (e *Evaluator) arrayMethod(arr *Array, name string) (Value, *Signal, error)
 
 func (e *Evaluator) channelMethod(ch *Channel, name string) (Value, *Signal, error) {
 	switch name {
-	case "send", "recv", "done":
+	case "send", "recv", "done", "trySend", "tryRecv":
 		builtin := getBuiltin(name)
 		if builtin == nil {
 			return nil, nil, &RuntimeError{Message: "unknown builtin: " + name}
 		}
 		return &Builtin{Name: name, Fn: bindReceiver(builtin.Fn, ch)}, nil, nil
+	case "close":
+		return &Builtin{Name: name, Fn: bindReceiver(builtinDone, ch)}, nil, nil
+	case "closed":
+		return &Boolean{Value: ch.Closed}, nil, nil
+	case "len", "cap":
+		return &Builtin{
+			Name: name,
+			Fn: func(_ *Evaluator, args []Value) (Value, error) {
+				if len(args) != 0 {
+					return nil, &RuntimeError{Message: name + " expects no arguments"}
+				}
+				if name == "len" {
+					return &Integer{Value: int64(ch.Len())}, nil
+				}
+				return &Integer{Value: int64(ch.Cap())}, nil
+			},
+		}, nil, nil
 	default:
 		return nil, nil, &RuntimeError{Message: "unknown channel member: " + name}
 	}
 }
 
+func (e *Evaluator) broadcastMethod(b *Broadcast, name string) (Value, *Signal, error) {
+	switch name {
+	case "send":
+		return &Builtin{Name: name, Fn: bindReceiver(builtinSend, b)}, nil, nil
+	case "subscribe":
+		return &Builtin{
+			Name: name,
+			Fn: func(e *Evaluator, args []Value) (Value, error) {
+				if len(args) != 0 {
+					return nil, &RuntimeError{Message: "subscribe expects no arguments"}
+				}
+				return b.subscribe(e), nil
+			},
+		}, nil, nil
+	case "done", "close":
+		return &Builtin{Name: name, Fn: bindReceiver(builtinDone, b)}, nil, nil
+	case "closed":
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return &Boolean{Value: b.closed}, nil, nil
+	case "subscribers":
+		return &Integer{Value: int64(b.subscriberCount())}, nil, nil
+	default:
+		return nil, nil, &RuntimeError{Message: "unknown broadcast member: " + name}
+	}
+}
+
 func (e *Evaluator) stringMethod(str *String, name string) (Value, *Signal, error) {
 	switch name {
 	case "split", "chars", "trim", "toLower", "toUpper", "contains", "startsWith", "endsWith", "replace":
@@ -78,6 +122,25 @@ func (e *Evaluator) setMethod(s *Set, name string) (Value, *Signal, error) {
 
 func (e *Evaluator) taskMethod(t *Task, name string) (Value, *Signal, error) {
 	switch name {
+	case "id":
+		return &Integer{Value: int64(t.id)}, nil, nil
+	case "name":
+		if t.name == "" {
+			return NullValue, nil, nil
+		}
+		return &String{Value: t.name}, nil, nil
+	case "context":
+		return contextObject(t.context), nil, nil
+	case "status":
+		return &Builtin{
+			Name: "status",
+			Fn: func(_ *Evaluator, args []Value) (Value, error) {
+				if len(args) != 0 {
+					return nil, &RuntimeError{Message: "status expects no arguments"}
+				}
+				return &String{Value: t.status()}, nil
+			},
+		}, nil, nil
 	case "then":
 		builtin := getBuiltin(name)
 		if builtin == nil {
@@ -99,3 +162,148 @@ func (e *Evaluator) taskMethod(t *Task, name string) (Value, *Signal, error) {
 		return nil, nil, &RuntimeError{Message: "unknown task member: " + name}
 	}
 }
+
+func (e *Evaluator) poolMethod(p *Pool, name string) (Value, *Signal, error) {
+	switch name {
+	case "submit":
+		return &Builtin{Name: name, Fn: bindReceiver(builtinPoolSubmit, p)}, nil, nil
+	case "cancel":
+		return &Builtin{
+			Name: "cancel",
+			Fn: func(_ *Evaluator, args []Value) (Value, error) {
+				if len(args) != 0 {
+					return nil, &RuntimeError{Message: "cancel expects no arguments"}
+				}
+				p.Cancel()
+				return UnitValue, nil
+			},
+		}, nil, nil
+	default:
+		return nil, nil, &RuntimeError{Message: "unknown pool member: " + name}
+	}
+}
+
+func (e *Evaluator) scheduleMethod(s *Schedule, name string) (Value, *Signal, error) {
+	switch name {
+	case "task":
+		return s.task, nil, nil
+	case "stats":
+		return &Builtin{
+			Name: "stats",
+			Fn: func(_ *Evaluator, args []Value) (Value, error) {
+				if len(args) != 0 {
+					return nil, &RuntimeError{Message: "stats expects no arguments"}
+				}
+				return s.stats(), nil
+			},
+		}, nil, nil
+	case "cancel":
+		return &Builtin{
+			Name: "cancel",
+			Fn: func(_ *Evaluator, args []Value) (Value, error) {
+				if len(args) != 0 {
+					return nil, &RuntimeError{Message: "cancel expects no arguments"}
+				}
+				s.task.Cancel()
+				return UnitValue, nil
+			},
+		}, nil, nil
+	default:
+		return nil, nil, &RuntimeError{Message: "unknown schedule member: " + name}
+	}
+}
+
+func (e *Evaluator) checkpointMethod(c *Checkpoint, name string) (Value, *Signal, error) {
+	switch name {
+	case "get":
+		return &Builtin{
+			Name: "get",
+			Fn: func(_ *Evaluator, args []Value) (Value, error) {
+				if len(args) != 1 && len(args) != 2 {
+					return nil, &RuntimeError{Message: "get expects key and optional default"}
+				}
+				key, ok := stringArg(args[0])
+				if !ok {
+					return nil, &RuntimeError{Message: "checkpoint keys must be strings"}
+				}
+				val, found, err := c.get(key)
+				if err != nil {
+					return nil, err
+				}
+				if !found {
+					if len(args) == 2 {
+						return args[1], nil
+					}
+					return NullValue, nil
+				}
+				return val, nil
+			},
+		}, nil, nil
+	case "has":
+		return &Builtin{
+			Name: "has",
+			Fn: func(_ *Evaluator, args []Value) (Value, error) {
+				if len(args) != 1 {
+					return nil, &RuntimeError{Message: "has expects key"}
+				}
+				key, ok := stringArg(args[0])
+				if !ok {
+					return nil, &RuntimeError{Message: "checkpoint keys must be strings"}
+				}
+				_, found := c.values[key]
+				return &Boolean{Value: found}, nil
+			},
+		}, nil, nil
+	case "put":
+		return &Builtin{
+			Name: "put",
+			Fn: func(e *Evaluator, args []Value) (Value, error) {
+				if len(args) != 2 {
+					return nil, &RuntimeError{Message: "put expects key and value"}
+				}
+				key, ok := stringArg(args[0])
+				if !ok {
+					return nil, &RuntimeError{Message: "checkpoint keys must be strings"}
+				}
+				if err := c.put(e, key, args[1]); err != nil {
+					return nil, err
+				}
+				return UnitValue, nil
+			},
+		}, nil, nil
+	case "delete":
+		return &Builtin{
+			Name: "delete",
+			Fn: func(e *Evaluator, args []Value) (Value, error) {
+				if len(args) != 1 {
+					return nil, &RuntimeError{Message: "delete expects key"}
+				}
+				key, ok := stringArg(args[0])
+				if !ok {
+					return nil, &RuntimeError{Message: "checkpoint keys must be strings"}
+				}
+				removed, err := c.remove(e, key)
+				if err != nil {
+					return nil, err
+				}
+				return &Boolean{Value: removed}, nil
+			},
+		}, nil, nil
+	case "step":
+		return &Builtin{
+			Name: "step",
+			Fn: func(e *Evaluator, args []Value) (Value, error) {
+				if len(args) != 2 {
+					return nil, &RuntimeError{Message: "step expects name and function"}
+				}
+				name, ok := stringArg(args[0])
+				if !ok {
+					return nil, &RuntimeError{Message: "step expects string name"}
+				}
+				return c.step(e, name, args[1])
+			},
+		}, nil, nil
+	default:
+		return nil, nil, &RuntimeError{Message: "unknown checkpoint member: " + name}
+	}
+}
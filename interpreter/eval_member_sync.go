@@ -0,0 +1,115 @@
+package interpreter
+
+import "fmt"
+
+func (e *Evaluator) mutexMethod(m *Mutex, name string) (Value, *Signal, error) {
+	switch name {
+	case "lock":
+		return syncMethod(name, 0, func(e *Evaluator, _ []Value) (Value, error) {
+			if err := takePermit(e, m.permits); err != nil {
+				return nil, err
+			}
+			return UnitValue, nil
+		}), nil, nil
+	case "tryLock":
+		return syncMethod(name, 0, func(_ *Evaluator, _ []Value) (Value, error) {
+			return &Boolean{Value: m.permits.tryTake()}, nil
+		}), nil, nil
+	case "unlock":
+		return syncMethod(name, 0, func(_ *Evaluator, _ []Value) (Value, error) {
+			if !m.permits.put() {
+				return nil, &RuntimeError{Message: "unlock of unlocked mutex"}
+			}
+			return UnitValue, nil
+		}), nil, nil
+	case "withLock":
+		return syncMethod(name, 1, func(e *Evaluator, args []Value) (Value, error) {
+			return withPermit(e, m.permits, args[0], nil)
+		}), nil, nil
+	default:
+		return nil, nil, &RuntimeError{Message: "unknown mutex member: " + name}
+	}
+}
+
+func (e *Evaluator) semaphoreMethod(s *Semaphore, name string) (Value, *Signal, error) {
+	switch name {
+	case "available":
+		return &Integer{Value: int64(s.permits.free())}, nil, nil
+	case "acquire":
+		return syncMethod(name, 0, func(e *Evaluator, _ []Value) (Value, error) {
+			if err := takePermit(e, s.permits); err != nil {
+				return nil, err
+			}
+			return UnitValue, nil
+		}), nil, nil
+	case "tryAcquire":
+		return syncMethod(name, 0, func(_ *Evaluator, _ []Value) (Value, error) {
+			return &Boolean{Value: s.permits.tryTake()}, nil
+		}), nil, nil
+	case "release":
+		return syncMethod(name, 0, func(_ *Evaluator, _ []Value) (Value, error) {
+			if !s.permits.put() {
+				return nil, &RuntimeError{Message: "semaphore released more often than acquired"}
+			}
+			return UnitValue, nil
+		}), nil, nil
+	case "withPermit":
+		return syncMethod(name, 1, func(e *Evaluator, args []Value) (Value, error) {
+			return withPermit(e, s.permits, args[0], nil)
+		}), nil, nil
+	default:
+		return nil, nil, &RuntimeError{Message: "unknown semaphore member: " + name}
+	}
+}
+
+func (e *Evaluator) atomicMethod(a *Atomic, name string) (Value, *Signal, error) {
+	switch name {
+	case "get":
+		return syncMethod(name, 0, func(_ *Evaluator, _ []Value) (Value, error) {
+			return a.value, nil
+		}), nil, nil
+	case "set":
+		return syncMethod(name, 1, func(e *Evaluator, args []Value) (Value, error) {
+			if _, err := atomicSwap(e, a, args[0], nil, false); err != nil {
+				return nil, err
+			}
+			return UnitValue, nil
+		}), nil, nil
+	case "swap":
+		return syncMethod(name, 1, func(e *Evaluator, args []Value) (Value, error) {
+			return atomicSwap(e, a, args[0], nil, false)
+		}), nil, nil
+	case "compareAndSet":
+		return syncMethod(name, 2, func(e *Evaluator, args []Value) (Value, error) {
+			return atomicSwap(e, a, args[1], args[0], true)
+		}), nil, nil
+	case "add":
+		return syncMethod(name, 1, func(e *Evaluator, args []Value) (Value, error) {
+			return atomicAdd(e, a, args[0])
+		}), nil, nil
+	case "update":
+		return syncMethod(name, 1, func(e *Evaluator, args []Value) (Value, error) {
+			return atomicUpdate(e, a, args[0])
+		}), nil, nil
+	default:
+		return nil, nil, &RuntimeError{Message: "unknown atomic member: " + name}
+	}
+}
+
+func syncMethod(name string, arity int, fn BuiltinFunction) *Builtin {
+	return &Builtin{
+		Name: name,
+		Fn: func(e *Evaluator, args []Value) (Value, error) {
+			switch {
+			case len(args) == arity:
+			case arity == 0:
+				return nil, &RuntimeError{Message: name + " expects no arguments"}
+			case arity == 1:
+				return nil, &RuntimeError{Message: name + " expects 1 argument"}
+			default:
+				return nil, &RuntimeError{Message: fmt.Sprintf("%s expects %d arguments", name, arity)}
+			}
+			return fn(e, args)
+		},
+	}
+}
@@ -28,7 +28,16 @@ func errorValue(err error) Value {
 
 func (e *Evaluator) evalProgram(program *ast.Program, env *Environment) (Value, *Signal, error) {
 	var result Value = UnitValue
+	e.coverProgram(e.filename, e.source, program)
+	if stack := e.profileStack(); stack != nil {
+		defer stack.pop(stack.push(profileFrame{name: profileTopLevelName, file: e.filename, startLine: 1, line: 1}))
+	}
 	for _, stmt := range program.Statements {
+		if err := e.safepoint(); err != nil {
+			return nil, nil, err
+		}
+		e.trackPosition(stmt)
+		e.coverHit(stmt)
 		val, sig, err := e.Eval(stmt, env)
 		if err != nil {
 			return nil, nil, err
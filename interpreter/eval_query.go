@@ -23,6 +23,9 @@ func (e *Evaluator) evalQueryExpression(node *ast.QueryExpression, env *Environm
 	rows := []queryRow{}
 
 	for _, item := range source.Elements {
+		if err := e.safepoint(); err != nil {
+			return nil, nil, err
+		}
 		rowEnv := NewEnclosedEnvironment(env)
 		rowEnv.Define(node.Var.Value, item)
 
@@ -65,6 +68,9 @@ func (e *Evaluator) evalQueryExpression(node *ast.QueryExpression, env *Environm
 
 	results := []Value{}
 	for _, r := range rows {
+		if err := e.safepoint(); err != nil {
+			return nil, nil, err
+		}
 		rowEnv := NewEnclosedEnvironment(env)
 		rowEnv.Define(node.Var.Value, r.item)
 		val, sig, err := e.Eval(node.Select, rowEnv)
@@ -14,7 +14,7 @@ func (e *Evaluator) evalRaceExpression(node *ast.RaceExpression, env *Environmen
 		children = append(children, child)
 	}
 
-	go func() {
+	e.runtime.goAsync(func() {
 		type result struct {
 			value Value
 			sig   *Signal
@@ -22,27 +22,45 @@ func (e *Evaluator) evalRaceExpression(node *ast.RaceExpression, env *Environmen
 		}
 		results := make(chan result, len(children))
 		for _, child := range children {
-			go func(t *Task) {
+			t := child
+			e.runtime.goAsync(func() {
 				val, sig, err := taskAwaitWithCancel(t, raceTask.cancelCh, e.runtime)
 				results <- result{value: val, sig: sig, err: err}
-			}(child)
+			})
 		}
 
-		select {
-		case <-raceTask.cancelCh:
-			// canceled by user or parent; Cancel() already completed the task.
-			return
-		case first := <-results:
-			// Cancel losers. Cancellation is cooperative; losers will stop at the next yield point.
+		if err := e.runtime.park(func() bool {
+			return raceTask.canceled() || len(results) > 0
+		}); err != nil {
 			raceTask.cancelChildren()
-			if first.sig != nil {
-				raceTask.complete(nil, &RuntimeError{Message: "break/continue outside loop"})
-				return
+			raceTask.complete(nil, err)
+			return
+		}
+		if raceTask.canceled() {
+			return
+		}
+		var first result
+		canceled := false
+		e.runtime.blocking(func() {
+			select {
+			case <-raceTask.cancelCh:
+				canceled = true
+			case first = <-results:
 			}
-			raceTask.complete(first.value, first.err)
+		})
+		if canceled {
+			// canceled by user or parent; Cancel() already completed the task.
+			return
+		}
+		// Cancel losers. Cancellation is cooperative; losers stop at their next
+		// yield point or safepoint.
+		raceTask.cancelChildren()
+		if first.sig != nil {
+			raceTask.complete(nil, &RuntimeError{Message: "break/continue outside loop"})
 			return
 		}
-	}()
+		raceTask.complete(first.value, first.err)
+	})
 
 	return raceTask, nil, nil
 }
@@ -2,10 +2,21 @@ package interpreter
 
 import "karl/ast"
 
-func (e *Evaluator) checkRuntimeBeforeEval() error {
+// safepoint is where running code notices that its task was canceled or that
+// the runtime is shutting down after a fatal task failure. The evaluator checks
+// it at statement boundaries, loop iterations, function calls and query rows,
+// so a task stops promptly even if it never reaches a yield point. In
+// deterministic mode it is also where the scheduler may preempt the task.
+func (e *Evaluator) safepoint() error {
 	if e.runtime != nil {
-		if err := e.runtime.getFatalTaskFailure(); err != nil {
-			return err
+		endWait := e.profileWait()
+		e.runtime.preempt()
+		if e.holdsLock {
+			e.runtime.yieldLock()
+		}
+		endWait()
+		if e.runtime.fatalRaised() {
+			return e.runtime.terminatedError()
 		}
 	}
 	if e.currentTask != nil && e.currentTask.canceled() {
@@ -19,22 +30,20 @@ func annotateErrorToken(node ast.Node, err error) {
 		return
 	}
 	if re, ok := err.(*RuntimeError); ok && re.Token == nil {
-		if tok := tokenFromNode(node); tok != nil {
+		if tok := ast.NodeToken(node); tok != nil {
 			re.Token = tok
 		}
 	}
 	if re, ok := err.(*RecoverableError); ok && re.Token == nil {
-		if tok := tokenFromNode(node); tok != nil {
+		if tok := ast.NodeToken(node); tok != nil {
 			re.Token = tok
 		}
 	}
 }
 
 func (e *Evaluator) checkRuntimeAfterEval(sig *Signal, err error) error {
-	if err == nil && sig == nil && e.runtime != nil {
-		if fatalErr := e.runtime.getFatalTaskFailure(); fatalErr != nil {
-			return fatalErr
-		}
+	if err == nil && sig == nil && e.runtime != nil && e.runtime.fatalRaised() {
+		return e.runtime.terminatedError()
 	}
 	return nil
 }
@@ -7,6 +7,9 @@ func (e *Evaluator) evalAwaitExpression(node *ast.AwaitExpression, env *Environm
 	if err != nil || sig != nil {
 		return val, sig, err
 	}
+	if s, ok := val.(*Schedule); ok {
+		val = s.task
+	}
 	task, ok := val.(*Task)
 	if !ok {
 		return nil, nil, &RuntimeError{Message: "wait expects task"}
@@ -15,6 +18,7 @@ func (e *Evaluator) evalAwaitExpression(node *ast.AwaitExpression, env *Environm
 	if e.currentTask != nil {
 		cancelCh = e.currentTask.cancelCh
 	}
+	defer e.waitSpan("wait", "await", map[string]interface{}{"task": task.id})()
 	return taskAwaitWithCancel(task, cancelCh, e.runtime)
 }
 
@@ -38,7 +42,7 @@ func (e *Evaluator) evalSpawnExpression(node *ast.SpawnExpression, env *Environm
 		children = append(children, child)
 	}
 
-	go func() {
+	e.runtime.goAsync(func() {
 		type result struct {
 			idx   int
 			value Value
@@ -48,38 +52,56 @@ func (e *Evaluator) evalSpawnExpression(node *ast.SpawnExpression, env *Environm
 
 		resultsCh := make(chan result, len(children))
 		for i, child := range children {
-			go func(idx int, t *Task) {
+			idx, t := i, child
+			e.runtime.goAsync(func() {
 				val, sig, err := taskAwaitWithCancel(t, join.cancelCh, e.runtime)
 				resultsCh <- result{idx: idx, value: val, sig: sig, err: err}
-			}(i, child)
+			})
 		}
 
 		out := make([]Value, len(children))
 		remaining := len(children)
 		for remaining > 0 {
-			select {
-			case <-join.cancelCh:
-				// canceled by user or parent; Cancel() already completed the task.
+			if err := e.runtime.park(func() bool {
+				return join.canceled() || len(resultsCh) > 0
+			}); err != nil {
+				join.cancelChildren()
+				join.complete(nil, err)
 				return
-			case r := <-resultsCh:
-				if r.err != nil {
-					// Fail fast: cancel remaining children and surface the error on the join task.
-					join.cancelChildren()
-					join.complete(nil, r.err)
-					return
-				}
-				if r.sig != nil {
-					join.cancelChildren()
-					join.complete(nil, &RuntimeError{Message: "break/continue outside loop"})
-					return
+			}
+			if join.canceled() {
+				return
+			}
+			var r result
+			canceled := false
+			e.runtime.blocking(func() {
+				select {
+				case <-join.cancelCh:
+					canceled = true
+				case r = <-resultsCh:
 				}
-				out[r.idx] = r.value
-				remaining--
+			})
+			if canceled {
+				// canceled by user or parent; Cancel() already completed the task.
+				return
+			}
+			if r.err != nil {
+				// Fail fast: cancel remaining children and surface the error on the join task.
+				join.cancelChildren()
+				join.complete(nil, r.err)
+				return
 			}
+			if r.sig != nil {
+				join.cancelChildren()
+				join.complete(nil, &RuntimeError{Message: "break/continue outside loop"})
+				return
+			}
+			out[r.idx] = r.value
+			remaining--
 		}
 
 		join.complete(&Array{Elements: out}, nil)
-	}()
+	})
 
 	return join, nil, nil
 }
@@ -87,7 +109,7 @@ func (e *Evaluator) evalSpawnExpression(node *ast.SpawnExpression, env *Environm
 func (e *Evaluator) spawnTask(expr ast.Expression, env *Environment, parent *Task, internal bool) (*Task, error) {
 	task := e.newTask(parent, internal)
 	taskEval := e.cloneForTask(task)
-	go func() {
+	e.runtime.goAsync(func() {
 		val, sig, err := taskEval.Eval(expr, env)
 		if err != nil {
 			taskEval.handleAsyncError(task, err)
@@ -98,6 +120,31 @@ func (e *Evaluator) spawnTask(expr ast.Expression, env *Environment, parent *Tas
 			return
 		}
 		task.complete(val, nil)
-	}()
+	})
 	return task, nil
 }
+
+// spawnCall starts fn(args...) as a child task of parent. Library builtins use
+// it to run user callbacks concurrently under their own bookkeeping task.
+func (e *Evaluator) spawnCall(fn Value, args []Value, parent *Task, internal bool) *Task {
+	task := e.newTask(parent, internal)
+	taskEval := e.cloneForTask(task)
+	e.runtime.goAsync(func() {
+		taskEval.completeWithCall(task, fn, args)
+	})
+	return task
+}
+
+// completeWithCall runs fn(args...) and settles task with the outcome.
+func (e *Evaluator) completeWithCall(task *Task, fn Value, args []Value) {
+	val, sig, err := e.applyFunction(fn, args)
+	if err != nil {
+		e.handleAsyncError(task, err)
+		return
+	}
+	if sig != nil {
+		task.complete(nil, &RuntimeError{Message: "break/continue outside loop"})
+		return
+	}
+	task.complete(val, nil)
+}
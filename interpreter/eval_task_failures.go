@@ -7,7 +7,17 @@ func (e *Evaluator) handleAsyncError(task *Task, err error) {
 		return
 	}
 	if exitErr, ok := err.(*ExitError); ok {
-		exitProcess(exitErr.Message)
+		// exit() from a task ends the whole program, whatever the failure
+		// policy: every task stops at its next safepoint and the root
+		// evaluation returns the ExitError.
+		if task != nil {
+			task.complete(nil, err)
+		}
+		if e.runtime == nil {
+			exitProcess(exitErr.Message)
+			return
+		}
+		e.runtime.setFatalTaskFailure(exitErr)
 		return
 	}
 	if task == nil {
@@ -36,22 +46,13 @@ func (e *Evaluator) CheckUnhandledTaskFailures() error {
 	if e.runtime == nil {
 		return nil
 	}
-	tasks := e.runtime.snapshotTasks()
+	tasks := e.runtime.snapshotFailedTasks()
 	msgs := []string{}
 	for _, t := range tasks {
-		if t == nil || t.internal {
-			continue
-		}
-		if !t.isDone() || t.isObserved() {
+		if t.isObserved() {
 			continue
 		}
 		err := t.getError()
-		if err == nil {
-			continue
-		}
-		if re, ok := err.(*RecoverableError); ok && re.Kind == "canceled" {
-			continue
-		}
 		msgs = append(msgs, FormatRuntimeError(err, t.source, t.filename))
 	}
 	if len(msgs) == 0 {
@@ -10,6 +10,14 @@ type Evaluator struct {
 
 	runtime     *runtimeState
 	currentTask *Task
+	// context holds the task-local values set by withContext. The map is
+	// never modified once an evaluator holds it.
+	context map[string]Value
+
+	// holdsLock is set while this evaluator's goroutine owns the interpreter
+	// lock. Task evaluators always do; the root evaluator takes the lock on
+	// entry to Eval.
+	holdsLock bool
 }
 
 func NewEvaluator() *Evaluator {
@@ -50,6 +58,17 @@ func (e *Evaluator) SetTaskFailurePolicy(policy string) error {
 	return e.runtime.setTaskFailurePolicy(policy)
 }
 
+// SetDeterministic switches the runtime to deterministic mode: spawned tasks run
+// cooperatively one at a time in an order drawn from seed, and sleep/now use a
+// virtual clock. Running the same program with the same seed replays the same
+// interleaving.
+func (e *Evaluator) SetDeterministic(seed int64) {
+	if e.runtime == nil {
+		e.runtime = newRuntimeState()
+	}
+	e.runtime.setDeterministic(seed)
+}
+
 func (e *Evaluator) SetProgramArgs(args []string) {
 	if e.runtime == nil {
 		e.runtime = newRuntimeState()
@@ -93,18 +112,26 @@ func (e *Evaluator) cloneForTask(task *Task) *Evaluator {
 		modules:     e.modules,
 		runtime:     e.runtime,
 		currentTask: task,
+		context:     e.context,
+		holdsLock:   true,
 	}
 }
 
 func (e *Evaluator) newTask(parent *Task, internal bool) *Task {
+	return e.newNamedTask(parent, internal, "")
+}
+
+func (e *Evaluator) newNamedTask(parent *Task, internal bool, name string) *Task {
 	if e.runtime == nil {
 		e.runtime = newRuntimeState()
 	}
 	t := newTask()
 	t.internal = internal
+	t.name = name
 	t.parent = parent
 	t.source = e.source
 	t.filename = e.filename
+	t.context = e.context
 	if parent != nil {
 		parent.addChild(t)
 	}
@@ -18,6 +18,7 @@ func (e *Evaluator) evalImportExpression(node *ast.ImportExpression, _ *Environm
 	if err != nil {
 		return nil, nil, err
 	}
+	e.coverProgram(module.filename, module.source, module.program)
 	factory := &Builtin{
 		Name: "moduleFactory",
 		Fn: func(_ *Evaluator, args []Value) (Value, error) {
@@ -31,6 +32,7 @@ func (e *Evaluator) evalImportExpression(node *ast.ImportExpression, _ *Environm
 				projectRoot: e.projectRoot,
 				modules:     e.modules,
 				runtime:     e.runtime,
+				holdsLock:   true,
 			}
 			val, sig, err := moduleEval.Eval(module.program, moduleEnv)
 			if err != nil {
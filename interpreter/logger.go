@@ -0,0 +1,310 @@
+package interpreter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type LogLevel int
+
+const (
+	LogDebug LogLevel = iota
+	LogInfo
+	LogWarn
+	LogError
+)
+
+var logLevelNames = [...]string{"debug", "info", "warn", "error"}
+
+func (l LogLevel) String() string { return logLevelNames[l] }
+
+func ParseLogLevel(name string) (LogLevel, bool) {
+	for i, n := range logLevelNames {
+		if strings.EqualFold(name, n) {
+			return LogLevel(i), true
+		}
+	}
+	return LogInfo, false
+}
+
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// Logger is a structured logger value. Loggers are immutable: child() and
+// logger(options) derive new ones that share the sink.
+type Logger struct {
+	level  LogLevel
+	format string
+	sink   logSink
+	fields map[string]Value
+}
+
+func (l *Logger) Type() ValueType { return LOGGER }
+func (l *Logger) Inspect() string {
+	return fmt.Sprintf("<logger %s %s>", l.level, l.format)
+}
+
+type logRecord struct {
+	time   int64 // epoch milliseconds
+	level  LogLevel
+	msg    string
+	fields map[string]Value
+}
+
+// logSink receives records that passed the level threshold. Stream sinks get
+// the encoded line; channel sinks get the record as an object.
+type logSink interface {
+	write(e *Evaluator, format string, rec logRecord) error
+}
+
+// Stream writes are serialized across loggers so lines never interleave.
+var logWriteMu sync.Mutex
+
+// streamSink writes to a fixed writer, or to os.Stderr/os.Stdout as they are
+// at write time when w is nil.
+type streamSink struct {
+	w      io.Writer
+	stdout bool
+}
+
+func (s streamSink) write(_ *Evaluator, format string, rec logRecord) error {
+	w := s.w
+	if w == nil {
+		w = os.Stderr
+		if s.stdout {
+			w = os.Stdout
+		}
+	}
+	line := encodeLogRecord(format, rec)
+	logWriteMu.Lock()
+	defer logWriteMu.Unlock()
+	if _, err := io.WriteString(w, line); err != nil {
+		return recoverableError("logger", "logger error: "+err.Error())
+	}
+	return nil
+}
+
+// fileSink appends each line to path, opening the file per record so no
+// descriptor outlives the program or blocks the file from being rotated.
+type fileSink struct {
+	path string
+}
+
+func (s fileSink) write(_ *Evaluator, format string, rec logRecord) error {
+	line := encodeLogRecord(format, rec)
+	logWriteMu.Lock()
+	defer logWriteMu.Unlock()
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err == nil {
+		_, err = file.WriteString(line)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return recoverableError("logger", "logger error: "+err.Error())
+	}
+	return nil
+}
+
+// channelSink sends `{ time, level, msg, fields, line }` to a Karl channel,
+// blocking like send() does.
+type channelSink struct {
+	ch *Channel
+}
+
+func (s channelSink) write(e *Evaluator, format string, rec logRecord) error {
+	record := &Object{Pairs: map[string]Value{
+		"time":   &String{Value: formatLogTime(rec.time)},
+		"level":  &String{Value: rec.level.String()},
+		"msg":    &String{Value: rec.msg},
+		"fields": contextObject(rec.fields),
+		"line":   &String{Value: strings.TrimSuffix(encodeLogRecord(format, rec), "\n")},
+	}}
+	return e.channelSend(s.ch, record)
+}
+
+func formatLogTime(ms int64) string {
+	return time.UnixMilli(ms).UTC().Format("2006-01-02T15:04:05.000Z07:00")
+}
+
+// encodeLogRecord renders one line. Both encoders put time, level and msg
+// first and the fields after them sorted by key; a field cannot replace one
+// of those three.
+func encodeLogRecord(format string, rec logRecord) string {
+	keys := make([]string, 0, len(rec.fields))
+	for k := range rec.fields {
+		if k == "time" || k == "level" || k == "msg" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	if format == LogFormatJSON {
+		b.WriteString(`{"time":"`)
+		b.WriteString(formatLogTime(rec.time))
+		b.WriteString(`","level":"`)
+		b.WriteString(rec.level.String())
+		b.WriteString(`"`)
+		b.WriteString(`,"msg":`)
+		b.Write(jsonLogValue(&String{Value: rec.msg}))
+		for _, k := range keys {
+			b.WriteString(",")
+			b.Write(jsonLogValue(&String{Value: k}))
+			b.WriteString(":")
+			b.Write(jsonLogValue(rec.fields[k]))
+		}
+		b.WriteString("}\n")
+		return b.String()
+	}
+	b.WriteString("time=")
+	b.WriteString(formatLogTime(rec.time))
+	b.WriteString(" level=")
+	b.WriteString(rec.level.String())
+	b.WriteString(" msg=")
+	b.WriteString(logfmtValue(rec.msg))
+	for _, k := range keys {
+		b.WriteString(" ")
+		b.WriteString(k)
+		b.WriteString("=")
+		b.WriteString(logfmtValue(formatLogValue(rec.fields[k])))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// jsonLogValue encodes like encodeJson (without HTML escaping), falling back
+// to the printed form for values JSON cannot represent (functions, tasks,
+// NaN, ...).
+func jsonLogValue(val Value) []byte {
+	if enc, err := encodeJSONValue(val); err == nil {
+		if data, err := marshalLogJSON(enc); err == nil {
+			return data
+		}
+	}
+	data, _ := marshalLogJSON(formatLogValue(val))
+	return data
+}
+
+func marshalLogJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func logfmtValue(s string) string {
+	if s == "" || strings.ContainsAny(s, " =\"\t\r\n") || !strconv.CanBackquote(s) {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// log emits a record at level with the logger's fields, the selected
+// task-local context keys (see logContext) and the call's fields, later ones
+// winning.
+func (l *Logger) log(e *Evaluator, level LogLevel, msg string, fields map[string]Value) error {
+	if level < l.level {
+		return nil
+	}
+	all := make(map[string]Value, len(l.fields)+len(fields))
+	for k, v := range e.logContextFields() {
+		all[k] = v
+	}
+	for k, v := range l.fields {
+		all[k] = v
+	}
+	for k, v := range fields {
+		all[k] = v
+	}
+	now := time.Now().UnixMilli()
+	if e.runtime != nil {
+		now = e.runtime.nowMillis()
+	}
+	return l.sink.write(e, l.format, logRecord{time: now, level: level, msg: msg, fields: all})
+}
+
+func (l *Logger) child(fields map[string]Value) *Logger {
+	next := *l
+	next.fields = make(map[string]Value, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		next.fields[k] = v
+	}
+	for k, v := range fields {
+		next.fields[k] = v
+	}
+	return &next
+}
+
+// loggerConfig is the default configuration logger() starts from, set by
+// the embedder (or `karl run --log-level/--log-format`).
+type loggerConfig struct {
+	level  LogLevel
+	format string
+	output io.Writer
+}
+
+func defaultLoggerConfig() loggerConfig {
+	return loggerConfig{level: LogInfo, format: LogFormatText}
+}
+
+// SetLogLevel sets the threshold of loggers created by logger(): "debug",
+// "info" (default), "warn" or "error".
+func (e *Evaluator) SetLogLevel(level string) error {
+	parsed, ok := ParseLogLevel(level)
+	if !ok {
+		return fmt.Errorf("invalid log level: %s", level)
+	}
+	e.updateLoggerConfig(func(c *loggerConfig) { c.level = parsed })
+	return nil
+}
+
+// SetLogFormat selects the "text" (logfmt, default) or "json" (JSON lines)
+// encoder for loggers created by logger().
+func (e *Evaluator) SetLogFormat(format string) error {
+	if format != LogFormatText && format != LogFormatJSON {
+		return fmt.Errorf("invalid log format: %s", format)
+	}
+	e.updateLoggerConfig(func(c *loggerConfig) { c.format = format })
+	return nil
+}
+
+// SetLogOutput sends the default logger sink to w instead of stderr.
+func (e *Evaluator) SetLogOutput(w io.Writer) {
+	e.updateLoggerConfig(func(c *loggerConfig) { c.output = w })
+}
+
+func (e *Evaluator) updateLoggerConfig(update func(*loggerConfig)) {
+	if e.runtime == nil {
+		e.runtime = newRuntimeState()
+	}
+	r := e.runtime
+	r.mu.Lock()
+	update(&r.loggerConfig)
+	r.mu.Unlock()
+}
+
+func (e *Evaluator) defaultLogger() *Logger {
+	config := defaultLoggerConfig()
+	if e.runtime != nil {
+		e.runtime.mu.Lock()
+		config = e.runtime.loggerConfig
+		e.runtime.mu.Unlock()
+	}
+	return &Logger{level: config.level, format: config.format, sink: streamSink{w: config.output}}
+}
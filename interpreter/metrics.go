@@ -0,0 +1,313 @@
+package interpreter
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	metricCounter   = "counter"
+	metricGauge     = "gauge"
+	metricHistogram = "histogram"
+)
+
+var (
+	metricNamePattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNamePattern  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+	// The Prometheus client defaults, in seconds.
+	defaultHistogramBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+)
+
+// metricsRegistry holds every metric of a runtime. A metric is a family (name,
+// kind, help, buckets) with one series per label set; asking for the same
+// name and labels again returns the same series. The registry has its own
+// lock because MetricsText may be called from any goroutine.
+type metricsRegistry struct {
+	mu       sync.Mutex
+	families map[string]*metricFamily
+
+	// Unlabeled runtime series, kept at hand since every task touches them.
+	tasksSpawned, tasksFailed, tasksCanceled *Metric
+}
+
+type metricFamily struct {
+	name    string
+	kind    string
+	help    string
+	buckets []float64
+	series  map[string]*Metric
+}
+
+type labelPair struct {
+	name, value string
+}
+
+// Metric is one series: a counter, gauge or histogram with fixed labels.
+type Metric struct {
+	registry *metricsRegistry
+	family   *metricFamily
+	labels   []labelPair
+
+	value float64 // counter and gauge
+
+	// Histogram observations per bucket (not cumulative), plus the overflow
+	// past the last bucket in the final slot.
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func (m *Metric) Type() ValueType { return METRIC }
+func (m *Metric) Inspect() string {
+	return fmt.Sprintf("<%s %s%s>", m.family.kind, m.family.name, formatLabels(m.labels, ""))
+}
+
+func newMetricsRegistry() *metricsRegistry {
+	r := &metricsRegistry{families: map[string]*metricFamily{}}
+	r.registerRuntimeMetrics()
+	return r
+}
+
+// metric returns the series for name and labels, creating it when needed.
+// buckets only matter for histograms; nil means the default buckets.
+func (r *metricsRegistry) metric(kind, name string, labels map[string]string, buckets []float64) (*Metric, error) {
+	if !metricNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid metric name: %q", name)
+	}
+	pairs := make([]labelPair, 0, len(labels))
+	for k, v := range labels {
+		if !labelNamePattern.MatchString(k) || strings.HasPrefix(k, "__") {
+			return nil, fmt.Errorf("invalid label name: %q", k)
+		}
+		if kind == metricHistogram && k == "le" {
+			return nil, fmt.Errorf("histogram %s cannot use label \"le\"", name)
+		}
+		pairs = append(pairs, labelPair{name: k, value: v})
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].name < pairs[j].name })
+	if kind == metricHistogram && buckets == nil {
+		buckets = defaultHistogramBuckets
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	family, ok := r.families[name]
+	if !ok {
+		family = &metricFamily{name: name, kind: kind, buckets: buckets, series: map[string]*Metric{}}
+		r.families[name] = family
+	}
+	if family.kind != kind {
+		return nil, fmt.Errorf("metric %s is already registered as a %s", name, family.kind)
+	}
+	if kind == metricHistogram && !equalBuckets(family.buckets, buckets) {
+		return nil, fmt.Errorf("histogram %s is already registered with different buckets", name)
+	}
+	key := formatLabels(pairs, "")
+	if m, ok := family.series[key]; ok {
+		return m, nil
+	}
+	m := &Metric{registry: r, family: family, labels: pairs}
+	if kind == metricHistogram {
+		m.counts = make([]uint64, len(buckets)+1)
+	}
+	family.series[key] = m
+	return m, nil
+}
+
+func equalBuckets(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *Metric) add(delta float64) {
+	m.registry.mu.Lock()
+	m.value += delta
+	m.registry.mu.Unlock()
+}
+
+func (m *Metric) set(value float64) {
+	m.registry.mu.Lock()
+	m.value = value
+	m.registry.mu.Unlock()
+}
+
+func (m *Metric) observe(value float64) {
+	m.registry.mu.Lock()
+	defer m.registry.mu.Unlock()
+	i := sort.SearchFloat64s(m.family.buckets, value)
+	m.counts[i]++
+	m.count++
+	m.sum += value
+}
+
+type metricSnapshot struct {
+	value  float64
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func (m *Metric) snapshot() metricSnapshot {
+	m.registry.mu.Lock()
+	defer m.registry.mu.Unlock()
+	return metricSnapshot{value: m.value, counts: append([]uint64(nil), m.counts...), count: m.count, sum: m.sum}
+}
+
+// text renders the registry in the Prometheus text exposition format,
+// families and series sorted so the output is stable.
+func (r *metricsRegistry) text() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		family := r.families[name]
+		if len(family.series) == 0 {
+			continue
+		}
+		if family.help != "" {
+			fmt.Fprintf(&b, "# HELP %s %s\n", name, escapeHelp(family.help))
+		}
+		fmt.Fprintf(&b, "# TYPE %s %s\n", name, family.kind)
+		keys := make([]string, 0, len(family.series))
+		for key := range family.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			m := family.series[key]
+			if family.kind != metricHistogram {
+				fmt.Fprintf(&b, "%s%s %s\n", name, key, formatMetricValue(m.value))
+				continue
+			}
+			var cumulative uint64
+			for i, upper := range family.buckets {
+				cumulative += m.counts[i]
+				fmt.Fprintf(&b, "%s_bucket%s %d\n", name, formatLabels(m.labels, formatMetricValue(upper)), cumulative)
+			}
+			fmt.Fprintf(&b, "%s_bucket%s %d\n", name, formatLabels(m.labels, "+Inf"), m.count)
+			fmt.Fprintf(&b, "%s_sum%s %s\n", name, key, formatMetricValue(m.sum))
+			fmt.Fprintf(&b, "%s_count%s %d\n", name, key, m.count)
+		}
+	}
+	return b.String()
+}
+
+// formatLabels renders `{a="1",b="2"}`, adding `le` last when given; no
+// labels render as the empty string.
+func formatLabels(labels []labelPair, le string) string {
+	if len(labels) == 0 && le == "" {
+		return ""
+	}
+	parts := make([]string, 0, len(labels)+1)
+	for _, l := range labels {
+		parts = append(parts, l.name+"=\""+escapeLabelValue(l.value)+"\"")
+	}
+	if le != "" {
+		parts = append(parts, "le=\""+le+"\"")
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(s string) string { return labelValueEscaper.Replace(s) }
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeHelp(s string) string { return helpEscaper.Replace(s) }
+
+func formatMetricValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Runtime metrics. Series without labels exist from the start so scrapes
+// see zeros rather than missing metrics.
+const (
+	metricTasksSpawned     = "karl_tasks_spawned_total"
+	metricTasksFailed      = "karl_tasks_failed_total"
+	metricTasksCanceled    = "karl_tasks_canceled_total"
+	metricHTTPRequests     = "karl_http_requests_total"
+	metricHTTPDuration     = "karl_http_request_duration_seconds"
+	metricRecoverableError = "karl_recoverable_errors_total"
+)
+
+func (r *metricsRegistry) registerRuntimeMetrics() {
+	r.describe(metricCounter, metricTasksSpawned, "Tasks started, including internal ones.")
+	r.describe(metricCounter, metricTasksFailed, "Tasks that finished with an error other than cancellation.")
+	r.describe(metricCounter, metricTasksCanceled, "Tasks that finished canceled.")
+	r.describe(metricCounter, metricHTTPRequests, "http() calls by method and status code (\"error\" when no response arrived).")
+	r.describe(metricHistogram, metricHTTPDuration, "http() call latency in seconds.")
+	r.describe(metricCounter, metricRecoverableError, "Recoverable errors raised by builtins, by kind.")
+	r.tasksSpawned, _ = r.metric(metricCounter, metricTasksSpawned, nil, nil)
+	r.tasksFailed, _ = r.metric(metricCounter, metricTasksFailed, nil, nil)
+	r.tasksCanceled, _ = r.metric(metricCounter, metricTasksCanceled, nil, nil)
+}
+
+// describe registers an empty family with help text.
+func (r *metricsRegistry) describe(kind, name, help string) {
+	family := &metricFamily{name: name, kind: kind, help: help, series: map[string]*Metric{}}
+	if kind == metricHistogram {
+		family.buckets = defaultHistogramBuckets
+	}
+	r.families[name] = family
+}
+
+func (r *metricsRegistry) count(name string, labels map[string]string, delta float64) {
+	if m, err := r.metric(metricCounter, name, labels, nil); err == nil {
+		m.add(delta)
+	}
+}
+
+func (r *metricsRegistry) observe(name string, labels map[string]string, value float64) {
+	if m, err := r.metric(metricHistogram, name, labels, nil); err == nil {
+		m.observe(value)
+	}
+}
+
+// countRecoverable records a recoverable error once, however many builtins
+// it passes through on its way up. Cancellation is not an error worth
+// counting; it shows up in karl_tasks_canceled_total.
+func (r *runtimeState) countRecoverable(err error) {
+	re, ok := err.(*RecoverableError)
+	if !ok || re.counted || re.Kind == "canceled" {
+		return
+	}
+	re.counted = true
+	r.metrics.count(metricRecoverableError, map[string]string{"kind": re.Kind}, 1)
+}
+
+// MetricsText renders the program's metrics in the Prometheus text format.
+// It is safe to call from any goroutine.
+func (e *Evaluator) MetricsText() string {
+	if e.runtime == nil {
+		e.runtime = newRuntimeState()
+	}
+	return e.runtime.metrics.text()
+}
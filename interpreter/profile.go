@@ -0,0 +1,320 @@
+package interpreter
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"karl/ast"
+)
+
+// cpuProfileInterval is how often the CPU profiler samples Karl call stacks.
+const cpuProfileInterval = 10 * time.Millisecond
+
+// profileFrame is one Karl call: the function (named after its let binding)
+// and the line it is currently evaluating.
+type profileFrame struct {
+	name      string
+	file      string
+	startLine int
+	line      int
+}
+
+// callStack is the Karl call stack of one task (or of the main program). It
+// is only maintained while a profiler runs. The owning task pushes and pops;
+// the CPU sampler reads it from another goroutine, hence the mutex.
+type callStack struct {
+	mu      sync.Mutex
+	frames  []profileFrame
+	waiting int
+}
+
+func (s *callStack) push(frame profileFrame) int {
+	s.mu.Lock()
+	depth := len(s.frames)
+	s.frames = append(s.frames, frame)
+	s.mu.Unlock()
+	return depth
+}
+
+// pop truncates the stack back to depth, as returned by the matching push.
+func (s *callStack) pop(depth int) {
+	s.mu.Lock()
+	if depth < len(s.frames) {
+		s.frames = s.frames[:depth]
+	}
+	s.mu.Unlock()
+}
+
+func (s *callStack) setLine(line int) {
+	s.mu.Lock()
+	if n := len(s.frames); n > 0 {
+		s.frames[n-1].line = line
+	}
+	s.mu.Unlock()
+}
+
+// wait marks the stack as blocked (not burning CPU) until the returned
+// function is called.
+func (s *callStack) wait() func() {
+	s.mu.Lock()
+	s.waiting++
+	s.mu.Unlock()
+	return func() {
+		s.mu.Lock()
+		s.waiting--
+		s.mu.Unlock()
+	}
+}
+
+// snapshot copies the frames, or returns nil when the stack is empty or
+// blocked.
+func (s *callStack) snapshot(running bool) []profileFrame {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.frames) == 0 || (running && s.waiting > 0) {
+		return nil
+	}
+	return append([]profileFrame(nil), s.frames...)
+}
+
+// Anonymous functions and top-level code get bracketed names: pprof strips
+// <...> from function names as if they were C++ template arguments.
+const (
+	profileLambdaName   = "[lambda]"
+	profileTopLevelName = "[toplevel]"
+)
+
+func functionFrame(f *Function) profileFrame {
+	name := f.Name
+	if name == "" {
+		name = profileLambdaName
+	}
+	return profileFrame{name: name, file: f.Filename, startLine: f.Line, line: f.Line}
+}
+
+// profileSamples aggregates values per distinct stack.
+type profileSamples struct {
+	mu      sync.Mutex
+	samples map[string]*profileSample
+}
+
+type profileSample struct {
+	frames []profileFrame
+	values []int64
+}
+
+func (p *profileSamples) add(frames []profileFrame, values ...int64) {
+	var key strings.Builder
+	for _, f := range frames {
+		key.WriteString(f.name)
+		key.WriteByte(0)
+		key.WriteString(f.file)
+		key.WriteByte(0)
+		key.WriteString(strconv.Itoa(f.startLine))
+		key.WriteByte(':')
+		key.WriteString(strconv.Itoa(f.line))
+		key.WriteByte(0)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.samples == nil {
+		p.samples = make(map[string]*profileSample)
+	}
+	sample, ok := p.samples[key.String()]
+	if !ok {
+		sample = &profileSample{frames: frames, values: make([]int64, len(values))}
+		p.samples[key.String()] = sample
+	}
+	for i, v := range values {
+		sample.values[i] += v
+	}
+}
+
+// cpuProfiler samples the stack of every task that is evaluating Karl code.
+// Tasks hold the interpreter lock while they evaluate, so blocked tasks mark
+// their stack as waiting and are skipped.
+type cpuProfiler struct {
+	w       io.Writer
+	start   time.Time
+	samples profileSamples
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func (p *cpuProfiler) run(r *runtimeState) {
+	defer close(p.done)
+	ticker := time.NewTicker(cpuProfileInterval)
+	defer ticker.Stop()
+	last := p.start
+	for {
+		select {
+		case <-p.stop:
+			return
+		case now := <-ticker.C:
+			// Weigh each sample by the time since the previous one: on a
+			// busy machine the sampler may not get to run every interval.
+			elapsed := now.Sub(last).Nanoseconds()
+			last = now
+			for _, stack := range r.callStacks() {
+				if frames := stack.snapshot(true); frames != nil {
+					p.samples.add(frames, 1, elapsed)
+				}
+			}
+		}
+	}
+}
+
+// allocProfiler counts the Arrays, Objects and Strings Karl code builds, by
+// the source location that built them.
+type allocProfiler struct {
+	w       io.Writer
+	start   time.Time
+	samples profileSamples
+}
+
+func (r *runtimeState) callStacks() []*callStack {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stacks := make([]*callStack, 0, len(r.tasks)+1)
+	stacks = append(stacks, &r.mainFrames)
+	for t := range r.tasks {
+		stacks = append(stacks, &t.frames)
+	}
+	return stacks
+}
+
+func (r *runtimeState) profiling() bool {
+	return r != nil && (r.cpuProfile.Load() != nil || r.allocProfile.Load() != nil)
+}
+
+// profileStack returns the evaluator's call stack while a profiler runs, and
+// nil otherwise.
+func (e *Evaluator) profileStack() *callStack {
+	if !e.runtime.profiling() {
+		return nil
+	}
+	if e.currentTask != nil {
+		return &e.currentTask.frames
+	}
+	return &e.runtime.mainFrames
+}
+
+// profileWait marks the evaluator as blocked for the CPU profiler until the
+// returned function is called; it is never nil.
+func (e *Evaluator) profileWait() func() {
+	if s := e.profileStack(); s != nil {
+		return s.wait()
+	}
+	return func() {}
+}
+
+// recordAlloc attributes a value built at line of the current file to the
+// running Karl stack.
+func (e *Evaluator) recordAlloc(line int, val Value) {
+	p := e.runtime.allocProfiler()
+	if p == nil {
+		return
+	}
+	var size int64
+	switch v := val.(type) {
+	case *String:
+		size = 16 + int64(len(v.Value))
+	case *Array:
+		size = 24 + 16*int64(len(v.Elements))
+	case *Object:
+		size = 48
+		for k := range v.Pairs {
+			size += 32 + int64(len(k))
+		}
+	default:
+		return
+	}
+	stack := e.profileStack()
+	frames := stack.snapshot(false)
+	if frames == nil {
+		frames = []profileFrame{{name: profileTopLevelName, file: e.filename, startLine: 1}}
+	}
+	frames[len(frames)-1].line = line
+	p.samples.add(frames, 1, size)
+}
+
+// recordNodeAlloc attributes values built directly by an expression (literals,
+// concatenation, ranges, slices, queries) to its line.
+func (e *Evaluator) recordNodeAlloc(node ast.Node, val Value) {
+	switch n := node.(type) {
+	case *ast.InfixExpression:
+		if n.Operator == "|>" {
+			// Attributed like the call it stands for, in evalPipeline.
+			return
+		}
+	case *ast.ArrayLiteral, *ast.ObjectLiteral, *ast.StructInitExpression, *ast.StringLiteral,
+		*ast.RangeExpression, *ast.SliceExpression, *ast.QueryExpression:
+	default:
+		return
+	}
+	if tok := ast.NodeToken(node); tok != nil {
+		e.recordAlloc(tok.Line, val)
+	}
+}
+
+func (r *runtimeState) allocProfiler() *allocProfiler {
+	if r == nil {
+		return nil
+	}
+	return r.allocProfile.Load()
+}
+
+// StartCPUProfile samples the Karl call stacks of all tasks until
+// StopCPUProfile, which writes them to w as a gzipped pprof profile. Call it
+// before Eval.
+func (e *Evaluator) StartCPUProfile(w io.Writer) {
+	if e.runtime == nil {
+		e.runtime = newRuntimeState()
+	}
+	p := &cpuProfiler{w: w, start: time.Now(), stop: make(chan struct{}), done: make(chan struct{})}
+	e.runtime.cpuProfile.Store(p)
+	go p.run(e.runtime)
+}
+
+// StopCPUProfile stops sampling and writes the profile.
+func (e *Evaluator) StopCPUProfile() error {
+	if e.runtime == nil {
+		return nil
+	}
+	p := e.runtime.cpuProfile.Swap(nil)
+	if p == nil {
+		return nil
+	}
+	close(p.stop)
+	<-p.done
+	return writeProfile(p.w, p.start, &p.samples,
+		[]profileValueType{{"samples", "count"}, {"cpu", "nanoseconds"}},
+		profileValueType{"cpu", "nanoseconds"}, cpuProfileInterval.Nanoseconds())
+}
+
+// StartAllocProfile records the Arrays, Objects and Strings built by Karl
+// code until StopAllocProfile, which writes them to w as a gzipped pprof
+// profile. Sizes are estimates of the Go memory behind each value.
+func (e *Evaluator) StartAllocProfile(w io.Writer) {
+	if e.runtime == nil {
+		e.runtime = newRuntimeState()
+	}
+	e.runtime.allocProfile.Store(&allocProfiler{w: w, start: time.Now()})
+}
+
+// StopAllocProfile stops recording and writes the profile.
+func (e *Evaluator) StopAllocProfile() error {
+	if e.runtime == nil {
+		return nil
+	}
+	p := e.runtime.allocProfile.Swap(nil)
+	if p == nil {
+		return nil
+	}
+	return writeProfile(p.w, p.start, &p.samples,
+		[]profileValueType{{"alloc_objects", "count"}, {"alloc_space", "bytes"}},
+		profileValueType{"space", "bytes"}, 1)
+}
@@ -0,0 +1,165 @@
+package interpreter
+
+import (
+	"compress/gzip"
+	"io"
+	"sort"
+	"time"
+)
+
+// This file encodes profiles in the pprof format (profile.proto, gzipped) by
+// hand; the format is small enough that it does not warrant a dependency.
+
+type profileValueType struct {
+	typ  string
+	unit string
+}
+
+// profile.proto field numbers.
+const (
+	pprofSampleType    = 1
+	pprofSample        = 2
+	pprofLocation      = 4
+	pprofFunction      = 5
+	pprofStringTable   = 6
+	pprofTimeNanos     = 9
+	pprofDurationNanos = 10
+	pprofPeriodType    = 11
+	pprofPeriod        = 12
+)
+
+type profileEncoder struct {
+	strings   []string
+	stringIDs map[string]int64
+	functions map[profileFrame]uint64
+	locations map[profileFrame]uint64
+	buf       []byte
+}
+
+func (p *profileEncoder) str(s string) int64 {
+	if id, ok := p.stringIDs[s]; ok {
+		return id
+	}
+	id := int64(len(p.strings))
+	p.strings = append(p.strings, s)
+	p.stringIDs[s] = id
+	return id
+}
+
+// location returns the id of the location for frame, emitting it (and its
+// function) the first time.
+func (p *profileEncoder) location(frame profileFrame) uint64 {
+	if id, ok := p.locations[frame]; ok {
+		return id
+	}
+	fnKey := profileFrame{name: frame.name, file: frame.file, startLine: frame.startLine}
+	fnID, ok := p.functions[fnKey]
+	if !ok {
+		fnID = uint64(len(p.functions) + 1)
+		p.functions[fnKey] = fnID
+		var fn []byte
+		fn = protoVarintField(fn, 1, fnID)
+		fn = protoVarintField(fn, 2, uint64(p.str(frame.name)))
+		fn = protoVarintField(fn, 3, uint64(p.str(frame.name)))
+		fn = protoVarintField(fn, 4, uint64(p.str(frame.file)))
+		fn = protoVarintField(fn, 5, uint64(frame.startLine))
+		p.buf = protoBytesField(p.buf, pprofFunction, fn)
+	}
+	id := uint64(len(p.locations) + 1)
+	p.locations[frame] = id
+	var line []byte
+	line = protoVarintField(line, 1, fnID)
+	line = protoVarintField(line, 2, uint64(frame.line))
+	var loc []byte
+	loc = protoVarintField(loc, 1, id)
+	loc = protoBytesField(loc, 4, line)
+	p.buf = protoBytesField(p.buf, pprofLocation, loc)
+	return id
+}
+
+func (p *profileEncoder) valueType(field int, vt profileValueType) {
+	var msg []byte
+	msg = protoVarintField(msg, 1, uint64(p.str(vt.typ)))
+	msg = protoVarintField(msg, 2, uint64(p.str(vt.unit)))
+	p.buf = protoBytesField(p.buf, field, msg)
+}
+
+func writeProfile(w io.Writer, start time.Time, samples *profileSamples, types []profileValueType, periodType profileValueType, period int64) error {
+	p := &profileEncoder{
+		strings:   []string{""},
+		stringIDs: map[string]int64{"": 0},
+		functions: make(map[profileFrame]uint64),
+		locations: make(map[profileFrame]uint64),
+	}
+	for _, vt := range types {
+		p.valueType(pprofSampleType, vt)
+	}
+
+	samples.mu.Lock()
+	keys := make([]string, 0, len(samples.samples))
+	for key := range samples.samples {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		sample := samples.samples[key]
+		ids := make([]uint64, 0, len(sample.frames))
+		// pprof lists locations leaf first.
+		for i := len(sample.frames) - 1; i >= 0; i-- {
+			ids = append(ids, p.location(sample.frames[i]))
+		}
+		var msg []byte
+		msg = protoPackedField(msg, 1, ids)
+		values := make([]uint64, len(sample.values))
+		for i, v := range sample.values {
+			values[i] = uint64(v)
+		}
+		msg = protoPackedField(msg, 2, values)
+		p.buf = protoBytesField(p.buf, pprofSample, msg)
+	}
+	samples.mu.Unlock()
+
+	p.buf = protoVarintField(p.buf, pprofTimeNanos, uint64(start.UnixNano()))
+	p.buf = protoVarintField(p.buf, pprofDurationNanos, uint64(time.Since(start).Nanoseconds()))
+	p.valueType(pprofPeriodType, periodType)
+	p.buf = protoVarintField(p.buf, pprofPeriod, uint64(period))
+	for _, s := range p.strings {
+		p.buf = protoBytesField(p.buf, pprofStringTable, []byte(s))
+	}
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(p.buf); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func protoVarint(buf []byte, v uint64) []byte {
+	for v >= 0x80 {
+		buf = append(buf, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(buf, byte(v))
+}
+
+func protoVarintField(buf []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return buf
+	}
+	buf = protoVarint(buf, uint64(field)<<3)
+	return protoVarint(buf, v)
+}
+
+func protoBytesField(buf []byte, field int, data []byte) []byte {
+	buf = protoVarint(buf, uint64(field)<<3|2)
+	buf = protoVarint(buf, uint64(len(data)))
+	return append(buf, data...)
+}
+
+func protoPackedField(buf []byte, field int, values []uint64) []byte {
+	var data []byte
+	for _, v := range values {
+		data = protoVarint(data, v)
+	}
+	return protoBytesField(buf, field, data)
+}
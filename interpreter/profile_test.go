@@ -0,0 +1,65 @@
+package interpreter
+
+import (
+	"bytes"
+	"testing"
+
+	"karl/lexer"
+	"karl/parser"
+)
+
+// TestCPUProfileSamplesKarlStacksAcrossTasks has each side spin until the
+// sampler has caught it inside fib, so the result does not depend on how the
+// 10ms ticks happen to fall.
+func TestCPUProfileSamplesKarlStacksAcrossTasks(t *testing.T) {
+	input := `
+let fib = (n) -> if n <= 1 { n } else { fib(n - 1) + fib(n - 2) }
+let spin = (root) -> for !sampledIn(root) { fib(12) }
+let worker = () -> spin("worker")
+let t = & worker()
+spin("[toplevel]")
+wait t
+`
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		t.Fatalf("parse errors: %v", errs)
+	}
+
+	var buf bytes.Buffer
+	eval := NewEvaluatorWithSourceAndFilename(input, "<test>")
+	eval.StartCPUProfile(&buf)
+	profiler := eval.runtime.cpuProfile.Load()
+	env := NewBaseEnvironment()
+	env.Define("sampledIn", &Builtin{Name: "sampledIn", Fn: func(_ *Evaluator, args []Value) (Value, error) {
+		return &Boolean{Value: sampledInFib(&profiler.samples, args[0].(*String).Value)}, nil
+	}})
+	if _, _, err := eval.Eval(program, env); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := eval.StopCPUProfile(); err != nil {
+		t.Fatalf("StopCPUProfile: %v", err)
+	}
+
+	for _, root := range []string{"worker", "[toplevel]"} {
+		if !sampledInFib(&profiler.samples, root) {
+			t.Fatalf("expected a sample in fib under %s", root)
+		}
+	}
+	if buf.Len() == 0 {
+		t.Fatalf("expected a profile to be written")
+	}
+}
+
+// sampledInFib reports whether some sample has fib on top of a stack whose
+// outermost frame is root.
+func sampledInFib(samples *profileSamples, root string) bool {
+	samples.mu.Lock()
+	defer samples.mu.Unlock()
+	for _, s := range samples.samples {
+		if n := len(s.frames); n >= 2 && s.frames[0].name == root && s.frames[n-1].name == "fib" {
+			return true
+		}
+	}
+	return false
+}
@@ -3,19 +3,28 @@ package interpreter
 import (
 	"bufio"
 	"io"
+	"math/rand"
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 // runtimeState is shared across Evaluator instances (main file, imported modules,
 // spawned tasks). It lets us inspect task outcomes at the end of a run.
 type runtimeState struct {
-	mu                sync.Mutex
-	tasks             map[*Task]struct{}
+	mu sync.Mutex
+
+	// tasks holds unfinished tasks only. A task leaves it when it completes,
+	// so long-running sessions do not accumulate finished tasks; failed ones
+	// nobody has observed yet move to failed until they are awaited or
+	// reported at the end of the run.
+	tasks  map[*Task]struct{}
+	failed map[*Task]struct{}
+
 	taskFailurePolicy string
 	fatalTaskFailure  error
-	fatalOnce         sync.Once
+	fatalRaisedFlag   atomic.Bool
 	fatalCh           chan struct{}
 	argv              []string
 	programPath       *string
@@ -24,18 +33,64 @@ type runtimeState struct {
 	input             io.Reader
 	inputReader       *bufio.Reader
 	inputMu           sync.Mutex
+
+	nextTaskID   atomic.Uint64
+	mainPosition sourcePosition
+
+	// Shutdown hooks registered by atExit and onSignal (see runtime_shutdown.go).
+	exitHooks      []Value
+	signalHandlers map[string][]Value
+
+	// Context keys log appends to each line (see builtins_context.go), and
+	// the configuration logger() starts from (see logger.go).
+	logContextKeys []string
+	loggerConfig   loggerConfig
+
+	// counter/gauge/histogram values and the runtime's own metrics.
+	metrics *metricsRegistry
+
+	// secrets holds every secret() value so error messages can be redacted.
+	secrets secretSet
+
+	// trace is set while StartTrace records events (see trace.go).
+	trace atomic.Pointer[tracer]
+
+	// CPU and allocation profilers (see profile.go). mainFrames is the call
+	// stack of code that runs outside any task.
+	cpuProfile   atomic.Pointer[cpuProfiler]
+	allocProfile atomic.Pointer[allocProfiler]
+	mainFrames   callStack
+
+	// cover counts statement and match arm executions (see coverage.go).
+	cover atomic.Pointer[coverage]
+
+	// Live every/cron/after schedules, for `karl run --daemon`.
+	scheduleMu    sync.Mutex
+	liveSchedules int
+	schedulesIdle chan struct{}
+
+	// Serializes evaluation across tasks (see runtime_lock.go).
+	lock interpreterLock
+
+	// Deterministic mode: tasks are scheduled cooperatively from a seeded RNG
+	// and time is virtual (see runtime_scheduler.go).
+	sched atomic.Pointer[scheduler]
+	rng   *rand.Rand
 }
 
 func newRuntimeState() *runtimeState {
 	envSnapshot := os.Environ()
 	return &runtimeState{
 		tasks:             make(map[*Task]struct{}),
+		failed:            make(map[*Task]struct{}),
 		taskFailurePolicy: TaskFailurePolicyFailFast,
 		fatalCh:           make(chan struct{}),
 		argv:              []string{},
 		environ:           cloneStrings(envSnapshot),
 		envMap:            makeEnvMap(envSnapshot),
 		input:             os.Stdin,
+		loggerConfig:      defaultLoggerConfig(),
+		metrics:           newMetricsRegistry(),
 	}
 }
 
@@ -43,11 +98,57 @@ func (r *runtimeState) registerTask(t *Task) {
 	if r == nil || t == nil {
 		return
 	}
+	t.id = r.nextTaskID.Add(1)
+	t.runtime = r
 	r.mu.Lock()
 	r.tasks[t] = struct{}{}
 	r.mu.Unlock()
+	r.metrics.tasksSpawned.add(1)
+	if tr := r.tracer(); tr != nil {
+		tr.taskStarted(t)
+	}
 }
 
+// releaseTask forgets a task that just completed. Only an unobserved failure
+// is kept, for CheckUnhandledTaskFailures.
+func (r *runtimeState) releaseTask(t *Task, err error) {
+	r.mu.Lock()
+	delete(r.tasks, t)
+	if unhandledFailure(t, err) && !t.isObserved() {
+		r.failed[t] = struct{}{}
+	}
+	r.mu.Unlock()
+	status := "complete"
+	if re, ok := err.(*RecoverableError); ok && re.Kind == "canceled" {
+		status = "canceled"
+		r.metrics.tasksCanceled.add(1)
+	} else if err != nil {
+		status = "failed"
+		r.metrics.tasksFailed.add(1)
+	}
+	if tr := r.tracer(); tr != nil {
+		tr.taskFinished(t, status)
+	}
+}
+
+// forgetFailure drops a failed task from the report once it was observed.
+func (r *runtimeState) forgetFailure(t *Task) {
+	r.mu.Lock()
+	delete(r.failed, t)
+	r.mu.Unlock()
+}
+
+func unhandledFailure(t *Task, err error) bool {
+	if err == nil || t.internal {
+		return false
+	}
+	if re, ok := err.(*RecoverableError); ok && re.Kind == "canceled" {
+		return false
+	}
+	return true
+}
+
+// snapshotTasks returns the unfinished tasks.
 func (r *runtimeState) snapshotTasks() []*Task {
 	if r == nil {
 		return nil
@@ -61,6 +162,20 @@ func (r *runtimeState) snapshotTasks() []*Task {
 	return out
 }
 
+// snapshotFailedTasks returns finished tasks whose failure nobody observed.
+func (r *runtimeState) snapshotFailedTasks() []*Task {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	out := make([]*Task, 0, len(r.failed))
+	for t := range r.failed {
+		out = append(out, t)
+	}
+	r.mu.Unlock()
+	return out
+}
+
 func (r *runtimeState) setProgramArgs(args []string) {
 	if r == nil {
 		return
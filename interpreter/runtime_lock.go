@@ -0,0 +1,65 @@
+package interpreter
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// interpreterLock makes Karl values safe to share between tasks: arrays,
+// objects, maps and sets are plain Go values, so only the goroutine holding
+// the lock may evaluate Karl code. A task releases it while it blocks (wait,
+// recv, send, sleep, http, stdin) and hands it over at safepoints when other
+// tasks are waiting, which keeps tasks interleaving like on an event loop.
+//
+// Deterministic mode does not use it: the scheduler already lets exactly one
+// task run at a time.
+type interpreterLock struct {
+	mu      sync.Mutex
+	waiting atomic.Int32
+}
+
+func (r *runtimeState) acquire() {
+	if r == nil || r.scheduler() != nil {
+		return
+	}
+	r.lock.waiting.Add(1)
+	r.lock.mu.Lock()
+	r.lock.waiting.Add(-1)
+}
+
+func (r *runtimeState) release() {
+	if r == nil || r.scheduler() != nil {
+		return
+	}
+	r.lock.mu.Unlock()
+}
+
+// blocking runs fn with the interpreter lock released. fn must not touch Karl
+// values; it is meant for channel operations, timers and I/O.
+func (r *runtimeState) blocking(fn func()) {
+	r.release()
+	defer r.acquire()
+	fn()
+}
+
+// yieldLock hands the interpreter lock to a waiting task, if there is one.
+func (r *runtimeState) yieldLock() {
+	if r == nil || r.lock.waiting.Load() == 0 || r.scheduler() != nil {
+		return
+	}
+	r.lock.mu.Unlock()
+	runtime.Gosched()
+	r.acquire()
+}
+
+// blocking is runtimeState.blocking for code that may also run outside Eval
+// (builtins called directly from Go), where there is no lock to release.
+func (e *Evaluator) blocking(fn func()) {
+	defer e.profileWait()()
+	if !e.holdsLock {
+		fn()
+		return
+	}
+	e.runtime.blocking(fn)
+}
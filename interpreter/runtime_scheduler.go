@@ -0,0 +1,294 @@
+package interpreter
+
+import (
+	"math/rand"
+	"time"
+)
+
+// scheduler drives deterministic mode. Tasks are still backed by goroutines, but
+// only the goroutine holding the baton evaluates Karl code; every other one is
+// parked on its wake channel. At each yield point the next goroutine is picked
+// with a seeded RNG, so the same seed always replays the same interleaving.
+// Sleeps wait on a virtual clock that only advances when every task is blocked.
+//
+// All scheduler state is owned by the baton holder, so it needs no locking:
+// handing the baton over a wake channel orders every access.
+type scheduler struct {
+	rng        *rand.Rand
+	now        int64
+	threads    []*schedThread
+	current    *schedThread
+	ticks      int64
+	deadlocked bool
+	onDeadlock func()
+}
+
+type schedThread struct {
+	wake chan struct{}
+
+	// ready is nil while the thread is runnable. A blocked thread becomes
+	// runnable again once ready reports true.
+	ready    func() bool
+	deadline int64
+	timed    bool
+}
+
+func newScheduler(seed int64) *scheduler {
+	main := &schedThread{wake: make(chan struct{})}
+	return &scheduler{
+		rng:     rand.New(rand.NewSource(seed)),
+		threads: []*schedThread{main},
+		current: main,
+	}
+}
+
+// spawn registers fn as a new thread, then yields so the seed decides whether
+// the spawning task or the new one runs first.
+func (s *scheduler) spawn(fn func()) {
+	t := &schedThread{wake: make(chan struct{})}
+	s.threads = append(s.threads, t)
+	go func() {
+		<-t.wake
+		fn()
+		s.exit(t)
+	}()
+	s.yield()
+}
+
+func (s *scheduler) yield() {
+	s.switchFrom(s.current)
+}
+
+// schedulerTimeslice is how many safepoints a task may pass before the
+// scheduler preempts it, so tasks that never block still interleave.
+const schedulerTimeslice = 100
+
+func (s *scheduler) preempt() {
+	s.ticks++
+	if s.ticks%schedulerTimeslice != 0 {
+		return
+	}
+	// A task that never blocks would otherwise freeze the virtual clock: once
+	// it is the only runnable task, let the next timer fire.
+	if len(s.runnable()) == 1 {
+		if deadline, ok := s.nextDeadline(); ok {
+			s.now = deadline
+		}
+	}
+	s.yield()
+}
+
+// block parks the current thread until ready reports true.
+func (s *scheduler) block(ready func() bool) bool {
+	return s.blockUntil(ready, 0, false)
+}
+
+// sleep parks the current thread until the virtual clock reaches now+ms or
+// ready reports true, whichever comes first.
+func (s *scheduler) sleep(ms int64, ready func() bool) bool {
+	deadline := s.now + ms
+	return s.blockUntil(func() bool { return s.now >= deadline || ready() }, deadline, true)
+}
+
+// blockUntil reports false when every thread ended up blocked with no timer
+// left to fire, i.e. the program deadlocked.
+func (s *scheduler) blockUntil(ready func() bool, deadline int64, timed bool) bool {
+	cur := s.current
+	for !s.deadlocked && !ready() {
+		cur.ready = ready
+		cur.deadline = deadline
+		cur.timed = timed
+		s.switchFrom(cur)
+	}
+	cur.ready = nil
+	cur.timed = false
+	return !s.deadlocked
+}
+
+func (s *scheduler) switchFrom(cur *schedThread) {
+	next := s.pick()
+	if next == cur || next == nil {
+		return
+	}
+	s.current = next
+	next.wake <- struct{}{}
+	<-cur.wake
+}
+
+func (s *scheduler) exit(t *schedThread) {
+	for i, other := range s.threads {
+		if other == t {
+			s.threads = append(s.threads[:i], s.threads[i+1:]...)
+			break
+		}
+	}
+	next := s.pick()
+	s.current = next
+	if next != nil {
+		next.wake <- struct{}{}
+	}
+}
+
+func (s *scheduler) runnable() []*schedThread {
+	runnable := make([]*schedThread, 0, len(s.threads))
+	for _, t := range s.threads {
+		if s.deadlocked || t.ready == nil || t.ready() {
+			runnable = append(runnable, t)
+		}
+	}
+	return runnable
+}
+
+func (s *scheduler) pick() *schedThread {
+	for {
+		runnable := s.runnable()
+		if len(runnable) > 0 {
+			return runnable[s.rng.Intn(len(runnable))]
+		}
+		if len(s.threads) == 0 {
+			return nil
+		}
+		if deadline, ok := s.nextDeadline(); ok {
+			s.now = deadline
+			continue
+		}
+		s.deadlocked = true
+		if s.onDeadlock != nil {
+			s.onDeadlock()
+		}
+	}
+}
+
+func (s *scheduler) nextDeadline() (int64, bool) {
+	found := false
+	var next int64
+	for _, t := range s.threads {
+		if !t.timed {
+			continue
+		}
+		if !found || t.deadline < next {
+			next = t.deadline
+			found = true
+		}
+	}
+	return next, found
+}
+
+func (r *runtimeState) setDeterministic(seed int64) {
+	if r == nil {
+		return
+	}
+	sched := newScheduler(seed)
+	sched.onDeadlock = func() {
+		r.setFatalTaskFailure(&RuntimeError{Message: "deadlock: all tasks are blocked"})
+	}
+	r.mu.Lock()
+	r.rng = rand.New(rand.NewSource(seed))
+	r.mu.Unlock()
+	r.sched.Store(sched)
+}
+
+func (r *runtimeState) scheduler() *scheduler {
+	if r == nil {
+		return nil
+	}
+	return r.sched.Load()
+}
+
+// preempt lets the deterministic scheduler switch tasks at a safepoint.
+func (r *runtimeState) preempt() {
+	if sched := r.scheduler(); sched != nil {
+		sched.preempt()
+	}
+}
+
+// goAsync starts fn concurrently: on its own goroutine holding the interpreter
+// lock normally, or as a new cooperatively scheduled thread in deterministic
+// mode.
+func (r *runtimeState) goAsync(fn func()) {
+	if sched := r.scheduler(); sched != nil {
+		sched.spawn(fn)
+		return
+	}
+	go func() {
+		r.acquire()
+		defer r.release()
+		fn()
+	}()
+}
+
+// park blocks the current task until ready reports true. It is a no-op outside
+// deterministic mode, where callers block on Go channels directly.
+func (r *runtimeState) park(ready func() bool) error {
+	sched := r.scheduler()
+	if sched == nil {
+		return nil
+	}
+	if !sched.block(ready) {
+		return r.terminatedError()
+	}
+	return nil
+}
+
+func (r *runtimeState) sleepVirtual(ms int64, ready func() bool) error {
+	sched := r.scheduler()
+	if ms <= 0 {
+		sched.yield()
+		return nil
+	}
+	if !sched.sleep(ms, ready) {
+		return r.terminatedError()
+	}
+	return nil
+}
+
+func (r *runtimeState) terminatedError() error {
+	if err := r.getFatalTaskFailure(); err != nil {
+		return err
+	}
+	return &RuntimeError{Message: "runtime terminated"}
+}
+
+// interruptedError reports why a blocked operation has to give up. A fatal
+// runtime failure takes precedence over cancellation of the current task so
+// deterministic runs always surface the same error.
+func (r *runtimeState) interruptedError(cancelCh <-chan struct{}, fatalCh <-chan struct{}) error {
+	if isClosed(fatalCh) {
+		return r.terminatedError()
+	}
+	if isClosed(cancelCh) {
+		return canceledError()
+	}
+	return nil
+}
+
+func isClosed(ch <-chan struct{}) bool {
+	if ch == nil {
+		return false
+	}
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *runtimeState) nowMillis() int64 {
+	if sched := r.scheduler(); sched != nil {
+		return sched.now
+	}
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+// seededRand returns the seeded RNG in deterministic mode and nil otherwise,
+// in which case callers fall back to the global math/rand source.
+func (r *runtimeState) seededRand() *rand.Rand {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	rng := r.rng
+	r.mu.Unlock()
+	return rng
+}
@@ -0,0 +1,139 @@
+package interpreter
+
+// Signals onSignal accepts. The embedder decides which ones it actually
+// subscribes to and delivers them with DeliverSignal.
+var supportedSignals = map[string]bool{
+	"SIGINT":  true,
+	"SIGTERM": true,
+	"SIGHUP":  true,
+}
+
+func registerShutdownBuiltins() {
+	builtins["atExit"] = &Builtin{Name: "atExit", Fn: builtinAtExit}
+	builtins["onSignal"] = &Builtin{Name: "onSignal", Fn: builtinOnSignal}
+}
+
+func builtinAtExit(e *Evaluator, args []Value) (Value, error) {
+	if len(args) != 1 {
+		return nil, &RuntimeError{Message: "atExit expects function"}
+	}
+	if !isCallable(args[0]) {
+		return nil, &RuntimeError{Message: "atExit expects function"}
+	}
+	r := e.runtime
+	r.mu.Lock()
+	r.exitHooks = append(r.exitHooks, args[0])
+	r.mu.Unlock()
+	return UnitValue, nil
+}
+
+func builtinOnSignal(e *Evaluator, args []Value) (Value, error) {
+	if len(args) != 2 {
+		return nil, &RuntimeError{Message: "onSignal expects signal name and function"}
+	}
+	name, ok := stringArg(args[0])
+	if !ok || !supportedSignals[name] {
+		return nil, &RuntimeError{Message: "onSignal expects \"SIGINT\", \"SIGTERM\" or \"SIGHUP\""}
+	}
+	if !isCallable(args[1]) {
+		return nil, &RuntimeError{Message: "onSignal expects function"}
+	}
+	r := e.runtime
+	r.mu.Lock()
+	if r.signalHandlers == nil {
+		r.signalHandlers = map[string][]Value{}
+	}
+	r.signalHandlers[name] = append(r.signalHandlers[name], args[1])
+	r.mu.Unlock()
+	return UnitValue, nil
+}
+
+func isCallable(val Value) bool {
+	switch val.(type) {
+	case *Function, *Builtin, *Partial:
+		return true
+	default:
+		return false
+	}
+}
+
+// DeliverSignal starts the onSignal handlers registered for name, each as a
+// new root task, and reports whether there were any. Without handlers the
+// embedder should fall back to RequestExit. Deterministic mode never runs
+// handlers, since a signal cannot be replayed from a seed.
+func (e *Evaluator) DeliverSignal(name string) bool {
+	r := e.runtime
+	if r == nil || r.scheduler() != nil {
+		return false
+	}
+	r.mu.Lock()
+	handlers := append([]Value(nil), r.signalHandlers[name]...)
+	r.mu.Unlock()
+	if len(handlers) == 0 {
+		return false
+	}
+	r.acquire()
+	defer r.release()
+	for _, fn := range handlers {
+		task := e.newNamedTask(nil, false, "onSignal "+name)
+		taskEval := e.cloneForTask(task)
+		r.goAsync(func() {
+			taskEval.completeWithCall(task, fn, nil)
+		})
+	}
+	return true
+}
+
+// RequestExit shuts the program down as if a task had called exit(code):
+// running code stops at its next safepoint, blocked tasks wake up, and the
+// root evaluation returns an ExitError with code. It is safe to call from
+// any goroutine, e.g. a signal handler.
+func (e *Evaluator) RequestExit(code int) {
+	if e.runtime == nil {
+		return
+	}
+	e.runtime.setFatalTaskFailure(&ExitError{Code: code})
+}
+
+// RunExitHooks runs the atExit hooks once the program is over, most recently
+// registered first. Tasks still alive are canceled beforehand so hooks never
+// race with them. It returns the first hook error; an ExitError means a hook
+// called exit() and its code should win.
+func (e *Evaluator) RunExitHooks() error {
+	r := e.runtime
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	hooks := r.exitHooks
+	r.exitHooks = nil
+	r.mu.Unlock()
+
+	for _, t := range r.snapshotTasks() {
+		if t.parent == nil && !t.isDone() {
+			t.Cancel()
+		}
+	}
+	if len(hooks) == 0 {
+		return nil
+	}
+	r.clearFatalTaskFailure()
+
+	r.acquire()
+	defer r.release()
+	hookEval := e.cloneForTask(nil)
+	var first error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		_, _, err := hookEval.applyFunction(hooks[i], nil)
+		if err == nil {
+			continue
+		}
+		if _, ok := err.(*ExitError); ok {
+			return err
+		}
+		if first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -41,18 +41,28 @@ func (r *runtimeState) setFatalTaskFailure(err error) {
 	if r == nil || err == nil {
 		return
 	}
-	shouldSignal := false
 	r.mu.Lock()
 	if r.fatalTaskFailure == nil {
 		r.fatalTaskFailure = err
-		shouldSignal = true
+		r.fatalRaisedFlag.Store(true)
+		close(r.fatalCh)
 	}
 	r.mu.Unlock()
-	if shouldSignal {
-		r.fatalOnce.Do(func() {
-			close(r.fatalCh)
-		})
+}
+
+// clearFatalTaskFailure lifts a fatal failure once every task has been
+// canceled, so atExit hooks can still evaluate Karl code during shutdown.
+func (r *runtimeState) clearFatalTaskFailure() {
+	if r == nil {
+		return
 	}
+	r.mu.Lock()
+	if r.fatalTaskFailure != nil {
+		r.fatalTaskFailure = nil
+		r.fatalRaisedFlag.Store(false)
+		r.fatalCh = make(chan struct{})
+	}
+	r.mu.Unlock()
 }
 
 func (r *runtimeState) getFatalTaskFailure() error {
@@ -65,6 +75,11 @@ func (r *runtimeState) getFatalTaskFailure() error {
 	return err
 }
 
+// fatalRaised is a lock-free check for the evaluator's safepoints.
+func (r *runtimeState) fatalRaised() bool {
+	return r != nil && r.fatalRaisedFlag.Load()
+}
+
 func (r *runtimeState) fatalSignal() <-chan struct{} {
 	if r == nil {
 		return nil
@@ -0,0 +1,65 @@
+package interpreter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	cases := []struct {
+		expr string
+		from string
+		want string
+	}{
+		{"*/15 * * * *", "2024-01-01T10:07:30Z", "2024-01-01T10:15:00Z"},
+		{"0 9 * * mon-fri", "2024-01-05T09:00:00Z", "2024-01-08T09:00:00Z"},
+		{"30 23 31 * *", "2024-02-01T00:00:00Z", "2024-03-31T23:30:00Z"},
+		{"0 0 29 2 *", "2024-03-01T00:00:00Z", "2028-02-29T00:00:00Z"},
+		// Day of month and day of week are OR-ed when both are restricted.
+		{"0 0 13 * 5", "2024-09-01T00:00:00Z", "2024-09-06T00:00:00Z"},
+		{"@hourly", "2024-01-01T10:00:00Z", "2024-01-01T11:00:00Z"},
+		{"0 12 * * 7", "2024-01-01T00:00:00Z", "2024-01-07T12:00:00Z"},
+	}
+	for _, tc := range cases {
+		spec, err := parseCron(tc.expr, time.UTC)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.expr, err)
+		}
+		from, _ := time.Parse(time.RFC3339, tc.from)
+		got, ok := spec.next(from)
+		if !ok || got.Format(time.RFC3339) != tc.want {
+			t.Fatalf("%q after %s: got %s (%v), want %s", tc.expr, tc.from, got.Format(time.RFC3339), ok, tc.want)
+		}
+	}
+
+	spec, err := parseCron("0 0 30 2 *", time.UTC)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if _, ok := spec.next(time.Unix(0, 0)); ok {
+		t.Fatalf("expected February 30th to never match")
+	}
+}
+
+func TestScheduleMissedTicks(t *testing.T) {
+	every := func(missed string) *Schedule {
+		s := &Schedule{missed: missed, nextDue: 10, hasNext: true}
+		s.due = func(after int64) (int64, bool) { return after + 10, true }
+		return s
+	}
+
+	s := every(missedSkip)
+	if got := s.dueTicks(45); got != 1 || s.dropped != 3 || s.nextDue != 50 {
+		t.Fatalf("skip: ticks=%d dropped=%d next=%d", got, s.dropped, s.nextDue)
+	}
+
+	s = every(missedCatchUp)
+	if got := s.dueTicks(45); got != 4 || s.dropped != 0 || s.nextDue != 50 {
+		t.Fatalf("catchUp: ticks=%d dropped=%d next=%d", got, s.dropped, s.nextDue)
+	}
+
+	s = every(missedCatchUp)
+	if got := s.dueTicks(10 * (maxCatchUpTicks + 50)); got != maxCatchUpTicks || s.nextDue <= 10*(maxCatchUpTicks+50) {
+		t.Fatalf("far behind: ticks=%d next=%d", got, s.nextDue)
+	}
+}
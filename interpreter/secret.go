@@ -0,0 +1,128 @@
+package interpreter
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// secretMask replaces a secret wherever it would otherwise be shown.
+const secretMask = "***"
+
+// minRedactLength keeps very short secrets out of error-message scanning,
+// where masking every occurrence of a one- or two-letter string would mangle
+// unrelated text.
+const minRedactLength = 3
+
+// Secret wraps a sensitive string. It prints as *** everywhere (Inspect, log,
+// str, encodeJson, errors); only explicit sinks such as http headers and
+// bodies unwrap it.
+type Secret struct {
+	value string
+}
+
+func (s *Secret) Type() ValueType { return SECRET }
+func (s *Secret) Inspect() string { return secretMask }
+
+// Reveal returns the wrapped string for embedders that need to hand it to an
+// explicit sink.
+func (s *Secret) Reveal() string { return s.value }
+
+// secretSet remembers every secret the program created so that error messages
+// which embed one (for example a failing request echoing a token) can be
+// redacted. The zero value is ready to use.
+type secretSet struct {
+	mu     sync.RWMutex
+	values map[string]struct{}
+}
+
+func (s *secretSet) add(value string) {
+	if len(value) < minRedactLength {
+		return
+	}
+	s.mu.Lock()
+	if s.values == nil {
+		s.values = make(map[string]struct{})
+	}
+	s.values[value] = struct{}{}
+	s.mu.Unlock()
+}
+
+// redact masks every known secret in msg. Longer secrets are replaced first so
+// one that contains another is masked whole.
+func (s *secretSet) redact(msg string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.values) == 0 {
+		return msg
+	}
+	found := []string{}
+	for value := range s.values {
+		if strings.Contains(msg, value) {
+			found = append(found, value)
+		}
+	}
+	if len(found) == 0 {
+		return msg
+	}
+	sort.Slice(found, func(i, j int) bool { return len(found[i]) > len(found[j]) })
+	for _, value := range found {
+		msg = strings.ReplaceAll(msg, value, secretMask)
+	}
+	return msg
+}
+
+func (e *Evaluator) newSecret(value string) *Secret {
+	if e.runtime != nil {
+		e.runtime.secrets.add(value)
+	}
+	return &Secret{value: value}
+}
+
+// secretText renders a secret built from text around known secrets (such as
+// "bad token " + token) with only the secret parts masked. Anything else is
+// masked whole.
+func (e *Evaluator) secretText(s *Secret) string {
+	if e.runtime == nil {
+		return secretMask
+	}
+	text := e.runtime.secrets.redact(s.value)
+	if text == s.value {
+		return secretMask
+	}
+	return text
+}
+
+// redactError masks known secrets in a runtime or recoverable error message in
+// place, so every later formatting of the error (recover blocks, task reports,
+// the CLI) sees the redacted text.
+func (r *runtimeState) redactError(err error) {
+	if r == nil {
+		return
+	}
+	switch e := err.(type) {
+	case *RecoverableError:
+		e.Message = r.secrets.redact(e.Message)
+	case *RuntimeError:
+		e.Message = r.secrets.redact(e.Message)
+	}
+}
+
+// concatSecret implements + when either side is a secret: the result stays
+// secret so "Bearer " + token can still be sent as a header.
+func concatSecret(left, right Value) (Value, bool) {
+	_, ls := left.(*Secret)
+	_, rs := right.(*Secret)
+	if !ls && !rs {
+		return nil, false
+	}
+	l, ok := sinkStringArg(left)
+	if !ok {
+		return nil, false
+	}
+	r, ok := sinkStringArg(right)
+	if !ok {
+		return nil, false
+	}
+	return &Secret{value: l + r}, true
+}
@@ -9,13 +9,28 @@ func (t *Task) addChild(child *Task) {
 		return
 	}
 	t.mu.Lock()
-	t.children = append(t.children, child)
+	if t.children == nil {
+		t.children = map[*Task]struct{}{}
+	}
+	t.children[child] = struct{}{}
+	t.mu.Unlock()
+}
+
+func (t *Task) removeChild(child *Task) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	delete(t.children, child)
 	t.mu.Unlock()
 }
 
 func (t *Task) cancelChildren() {
 	t.mu.Lock()
-	children := append([]*Task(nil), t.children...)
+	children := make([]*Task, 0, len(t.children))
+	for child := range t.children {
+		children = append(children, child)
+	}
 	t.mu.Unlock()
 	for _, child := range children {
 		child.Cancel()
@@ -26,7 +41,12 @@ func (t *Task) Cancel() {
 	if t == nil {
 		return
 	}
-	t.cancelOnce.Do(func() { close(t.cancelCh) })
+	t.cancelOnce.Do(func() {
+		if tr := t.runtime.tracer(); tr != nil {
+			tr.instant(t.id, "cancel", "task", nil)
+		}
+		close(t.cancelCh)
+	})
 	t.cancelChildren()
 	t.complete(nil, canceledError())
 }
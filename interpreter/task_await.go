@@ -19,6 +19,10 @@ func (t *Task) complete(value Value, err error) {
 	t.mu.Unlock()
 
 	t.ResultCh <- taskResult{value: value, err: err}
+	if t.runtime != nil {
+		t.runtime.releaseTask(t, err)
+	}
+	t.parent.removeChild(t)
 }
 
 func taskAwaitWithCancel(t *Task, cancelCh <-chan struct{}, runtime *runtimeState) (Value, *Signal, error) {
@@ -28,6 +32,13 @@ func taskAwaitWithCancel(t *Task, cancelCh <-chan struct{}, runtime *runtimeStat
 
 	t.markObserved()
 
+	fatalCh := runtime.fatalSignal()
+	if err := runtime.park(func() bool {
+		return t.isDone() || isClosed(cancelCh) || isClosed(fatalCh)
+	}); err != nil {
+		return nil, nil, err
+	}
+
 	t.mu.Lock()
 	if t.done {
 		res := t.result
@@ -40,22 +51,27 @@ func taskAwaitWithCancel(t *Task, cancelCh <-chan struct{}, runtime *runtimeStat
 	}
 	t.mu.Unlock()
 
-	var out taskResult
-	fatalCh := runtime.fatalSignal()
+	if err := runtime.interruptedError(cancelCh, fatalCh); err != nil {
+		return nil, nil, err
+	}
 
-	if cancelCh == nil && fatalCh == nil {
-		out = <-t.ResultCh
-	} else {
+	var out taskResult
+	var interrupted error
+	runtime.blocking(func() {
+		if cancelCh == nil && fatalCh == nil {
+			out = <-t.ResultCh
+			return
+		}
 		select {
 		case out = <-t.ResultCh:
 		case <-cancelCh:
-			return nil, nil, canceledError()
+			interrupted = canceledError()
 		case <-fatalCh:
-			if err := runtime.getFatalTaskFailure(); err != nil {
-				return nil, nil, err
-			}
-			return nil, nil, &RuntimeError{Message: "runtime terminated"}
+			interrupted = runtime.terminatedError()
 		}
+	})
+	if interrupted != nil {
+		return nil, nil, interrupted
 	}
 
 	t.mu.Lock()
@@ -74,6 +90,9 @@ func (t *Task) markObserved() {
 	t.mu.Lock()
 	t.observed = true
 	t.mu.Unlock()
+	if t.runtime != nil {
+		t.runtime.forgetFailure(t)
+	}
 }
 
 func (t *Task) isObserved() bool {
@@ -0,0 +1,166 @@
+package interpreter
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"karl/ast"
+	"karl/token"
+)
+
+// sourcePosition records the statement a task is evaluating. It is written by
+// the task itself and read by tasks() or a dump from other goroutines, hence
+// the atomics.
+type sourcePosition struct {
+	tok  atomic.Pointer[token.Token]
+	file atomic.Pointer[string]
+}
+
+func (p *sourcePosition) store(tok *token.Token, file *string) {
+	p.tok.Store(tok)
+	p.file.Store(file)
+}
+
+func (p *sourcePosition) String() string {
+	tok := p.tok.Load()
+	if tok == nil {
+		return ""
+	}
+	if file := p.file.Load(); file != nil && *file != "" {
+		return fmt.Sprintf("%s:%d:%d", *file, tok.Line, tok.Column)
+	}
+	return fmt.Sprintf("%d:%d", tok.Line, tok.Column)
+}
+
+func (e *Evaluator) trackPosition(stmt ast.Statement) {
+	tok := ast.NodeToken(stmt)
+	if tok == nil {
+		return
+	}
+	if stack := e.profileStack(); stack != nil {
+		stack.setLine(tok.Line)
+	}
+	if e.currentTask != nil {
+		e.currentTask.position.store(tok, &e.filename)
+		return
+	}
+	if e.runtime != nil {
+		e.runtime.mainPosition.store(tok, &e.filename)
+	}
+}
+
+func (t *Task) status() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch {
+	case !t.done:
+		return "pending"
+	case t.err == nil:
+		return "done"
+	}
+	if re, ok := t.err.(*RecoverableError); ok && re.Kind == "canceled" {
+		return "canceled"
+	}
+	return "failed"
+}
+
+func builtinTasks(e *Evaluator, args []Value) (Value, error) {
+	if len(args) != 0 {
+		return nil, &RuntimeError{Message: "tasks expects no arguments"}
+	}
+	live := make([]*Task, 0)
+	for _, t := range e.runtime.snapshotTasks() {
+		if !t.isDone() {
+			live = append(live, t)
+		}
+	}
+	sortTasks(live)
+	out := make([]Value, 0, len(live))
+	for _, t := range live {
+		out = append(out, taskInfo(t))
+	}
+	return &Array{Elements: out}, nil
+}
+
+func builtinCurrentTask(e *Evaluator, args []Value) (Value, error) {
+	if len(args) != 0 {
+		return nil, &RuntimeError{Message: "currentTask expects no arguments"}
+	}
+	if e.currentTask == nil {
+		return NullValue, nil
+	}
+	return e.currentTask, nil
+}
+
+func taskInfo(t *Task) Value {
+	var name, parent, position Value = NullValue, NullValue, NullValue
+	if t.name != "" {
+		name = &String{Value: t.name}
+	}
+	if t.parent != nil {
+		parent = &Integer{Value: int64(t.parent.id)}
+	}
+	if pos := t.position.String(); pos != "" {
+		position = &String{Value: pos}
+	}
+	return &Object{Pairs: map[string]Value{
+		"id":       &Integer{Value: int64(t.id)},
+		"name":     name,
+		"status":   &String{Value: t.status()},
+		"parent":   parent,
+		"position": position,
+		"task":     t,
+	}}
+}
+
+func sortTasks(tasks []*Task) {
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].id < tasks[j].id })
+}
+
+// DumpTasks writes the tree of unfinished tasks, each with the statement it
+// is currently at. Finished tasks only appear when they still have unfinished
+// descendants.
+func (e *Evaluator) DumpTasks(w io.Writer) {
+	if e.runtime == nil {
+		return
+	}
+	shown := map[*Task]bool{}
+	for _, t := range e.runtime.snapshotTasks() {
+		if t.isDone() {
+			continue
+		}
+		for cur := t; cur != nil && !shown[cur]; cur = cur.parent {
+			shown[cur] = true
+		}
+	}
+	children := map[*Task][]*Task{}
+	for t := range shown {
+		children[t.parent] = append(children[t.parent], t)
+	}
+
+	var b strings.Builder
+	b.WriteString("main")
+	if pos := e.runtime.mainPosition.String(); pos != "" {
+		b.WriteString(" at " + pos)
+	}
+	b.WriteString("\n")
+	var walk func(parent *Task, depth int)
+	walk = func(parent *Task, depth int) {
+		kids := children[parent]
+		sortTasks(kids)
+		for _, t := range kids {
+			b.WriteString(strings.Repeat("  ", depth))
+			b.WriteString(t.Inspect())
+			if pos := t.position.String(); pos != "" && !t.isDone() {
+				b.WriteString(" at " + pos)
+			}
+			b.WriteString("\n")
+			walk(t, depth+1)
+		}
+	}
+	walk(nil, 1)
+	_, _ = io.WriteString(w, b.String())
+}
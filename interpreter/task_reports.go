@@ -0,0 +1,51 @@
+package interpreter
+
+// taskReport is what a child awaiter sends back to the task that fans work
+// out (supervisors, parallelMap): the child's index and its outcome.
+type taskReport struct {
+	idx   int
+	value Value
+	err   error
+}
+
+// awaitChild waits for child on behalf of owner and delivers its outcome on
+// reports. reports must have room for every child that is still outstanding.
+func (r *runtimeState) awaitChild(owner *Task, child *Task, idx int, reports chan<- taskReport) {
+	r.goAsync(func() {
+		val, _, err := taskAwaitWithCancel(child, owner.cancelCh, r)
+		reports <- taskReport{idx: idx, value: val, err: err}
+	})
+}
+
+// nextReport waits for the next child report. Cancellation of owner and fatal
+// runtime failures win over pending reports, so a child that only stopped
+// because its owner is going away is never mistaken for a real outcome.
+func (r *runtimeState) nextReport(owner *Task, reports <-chan taskReport) (taskReport, error) {
+	fatalCh := r.fatalSignal()
+	if err := r.park(func() bool {
+		return owner.canceled() || isClosed(fatalCh) || len(reports) > 0
+	}); err != nil {
+		return taskReport{}, err
+	}
+	if err := r.interruptedError(owner.cancelCh, fatalCh); err != nil {
+		return taskReport{}, err
+	}
+	var report taskReport
+	var err error
+	r.blocking(func() {
+		select {
+		case report = <-reports:
+		case <-owner.cancelCh:
+			err = canceledError()
+		case <-fatalCh:
+			err = r.terminatedError()
+		}
+	})
+	if err != nil {
+		return taskReport{}, err
+	}
+	if err := r.interruptedError(owner.cancelCh, fatalCh); err != nil {
+		return taskReport{}, err
+	}
+	return report, nil
+}
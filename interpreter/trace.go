@@ -0,0 +1,170 @@
+package interpreter
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// tracer streams Chrome trace events (the JSON object format that
+// chrome://tracing and Perfetto load). Each task is a thread whose id is the
+// task id; the main program and hooks run on thread 0. Task lifetimes are
+// async spans, so cancellation from another task cannot break the nesting of
+// the spans recorded on the task's own thread.
+type tracer struct {
+	mu     sync.Mutex
+	w      *bufio.Writer
+	start  time.Time
+	events int
+	err    error
+}
+
+type traceEvent struct {
+	Name  string                 `json:"name"`
+	Cat   string                 `json:"cat,omitempty"`
+	Ph    string                 `json:"ph"`
+	Ts    float64                `json:"ts"`
+	Pid   int                    `json:"pid"`
+	Tid   uint64                 `json:"tid"`
+	ID    uint64                 `json:"id,omitempty"`
+	Scope string                 `json:"s,omitempty"`
+	Args  map[string]interface{} `json:"args,omitempty"`
+}
+
+func newTracer(w io.Writer) *tracer {
+	t := &tracer{w: bufio.NewWriter(w), start: time.Now()}
+	_, t.err = t.w.WriteString(`{"displayTimeUnit":"ms","traceEvents":[`)
+	t.emit(traceEvent{Name: "thread_name", Ph: "M", Args: map[string]interface{}{"name": "main"}})
+	return t
+}
+
+func (t *tracer) emit(ev traceEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err != nil {
+		return
+	}
+	if ev.Ph != "M" {
+		ev.Ts = float64(time.Since(t.start).Nanoseconds()) / 1e3
+	}
+	ev.Pid = 1
+	data, err := json.Marshal(ev)
+	if err != nil {
+		t.err = err
+		return
+	}
+	if t.events > 0 {
+		t.w.WriteByte(',')
+	}
+	t.w.WriteByte('\n')
+	_, t.err = t.w.Write(data)
+	t.events++
+}
+
+var errTraceClosed = errors.New("trace closed")
+
+// close ends the document. Tasks that still hold the tracer can no longer
+// append to it.
+func (t *tracer) close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.err; err != nil {
+		t.err = errTraceClosed
+		return err
+	}
+	t.err = errTraceClosed
+	if _, err := t.w.WriteString("\n]}\n"); err != nil {
+		return err
+	}
+	return t.w.Flush()
+}
+
+// span records a duration event on tid and returns the function that ends it.
+func (t *tracer) span(tid uint64, name, cat string, args map[string]interface{}) func() {
+	t.emit(traceEvent{Name: name, Cat: cat, Ph: "B", Tid: tid, Args: args})
+	return func() {
+		t.emit(traceEvent{Name: name, Cat: cat, Ph: "E", Tid: tid})
+	}
+}
+
+func (t *tracer) instant(tid uint64, name, cat string, args map[string]interface{}) {
+	t.emit(traceEvent{Name: name, Cat: cat, Ph: "i", Scope: "t", Tid: tid, Args: args})
+}
+
+func (t *tracer) taskStarted(task *Task) {
+	label := fmt.Sprintf("task #%d", task.id)
+	if task.name != "" {
+		label += " " + task.name
+	}
+	var parent uint64
+	if task.parent != nil {
+		parent = task.parent.id
+	}
+	t.emit(traceEvent{Name: "thread_name", Ph: "M", Tid: task.id, Args: map[string]interface{}{"name": label}})
+	t.instant(parent, "spawn", "task", map[string]interface{}{"task": task.id, "name": task.name})
+	t.emit(traceEvent{Name: label, Cat: "task", Ph: "b", Tid: task.id, ID: task.id})
+}
+
+func (t *tracer) taskFinished(task *Task, status string) {
+	label := fmt.Sprintf("task #%d", task.id)
+	if task.name != "" {
+		label += " " + task.name
+	}
+	t.instant(task.id, status, "task", nil)
+	t.emit(traceEvent{Name: label, Cat: "task", Ph: "e", Tid: task.id, ID: task.id, Args: map[string]interface{}{"status": status}})
+}
+
+func (r *runtimeState) tracer() *tracer {
+	if r == nil {
+		return nil
+	}
+	return r.trace.Load()
+}
+
+// waitSpan marks the evaluator as blocked in a wait, channel operation, sleep
+// or http call: the span is traced and the CPU profiler skips the task until
+// the returned function is called. It is never nil.
+func (e *Evaluator) waitSpan(name, cat string, args map[string]interface{}) func() {
+	endWait := e.profileWait()
+	t := e.runtime.tracer()
+	if t == nil {
+		return endWait
+	}
+	end := t.span(e.traceThread(), name, cat, args)
+	return func() {
+		end()
+		endWait()
+	}
+}
+
+func (e *Evaluator) traceThread() uint64 {
+	if e.currentTask == nil {
+		return 0
+	}
+	return e.currentTask.id
+}
+
+// StartTrace records trace events to w until StopTrace. Call it before Eval.
+func (e *Evaluator) StartTrace(w io.Writer) {
+	if e.runtime == nil {
+		e.runtime = newRuntimeState()
+	}
+	e.runtime.trace.Store(newTracer(w))
+}
+
+// StopTrace finishes the trace document and flushes it. Events after this
+// point are dropped.
+func (e *Evaluator) StopTrace() error {
+	if e.runtime == nil {
+		return nil
+	}
+	t := e.runtime.trace.Swap(nil)
+	if t == nil {
+		return nil
+	}
+	return t.close()
+}
@@ -24,6 +24,9 @@ func (a *Array) Inspect() string {
 
 type Object struct {
 	Pairs map[string]Value
+	// Proto, set by new(), is where member access looks for properties the
+	// object does not have itself.
+	Proto *Object
 }
 
 func (o *Object) Type() ValueType { return OBJECT }
@@ -31,6 +34,16 @@ func (o *Object) Inspect() string {
 	return inspectObjectPairs(o.Pairs)
 }
 
+// lookup finds name on the object or along its prototype chain.
+func (o *Object) lookup(name string) (Value, bool) {
+	for obj := o; obj != nil; obj = obj.Proto {
+		if val, ok := obj.Pairs[name]; ok {
+			return val, true
+		}
+	}
+	return nil, false
+}
+
 type ModuleObject struct {
 	Env *Environment
 }
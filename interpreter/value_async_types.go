@@ -1,10 +1,23 @@
 package interpreter
 
-import "sync"
+import (
+	"fmt"
+	"sync"
+)
 
 type Task struct {
 	ResultCh chan taskResult
 
+	// id is assigned when the task is registered with the runtime; name is the
+	// optional label given to spawn. position is the statement the task is
+	// currently evaluating, for tasks() and task dumps.
+	id       uint64
+	name     string
+	position sourcePosition
+
+	// frames is the task's Karl call stack while a profiler runs.
+	frames callStack
+
 	mu       sync.Mutex
 	done     bool
 	result   Value
@@ -13,14 +26,23 @@ type Task struct {
 
 	internal bool
 
-	// Cancellation is cooperative. A task only stops when it reaches a yield
-	// point (wait/recv/sleep/http/...) where we check cancelCh.
+	// Cancellation is cooperative. A blocked task wakes up at its yield point
+	// (wait/recv/sleep/http/...); a running one stops at the next evaluator
+	// safepoint (statement, loop iteration, call or query row).
 	cancelOnce sync.Once
 	cancelCh   chan struct{}
 
+	// context is the task-local context inherited from the spawner.
+	context map[string]Value
+
 	// Bookkeeping for structured cancellation (parent cancels children).
+	// Children remove themselves when they complete.
 	parent   *Task
-	children []*Task
+	children map[*Task]struct{}
+
+	// runtime is set when the task is registered; completing the task
+	// releases it there.
+	runtime *runtimeState
 
 	// Used for formatting task errors (each task captures the file it was spawned from).
 	source   string
@@ -28,12 +50,31 @@ type Task struct {
 }
 
 func (t *Task) Type() ValueType { return TASK }
-func (t *Task) Inspect() string { return "<task>" }
+func (t *Task) Inspect() string {
+	if t.name != "" {
+		return fmt.Sprintf("<task #%d %s %s>", t.id, t.name, t.status())
+	}
+	return fmt.Sprintf("<task #%d %s>", t.id, t.status())
+}
 
 type Channel struct {
+	// Ch is never closed: a sender blocked with the interpreter lock released
+	// would panic. closedCh signals closing instead, and receivers drain
+	// whatever is still buffered before they report the channel closed.
 	Ch        chan Value
 	Closed    bool
 	closeOnce sync.Once
+	closedCh  chan struct{}
+
+	// Deterministic mode never blocks inside a Go channel operation, so a
+	// rendezvous is backed by a one-slot buffer and the sender waits until the
+	// receive counter passes its ticket.
+	rendezvous bool
+	sent       uint64
+	received   uint64
+	// receivers counts parked deterministic receivers so trySend knows whether
+	// a rendezvous would be taken right away.
+	receivers int
 }
 
 func (c *Channel) Type() ValueType { return CHANNEL }
@@ -41,10 +82,50 @@ func (c *Channel) Inspect() string { return "<channel>" }
 func (c *Channel) Close() {
 	c.closeOnce.Do(func() {
 		c.Closed = true
-		close(c.Ch)
+		close(c.closedCh)
 	})
 }
 
+// Len reports how many values are buffered. A rendezvous never holds values,
+// even though deterministic mode parks a pending send in a one-slot buffer.
+func (c *Channel) Len() int {
+	if c.rendezvous {
+		return 0
+	}
+	return len(c.Ch)
+}
+
+func (c *Channel) Cap() int {
+	if c.rendezvous {
+		return 0
+	}
+	return cap(c.Ch)
+}
+
+// Broadcast delivers every message sent to it to each of its subscribers.
+type Broadcast struct {
+	mu     sync.Mutex
+	size   int
+	subs   []*Channel
+	closed bool
+}
+
+func (b *Broadcast) Type() ValueType { return BROADCAST }
+func (b *Broadcast) Inspect() string { return "<broadcast>" }
+
+// Pool bounds how many submitted tasks run at once. Tasks submitted beyond
+// the limit are created immediately but only start when a slot frees up.
+type Pool struct {
+	mu      sync.Mutex
+	size    int
+	running int
+	queue   []*poolJob
+	tasks   map[*Task]struct{}
+}
+
+func (p *Pool) Type() ValueType { return POOL }
+func (p *Pool) Inspect() string { return fmt.Sprintf("<pool %d>", p.size) }
+
 type taskResult struct {
 	value Value
 	err   error
@@ -2,6 +2,7 @@ package interpreter
 
 import (
 	"karl/ast"
+	"strconv"
 	"strings"
 )
 
@@ -9,11 +10,69 @@ type Function struct {
 	Params []ast.Pattern
 	Body   ast.Expression
 	Env    *Environment
+	// Name is the binding the lambda was first assigned to, if any; Filename
+	// and Line locate its definition for traces and profiles.
+	Name     string
+	Filename string
+	Line     int
+	// Receiver is the object a method was read from; calls pass it as the
+	// leading self parameter. method is the unbound function it was bound
+	// from, so that reading obj.m twice gives equal values.
+	Receiver Value
+	method   *Function
 }
 
 func (f *Function) Type() ValueType { return FUNC }
 func (f *Function) Inspect() string { return "<function>" }
 
+// isMethod reports whether f declares a leading self parameter that member
+// access on an object should bind. Bound methods keep their first receiver.
+func (f *Function) isMethod() bool {
+	if f.Receiver != nil || len(f.Params) == 0 {
+		return false
+	}
+	param, ok := f.Params[0].(*ast.Identifier)
+	return ok && param.Value == "self"
+}
+
+// bindMethod returns val with receiver bound when it is a method, and val
+// itself otherwise, so lambdas stored as plain data are called as written.
+func bindMethod(receiver Value, val Value) Value {
+	f, ok := val.(*Function)
+	if !ok || !f.isMethod() {
+		return val
+	}
+	bound := *f
+	bound.Receiver = receiver
+	bound.method = f
+	return &bound
+}
+
+// sameFunction is identity equality for functions, where a bound method is
+// the same as another binding of the same function to the same receiver.
+func sameFunction(l, r *Function) bool {
+	if l.method != nil && r.method != nil {
+		return l.method == r.method && l.Receiver == r.Receiver
+	}
+	return l == r
+}
+
+// label names the function for traces and profiles: "name (file:line)".
+func (f *Function) label() string {
+	name := f.Name
+	if name == "" {
+		name = "<lambda>"
+	}
+	if f.Line == 0 {
+		return name
+	}
+	file := f.Filename
+	if file == "" {
+		file = "<input>"
+	}
+	return name + " (" + file + ":" + strconv.Itoa(f.Line) + ")"
+}
+
 type BuiltinFunction func(e *Evaluator, args []Value) (Value, error)
 
 type Builtin struct {
@@ -5,22 +5,32 @@ import "fmt"
 type ValueType string
 
 const (
-	INTEGER ValueType = "INTEGER"
-	FLOAT   ValueType = "FLOAT"
-	BOOLEAN ValueType = "BOOLEAN"
-	STRING  ValueType = "STRING"
-	CHAR    ValueType = "CHAR"
-	NULL    ValueType = "NULL"
-	UNIT    ValueType = "UNIT"
-	ARRAY   ValueType = "ARRAY"
-	OBJECT  ValueType = "OBJECT"
-	MAP     ValueType = "MAP"
-	SET     ValueType = "SET"
-	FUNC    ValueType = "FUNCTION"
-	BUILTIN ValueType = "BUILTIN"
-	TASK    ValueType = "TASK"
-	CHANNEL ValueType = "CHANNEL"
-	PARTIAL ValueType = "PARTIAL"
+	INTEGER    ValueType = "INTEGER"
+	FLOAT      ValueType = "FLOAT"
+	BOOLEAN    ValueType = "BOOLEAN"
+	STRING     ValueType = "STRING"
+	CHAR       ValueType = "CHAR"
+	NULL       ValueType = "NULL"
+	UNIT       ValueType = "UNIT"
+	ARRAY      ValueType = "ARRAY"
+	OBJECT     ValueType = "OBJECT"
+	MAP        ValueType = "MAP"
+	SET        ValueType = "SET"
+	FUNC       ValueType = "FUNCTION"
+	BUILTIN    ValueType = "BUILTIN"
+	TASK       ValueType = "TASK"
+	CHANNEL    ValueType = "CHANNEL"
+	BROADCAST  ValueType = "BROADCAST"
+	POOL       ValueType = "POOL"
+	MUTEX      ValueType = "MUTEX"
+	SEMAPHORE  ValueType = "SEMAPHORE"
+	ATOMIC     ValueType = "ATOMIC"
+	CHECKPOINT ValueType = "CHECKPOINT"
+	SCHEDULE   ValueType = "SCHEDULE"
+	LOGGER     ValueType = "LOGGER"
+	METRIC     ValueType = "METRIC"
+	SECRET     ValueType = "SECRET"
+	PARTIAL    ValueType = "PARTIAL"
 )
 
 type Value interface {
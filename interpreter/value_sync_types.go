@@ -0,0 +1,80 @@
+package interpreter
+
+import (
+	"fmt"
+	"sync"
+)
+
+// permits is the counting semaphore behind mutex(), semaphore(n) and the
+// update guard of atomic(x). Waiters block on changed, which is closed and
+// replaced every time a permit is returned.
+type permits struct {
+	mu        sync.Mutex
+	available int
+	capacity  int
+	changed   chan struct{}
+}
+
+func newPermits(n int) *permits {
+	return &permits{available: n, capacity: n, changed: make(chan struct{})}
+}
+
+func (p *permits) tryTake() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.available == 0 {
+		return false
+	}
+	p.available--
+	return true
+}
+
+func (p *permits) put() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.available == p.capacity {
+		return false
+	}
+	p.available++
+	close(p.changed)
+	p.changed = make(chan struct{})
+	return true
+}
+
+func (p *permits) free() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.available
+}
+
+type Mutex struct {
+	permits *permits
+}
+
+func (m *Mutex) Type() ValueType { return MUTEX }
+func (m *Mutex) Inspect() string {
+	if m.permits.free() == 0 {
+		return "<mutex locked>"
+	}
+	return "<mutex>"
+}
+
+type Semaphore struct {
+	permits *permits
+}
+
+func (s *Semaphore) Type() ValueType { return SEMAPHORE }
+func (s *Semaphore) Inspect() string {
+	return fmt.Sprintf("<semaphore %d/%d>", s.permits.free(), s.permits.capacity)
+}
+
+// Atomic holds a single value. Reads and writes are already serialized by the
+// interpreter lock; guard additionally keeps update(fn) atomic while fn runs
+// and may reach a safepoint or yield point.
+type Atomic struct {
+	value Value
+	guard *permits
+}
+
+func (a *Atomic) Type() ValueType { return ATOMIC }
+func (a *Atomic) Inspect() string { return "<atomic " + a.value.Inspect() + ">" }
@@ -411,8 +411,8 @@ func (k *Kernel) handleExecuteRequest(msg *Message, identities [][]byte) {
 	p := parser.New(l)
 	program := p.ParseProgram()
 	
-	if len(p.Errors()) > 0 {
-		errResult = fmt.Errorf("Parse Error: %v", p.Errors())
+	if errs := p.ErrorsDetailed(); len(errs) > 0 {
+		errResult = fmt.Errorf("%s", parser.FormatParseErrors(errs, code, "<jupyter>"))
 	} else {
 		val, _, err := k.eval.Eval(program, k.env)
 		if err != nil {
@@ -425,11 +425,12 @@ func (k *Kernel) handleExecuteRequest(msg *Message, identities [][]byte) {
 	}
 
 	if errResult != nil {
-		// Publish Error
+		// Publish Error; Jupyter shows one traceback entry per line
+		traceback := strings.Split(errResult.Error(), "\n")
 		errorContent := map[string]interface{}{
 			"ename":    "Error",
 			"evalue":   errResult.Error(),
-			"traceback": []string{errResult.Error()},
+			"traceback": traceback,
 		}
 		
 		errorMsg := &Message{
@@ -464,7 +465,7 @@ func (k *Kernel) handleExecuteRequest(msg *Message, identities [][]byte) {
 				"execution_count":  execCount,
 				"ename":            "Error",
 				"evalue":           errResult.Error(),
-				"traceback":        []string{errResult.Error()},
+				"traceback":        traceback,
 			},
 		}
 		if err := k.sendMessage(k.shell, reply, identities...); err != nil {
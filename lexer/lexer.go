@@ -169,6 +169,9 @@ func (l *Lexer) NextToken() token.Token {
 			l.readChar()
 			literal := string(ch) + string(l.ch)
 			tok = token.Token{Type: token.OR, Literal: literal}
+		} else if l.peekChar() == '>' {
+			l.readChar()
+			tok = token.Token{Type: token.PIPELINE, Literal: "|>"}
 		} else {
 			tok = newToken(token.PIPE, l.ch)
 		}
@@ -209,7 +212,7 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.RBRACKET, l.ch)
 	case '"':
 		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		tok.Literal = l.readStringLiteral(false)
 	case '\'':
 		tok.Type = token.CHAR
 		tok.Literal = l.readCharLiteral()
@@ -217,7 +220,11 @@ func (l *Lexer) NextToken() token.Token {
 		tok.Literal = ""
 		tok.Type = token.EOF
 	default:
-		if isLetter(l.ch) {
+		if l.ch == 'r' && l.peekChar() == '"' {
+			l.readChar()
+			tok.Type = token.STRING
+			tok.Literal = l.readStringLiteral(true)
+		} else if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
 			tok.Line = startLine
@@ -237,9 +244,15 @@ func (l *Lexer) NextToken() token.Token {
 		}
 	}
 
+	if tok.Type == token.STRING && l.ch == 0 {
+		tok = l.unterminatedString(startOffset)
+	}
 	tok.Line = startLine
 	tok.Column = startColumn
 	tok.Offset = startOffset
+	if tok.Type == token.STRING || tok.Type == token.CHAR {
+		tok.Source = l.input[startOffset:min(l.position+1, len(l.input))]
+	}
 	l.readChar()
 	return tok
 }
@@ -256,25 +269,157 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[position:l.position]
 }
 
+// readNumber reads a decimal, 0x hexadecimal or 0b binary integer, or a
+// decimal float with a fraction, an exponent or both. Digits may be grouped
+// with underscores; the parser rejects misplaced ones.
 func (l *Lexer) readNumber() (string, token.TokenType) {
 	position := l.position
-	for isDigit(l.ch) {
+	if l.ch == '0' && strings.IndexByte("xXbB", l.peekChar()) >= 0 {
 		l.readChar()
+		l.readChar()
+		// Binary literals read hex digits too, so 0b12 is one bad
+		// literal rather than 0b1 followed by 2.
+		for isHexDigit(l.ch) || l.ch == '_' {
+			l.readChar()
+		}
+		return l.input[position:l.position], token.INT
 	}
-	if l.ch == '.' && l.peekChar() != '.' && isDigit(l.peekChar()) {
+	tokType := token.TokenType(token.INT)
+	l.readDigits()
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokType = token.FLOAT
 		l.readChar()
-		for isDigit(l.ch) {
+		l.readDigits()
+	}
+	if l.ch == 'e' || l.ch == 'E' {
+		next := l.peekChar()
+		if (next == '+' || next == '-') && l.readPosition+1 < len(l.input) {
+			next = l.input[l.readPosition+1]
+		}
+		if isDigit(next) {
+			tokType = token.FLOAT
+			l.readChar()
+			if l.ch == '+' || l.ch == '-' {
+				l.readChar()
+			}
+			l.readDigits()
+		}
+	}
+	return l.input[position:l.position], tokType
+}
+
+func (l *Lexer) readDigits() {
+	for isDigit(l.ch) || l.ch == '_' {
+		l.readChar()
+	}
+}
+
+// readStringLiteral reads a string starting at its opening quote and leaves
+// l.ch on the closing one. Raw strings (r"...") keep backslashes as written.
+// Triple-quoted strings may span lines and have their indentation stripped;
+// see dedent.
+func (l *Lexer) readStringLiteral(raw bool) string {
+	if l.peekChar() != '"' || l.readPosition+1 >= len(l.input) || l.input[l.readPosition+1] != '"' {
+		if raw {
+			l.readChar()
+			start := l.position
+			for l.ch != '"' && l.ch != 0 {
+				l.readChar()
+			}
+			return l.input[start:l.position]
+		}
+		return l.readString()
+	}
+
+	l.readChar()
+	l.readChar()
+	l.readChar()
+	start := l.position
+	for l.ch != 0 && !strings.HasPrefix(l.input[l.position:], `"""`) {
+		if l.ch == '\\' && !raw {
+			l.readChar()
+		}
+		if l.ch != 0 {
 			l.readChar()
 		}
-		return l.input[position:l.position], token.FLOAT
 	}
-	return l.input[position:l.position], token.INT
+	text := dedent(l.input[start:l.position])
+	if l.ch != 0 {
+		l.readChar()
+		l.readChar()
+	}
+	if raw {
+		return text
+	}
+	escapes := &Lexer{input: text}
+	escapes.readChar()
+	return escapes.readEscaped(0)
+}
+
+// unterminatedString is the ILLEGAL token for a string starting at start that
+// runs to the end of the input without its closing quotes. Its literal is the
+// opening delimiter, so the error points at where the string began.
+func (l *Lexer) unterminatedString(start int) token.Token {
+	open := l.input[start:]
+	n := strings.IndexByte(open, '"') + 1
+	if strings.HasPrefix(open[n-1:], `"""`) {
+		n += 2
+	}
+	return token.Token{Type: token.ILLEGAL, Literal: open[:n]}
+}
+
+// dedent applies the layout rules of triple-quoted strings to the text
+// between the quotes:
+//   - a line break right after the opening quotes is dropped, and so is the
+//     last line when the closing quotes sit on a line of their own;
+//   - the smallest indentation of the non-blank lines, counting the closing
+//     quotes' line, is removed from every line;
+//   - blank lines become empty and CRLF line endings become LF.
+func dedent(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	if len(lines) == 1 {
+		return text
+	}
+	if isBlank(lines[0]) {
+		lines = lines[1:]
+	}
+	indent := -1
+	if last := lines[len(lines)-1]; isBlank(last) {
+		indent = len(last)
+		lines = lines[:len(lines)-1]
+	}
+	for _, line := range lines {
+		if isBlank(line) {
+			continue
+		}
+		if n := len(line) - len(strings.TrimLeft(line, " \t")); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	for i, line := range lines {
+		if isBlank(line) {
+			lines[i] = ""
+		} else {
+			lines[i] = line[indent:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func isBlank(line string) bool {
+	return strings.Trim(line, " \t") == ""
 }
 
 func (l *Lexer) readString() string {
 	l.readChar()
+	return l.readEscaped('"')
+}
+
+// readEscaped reads up to the next unescaped end byte, or to the end of the
+// input, interpreting escape sequences.
+func (l *Lexer) readEscaped(end byte) string {
 	var out strings.Builder
-	for l.ch != '"' && l.ch != 0 {
+	for l.ch != end && l.ch != 0 {
 		if l.ch != '\\' {
 			out.WriteByte(l.ch)
 			l.readChar()
@@ -4,9 +4,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
 	"runtime/debug"
+	"slices"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"karl/ast"
 	"karl/interpreter"
@@ -17,6 +24,7 @@ import (
 	"karl/playground"
 	"karl/repl"
 	"karl/spreadsheet"
+	"karl/token"
 )
 
 func main() {
@@ -37,6 +45,8 @@ func main() {
 		os.Exit(parseCommand(os.Args[2:]))
 	case "run":
 		os.Exit(runCommand(os.Args[2:]))
+	case "migrate":
+		os.Exit(migrateCommand(os.Args[2:], os.Stdout, os.Stderr))
 	case "loom":
 		os.Exit(loomCommand(os.Args[2:]))
 	case "repl":
@@ -68,6 +78,7 @@ func usage(w io.Writer) {
 	fmt.Fprintf(w, "  version                  print Karl CLI version\n")
 	fmt.Fprintf(w, "  parse <file.k>           parse a file and print the AST\n")
 	fmt.Fprintf(w, "  run <file.k>             run a file using the interpreter (program args after --)\n")
+	fmt.Fprintf(w, "  migrate <file.k>...      find code whose meaning changed with newline-aware parsing\n")
 	fmt.Fprintf(w, "  loom <file.k>            run a file using the Loom runtime\n")
 	fmt.Fprintf(w, "  repl                     start the REPL\n")
 	fmt.Fprintf(w, "  repl-server              start the REPL server\n")
@@ -159,7 +170,7 @@ func parseCommand(args []string) int {
 }
 
 func runCommand(args []string) int {
-	taskFailurePolicy, positional, programArgs, help, err := parseRunArgs(args)
+	opts, positional, help, err := parseRunArgs(args)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s\n", err)
 		runUsage()
@@ -178,9 +189,11 @@ func runCommand(args []string) int {
 		runUsage()
 		return 2
 	}
-	if err := validateExtension(positional[0]); err != nil {
-		fmt.Fprintf(os.Stderr, "%s\n", err)
-		return 2
+	if !opts.ast {
+		if err := validateExtension(positional[0]); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			return 2
+		}
 	}
 	data, err := readInput(positional[0])
 	if err != nil {
@@ -188,18 +201,32 @@ func runCommand(args []string) int {
 		return 1
 	}
 	filename := displayName(positional[0])
-	program, err := parseProgram(data, filename)
+	source := string(data)
+	var program *ast.Program
+	if opts.ast {
+		// There is no source text to quote in error messages.
+		program, err = decodeProgram(data)
+		source = ""
+	} else {
+		program, err = parseProgram(data, filename)
+	}
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
 		return 1
 	}
-	val, err := runProgram(program, string(data), filename, taskFailurePolicy, programArgs)
+	val, err := runProgram(program, source, filename, opts)
 	if err != nil {
+		if exitErr, ok := err.(*interpreter.ExitError); ok {
+			if exitErr.Message != "" {
+				fmt.Fprintln(os.Stderr, exitErr.Message)
+			}
+			return exitErr.Code
+		}
 		if ute, ok := err.(*interpreter.UnhandledTaskError); ok {
 			fmt.Fprintln(os.Stderr, ute.Error())
 			return 1
 		}
-		fmt.Fprintln(os.Stderr, interpreter.FormatRuntimeError(err, string(data), filename))
+		fmt.Fprintln(os.Stderr, interpreter.FormatRuntimeError(err, source, filename))
 		return 1
 	}
 	if _, ok := val.(*interpreter.Unit); !ok {
@@ -216,13 +243,132 @@ func parseUsage() {
 	fmt.Fprintf(os.Stderr, "  --format string   output format: pretty|json (default \"pretty\")\n")
 }
 
+// migrateCommand reports lines that start with a bracket the parser used to
+// read as continuing the line before (see parser.FindContinuations) and,
+// with --write, joins them back so the code keeps its old meaning.
+func migrateCommand(args []string, out io.Writer, errOut io.Writer) int {
+	write := false
+	files := []string{}
+	for _, arg := range args {
+		switch {
+		case arg == "-h" || arg == "--help":
+			migrateUsage(errOut)
+			return 0
+		case arg == "--write" || arg == "-w":
+			write = true
+		case arg == "-":
+			fmt.Fprintf(errOut, "migrate reads files, not stdin\n")
+			return 2
+		case strings.HasPrefix(arg, "-"):
+			fmt.Fprintf(errOut, "unknown flag: %s\n", arg)
+			migrateUsage(errOut)
+			return 2
+		default:
+			files = append(files, arg)
+		}
+	}
+	if len(files) == 0 {
+		migrateUsage(errOut)
+		return 2
+	}
+
+	code := 0
+	for _, path := range files {
+		if err := validateExtension(path); err != nil {
+			fmt.Fprintf(errOut, "%s\n", err)
+			return 2
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(errOut, "read error: %v\n", err)
+			return 1
+		}
+		fixed, notes, remaining := migrateSource(string(data), write)
+		for _, note := range notes {
+			fmt.Fprintf(out, "%s:%s\n", path, note)
+		}
+		if write && fixed != string(data) {
+			if err := os.WriteFile(path, []byte(fixed), 0o644); err != nil {
+				fmt.Fprintf(errOut, "write error: %v\n", err)
+				return 1
+			}
+		}
+		if remaining > 0 {
+			code = 1
+		}
+	}
+	return code
+}
+
+// migrateSource describes each continuation in source as "line:col: ..." and,
+// when fix is set, joins the bracket onto the end of the line before it. A
+// continuation with a comment in between is left for the user; remaining
+// counts the ones still in the returned source.
+func migrateSource(source string, fix bool) (string, []string, int) {
+	conts := parser.FindContinuations(source)
+	notes := make([]string, 0, len(conts))
+	remaining := 0
+	fixed := source
+	for i := len(conts) - 1; i >= 0; i-- {
+		c := conts[i]
+		role := map[token.TokenType]string{
+			token.LPAREN:   "a call",
+			token.LBRACKET: "an index",
+			token.LBRACE:   "a struct initializer",
+		}[c.Token.Type]
+		note := fmt.Sprintf("%d:%d: %s at the start of a line now begins a new statement; it used to continue line %d as %s",
+			c.Token.Line, c.Token.Column, c.Token.Literal, c.Prev.Line, role)
+		end := ast.TokenSpan(c.Prev).End.Offset
+		gap := fixed[end:c.Token.Offset]
+		switch {
+		case !fix:
+			remaining++
+		case strings.TrimSpace(gap) != "":
+			note += " (not joined: there is a comment in between)"
+			remaining++
+		default:
+			fixed = fixed[:end] + fixed[c.Token.Offset:]
+			note += " (joined)"
+		}
+		notes = append(notes, note)
+	}
+	slices.Reverse(notes)
+	return fixed, notes, remaining
+}
+
+func migrateUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage:\n")
+	fmt.Fprintf(w, "  karl migrate [--write] <file.k>...\n")
+	fmt.Fprintf(w, "\nA (, [ or { at the start of a line now begins a new statement instead of\n")
+	fmt.Fprintf(w, "calling, indexing or initializing the expression on the line before.\n")
+	fmt.Fprintf(w, "migrate lists the places where that changes what a program means and\n")
+	fmt.Fprintf(w, "exits with status 1 if there are any.\n")
+	fmt.Fprintf(w, "\nOptions:\n")
+	fmt.Fprintf(w, "  -w, --write   join each such bracket onto the previous line, keeping the old meaning\n")
+}
+
 func runUsage() {
 	fmt.Fprintf(os.Stderr, "Usage:\n")
-	fmt.Fprintf(os.Stderr, "  karl run <file.k> [--task-failure-policy=fail-fast|defer] [-- <program args...>]\n")
+	fmt.Fprintf(os.Stderr, "  karl run <file.k> [--ast] [--task-failure-policy=fail-fast|defer] [--seed=N] [--dump-tasks] [--daemon] [--grace-period=5s] [--log-level=info] [--log-format=text|json] [--metrics-addr=127.0.0.1:9090] [--trace=out.json] [--cpuprofile=cpu.pb.gz] [--allocprofile=alloc.pb.gz] [--cover] [--coverprofile=lcov.info] [--coverhtml=cover.html] [-- <program args...>]\n")
 	fmt.Fprintf(os.Stderr, "  <file> can be '-' to read from stdin\n")
 	fmt.Fprintf(os.Stderr, "  program args are only accepted after `--`\n")
 	fmt.Fprintf(os.Stderr, "\nOptions:\n")
+	fmt.Fprintf(os.Stderr, "  --ast                          <file> is a JSON AST from `karl parse --format=json` instead of source\n")
 	fmt.Fprintf(os.Stderr, "  --task-failure-policy string   task failure behavior: fail-fast|defer (default \"fail-fast\")\n")
+	fmt.Fprintf(os.Stderr, "  --seed int                     run tasks deterministically on a virtual clock, scheduled from this seed\n")
+	fmt.Fprintf(os.Stderr, "  --dump-tasks                   print the task tree to stderr when the program finishes\n")
+	fmt.Fprintf(os.Stderr, "  --daemon                       keep running until every every/cron/after schedule has stopped\n")
+	fmt.Fprintf(os.Stderr, "  --grace-period duration        time to shut down after SIGINT/SIGTERM/SIGHUP before a forced exit (default 5s)\n")
+	fmt.Fprintf(os.Stderr, "  --log-level string             threshold for logger(): debug|info|warn|error (default \"info\")\n")
+	fmt.Fprintf(os.Stderr, "  --log-format string            encoder for logger(): text|json (default \"text\")\n")
+	fmt.Fprintf(os.Stderr, "  --metrics-addr host:port       serve metricsText() at http://host:port/metrics while the program runs\n")
+	fmt.Fprintf(os.Stderr, "  --trace out.json               write a Chrome/Perfetto trace of tasks, waits, channels, sleeps, http and calls\n")
+	fmt.Fprintf(os.Stderr, "  --cpuprofile cpu.pb.gz         write a pprof CPU profile of Karl functions (go tool pprof)\n")
+	fmt.Fprintf(os.Stderr, "  --allocprofile alloc.pb.gz     write a pprof profile of the Arrays, Objects and Strings built per source line\n")
+	fmt.Fprintf(os.Stderr, "  --cover                        print statement and match arm coverage (including imports and tasks) to stderr\n")
+	fmt.Fprintf(os.Stderr, "  --coverprofile lcov.info       write coverage as an LCOV tracefile (implies coverage)\n")
+	fmt.Fprintf(os.Stderr, "  --coverhtml cover.html         write an HTML coverage report with annotated source (implies coverage)\n")
+	fmt.Fprintf(os.Stderr, "  sending SIGQUIT (Ctrl-\\) prints the task tree while the program keeps running\n")
 }
 
 func parseParseArgs(args []string) (string, []string, bool, error) {
@@ -254,43 +400,209 @@ func parseParseArgs(args []string) (string, []string, bool, error) {
 	return format, positional, false, nil
 }
 
-func parseRunArgs(args []string) (string, []string, []string, bool, error) {
-	taskFailurePolicy := interpreter.TaskFailurePolicyFailFast
+// runOptions holds the `karl run` flags that configure the evaluator.
+type runOptions struct {
+	taskFailurePolicy string
+	programArgs       []string
+
+	// deterministic is set by --seed.
+	deterministic bool
+	seed          int64
+
+	// dumpTasks prints the task tree to stderr when the program finishes.
+	dumpTasks bool
+
+	// daemon keeps the process running while every/cron/after schedules exist.
+	daemon bool
+
+	// gracePeriod bounds how long a signal-initiated shutdown may take.
+	gracePeriod time.Duration
+
+	// logLevel and logFormat configure logger(); empty keeps the defaults.
+	logLevel  string
+	logFormat string
+
+	// metricsAddr serves metricsText() over HTTP while the program runs.
+	metricsAddr string
+
+	// tracePath receives a Chrome trace-event file for the run.
+	tracePath string
+
+	// cpuProfilePath and allocProfilePath receive pprof profiles of the run.
+	cpuProfilePath   string
+	allocProfilePath string
+
+	// cover prints a coverage summary; coverProfilePath and coverHTMLPath
+	// receive LCOV and HTML reports.
+	cover            bool
+	coverProfilePath string
+	coverHTMLPath    string
+
+	// ast reads the program as the JSON AST printed by `karl parse --format=json`.
+	ast bool
+}
+
+func parseRunArgs(args []string) (runOptions, []string, bool, error) {
+	opts := runOptions{
+		taskFailurePolicy: interpreter.TaskFailurePolicyFailFast,
+		programArgs:       []string{},
+		gracePeriod:       defaultGracePeriod,
+	}
 	positional := []string{}
-	programArgs := []string{}
 	separatorSeen := false
 	for i := 0; i < len(args); i++ {
 		arg := args[i]
 		switch {
 		case arg == "-h" || arg == "--help":
-			return taskFailurePolicy, positional, programArgs, true, nil
+			return opts, positional, true, nil
 		case arg == "--":
 			separatorSeen = true
-			programArgs = append(programArgs, args[i+1:]...)
+			opts.programArgs = append(opts.programArgs, args[i+1:]...)
 			i = len(args)
 		case strings.HasPrefix(arg, "--task-failure-policy="):
-			taskFailurePolicy = strings.TrimPrefix(arg, "--task-failure-policy=")
+			opts.taskFailurePolicy = strings.TrimPrefix(arg, "--task-failure-policy=")
 		case arg == "--task-failure-policy":
 			if i+1 >= len(args) {
-				return taskFailurePolicy, positional, programArgs, false, fmt.Errorf("--task-failure-policy requires a value")
+				return opts, positional, false, fmt.Errorf("--task-failure-policy requires a value")
+			}
+			opts.taskFailurePolicy = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--seed="):
+			if err := opts.setSeed(strings.TrimPrefix(arg, "--seed=")); err != nil {
+				return opts, positional, false, err
+			}
+		case arg == "--seed":
+			if i+1 >= len(args) {
+				return opts, positional, false, fmt.Errorf("--seed requires a value")
+			}
+			if err := opts.setSeed(args[i+1]); err != nil {
+				return opts, positional, false, err
+			}
+			i++
+		case arg == "--dump-tasks":
+			opts.dumpTasks = true
+		case arg == "--daemon":
+			opts.daemon = true
+		case strings.HasPrefix(arg, "--grace-period="):
+			if err := opts.setGracePeriod(strings.TrimPrefix(arg, "--grace-period=")); err != nil {
+				return opts, positional, false, err
+			}
+		case arg == "--grace-period":
+			if i+1 >= len(args) {
+				return opts, positional, false, fmt.Errorf("--grace-period requires a value")
+			}
+			if err := opts.setGracePeriod(args[i+1]); err != nil {
+				return opts, positional, false, err
+			}
+			i++
+		case strings.HasPrefix(arg, "--log-level="):
+			opts.logLevel = strings.TrimPrefix(arg, "--log-level=")
+		case arg == "--log-level":
+			if i+1 >= len(args) {
+				return opts, positional, false, fmt.Errorf("--log-level requires a value")
+			}
+			opts.logLevel = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--log-format="):
+			opts.logFormat = strings.TrimPrefix(arg, "--log-format=")
+		case arg == "--log-format":
+			if i+1 >= len(args) {
+				return opts, positional, false, fmt.Errorf("--log-format requires a value")
+			}
+			opts.logFormat = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--metrics-addr="):
+			opts.metricsAddr = strings.TrimPrefix(arg, "--metrics-addr=")
+		case arg == "--metrics-addr":
+			if i+1 >= len(args) {
+				return opts, positional, false, fmt.Errorf("--metrics-addr requires a value")
+			}
+			opts.metricsAddr = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--trace="):
+			opts.tracePath = strings.TrimPrefix(arg, "--trace=")
+		case arg == "--trace":
+			if i+1 >= len(args) {
+				return opts, positional, false, fmt.Errorf("--trace requires a value")
+			}
+			opts.tracePath = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--cpuprofile="):
+			opts.cpuProfilePath = strings.TrimPrefix(arg, "--cpuprofile=")
+		case arg == "--cpuprofile":
+			if i+1 >= len(args) {
+				return opts, positional, false, fmt.Errorf("--cpuprofile requires a value")
 			}
-			taskFailurePolicy = args[i+1]
+			opts.cpuProfilePath = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--allocprofile="):
+			opts.allocProfilePath = strings.TrimPrefix(arg, "--allocprofile=")
+		case arg == "--allocprofile":
+			if i+1 >= len(args) {
+				return opts, positional, false, fmt.Errorf("--allocprofile requires a value")
+			}
+			opts.allocProfilePath = args[i+1]
+			i++
+		case arg == "--ast":
+			opts.ast = true
+		case arg == "--cover":
+			opts.cover = true
+		case strings.HasPrefix(arg, "--coverprofile="):
+			opts.coverProfilePath = strings.TrimPrefix(arg, "--coverprofile=")
+		case arg == "--coverprofile":
+			if i+1 >= len(args) {
+				return opts, positional, false, fmt.Errorf("--coverprofile requires a value")
+			}
+			opts.coverProfilePath = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--coverhtml="):
+			opts.coverHTMLPath = strings.TrimPrefix(arg, "--coverhtml=")
+		case arg == "--coverhtml":
+			if i+1 >= len(args) {
+				return opts, positional, false, fmt.Errorf("--coverhtml requires a value")
+			}
+			opts.coverHTMLPath = args[i+1]
 			i++
 		case arg == "-":
 			positional = append(positional, arg)
 		case strings.HasPrefix(arg, "-"):
-			return taskFailurePolicy, positional, programArgs, false, fmt.Errorf("unknown flag: %s", arg)
+			return opts, positional, false, fmt.Errorf("unknown flag: %s", arg)
 		default:
 			positional = append(positional, arg)
 		}
 	}
 	if !separatorSeen && len(positional) > 1 {
-		return taskFailurePolicy, positional, programArgs, false, fmt.Errorf("program args must follow `--`")
+		return opts, positional, false, fmt.Errorf("program args must follow `--`")
+	}
+	if opts.taskFailurePolicy != interpreter.TaskFailurePolicyFailFast && opts.taskFailurePolicy != interpreter.TaskFailurePolicyDefer {
+		return opts, positional, false, fmt.Errorf("invalid --task-failure-policy: %s", opts.taskFailurePolicy)
 	}
-	if taskFailurePolicy != interpreter.TaskFailurePolicyFailFast && taskFailurePolicy != interpreter.TaskFailurePolicyDefer {
-		return taskFailurePolicy, positional, programArgs, false, fmt.Errorf("invalid --task-failure-policy: %s", taskFailurePolicy)
+	if _, ok := interpreter.ParseLogLevel(opts.logLevel); opts.logLevel != "" && !ok {
+		return opts, positional, false, fmt.Errorf("invalid --log-level: %s", opts.logLevel)
+	}
+	if opts.logFormat != "" && opts.logFormat != interpreter.LogFormatText && opts.logFormat != interpreter.LogFormatJSON {
+		return opts, positional, false, fmt.Errorf("invalid --log-format: %s", opts.logFormat)
+	}
+	return opts, positional, false, nil
+}
+
+func (o *runOptions) setSeed(value string) error {
+	seed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid --seed: %s", value)
+	}
+	o.deterministic = true
+	o.seed = seed
+	return nil
+}
+
+func (o *runOptions) setGracePeriod(value string) error {
+	grace, err := time.ParseDuration(value)
+	if err != nil || grace < 0 {
+		return fmt.Errorf("invalid --grace-period: %s", value)
 	}
-	return taskFailurePolicy, positional, programArgs, false, nil
+	o.gracePeriod = grace
+	return nil
 }
 
 func readInput(path string) ([]byte, error) {
@@ -326,27 +638,259 @@ func parseProgram(data []byte, filename string) (*ast.Program, error) {
 	return program, nil
 }
 
-func runProgram(program *ast.Program, source string, filename string, taskFailurePolicy string, programArgs []string) (interpreter.Value, error) {
+// decodeProgram reads a program from the JSON written by
+// `karl parse --format=json`.
+func decodeProgram(data []byte) (*ast.Program, error) {
+	node, err := ast.FromJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("ast error: %v", err)
+	}
+	program, ok := node.(*ast.Program)
+	if !ok {
+		return nil, fmt.Errorf("ast error: expected a Program, got %T", node)
+	}
+	return program, nil
+}
+
+func runProgram(program *ast.Program, source string, filename string, opts runOptions) (interpreter.Value, error) {
 	eval := interpreter.NewEvaluatorWithSourceAndFilename(source, filename)
-	if err := eval.SetTaskFailurePolicy(taskFailurePolicy); err != nil {
+	if err := eval.SetTaskFailurePolicy(opts.taskFailurePolicy); err != nil {
 		return nil, err
 	}
-	eval.SetProgramArgs(programArgs)
+	if opts.deterministic {
+		eval.SetDeterministic(opts.seed)
+	}
+	if opts.logLevel != "" {
+		if err := eval.SetLogLevel(opts.logLevel); err != nil {
+			return nil, err
+		}
+	}
+	if opts.logFormat != "" {
+		if err := eval.SetLogFormat(opts.logFormat); err != nil {
+			return nil, err
+		}
+	}
+	if opts.metricsAddr != "" {
+		_, stopMetrics, err := serveMetrics(eval, opts.metricsAddr)
+		if err != nil {
+			return nil, err
+		}
+		defer stopMetrics()
+	}
+	var stopRecorders []func() error
+	for _, rec := range []struct {
+		flag, path string
+		start      func(io.Writer)
+		stop       func() error
+	}{
+		{"--trace", opts.tracePath, eval.StartTrace, eval.StopTrace},
+		{"--cpuprofile", opts.cpuProfilePath, eval.StartCPUProfile, eval.StopCPUProfile},
+		{"--allocprofile", opts.allocProfilePath, eval.StartAllocProfile, eval.StopAllocProfile},
+	} {
+		if rec.path == "" {
+			continue
+		}
+		stop, err := startRecording(rec.flag, rec.path, rec.start, rec.stop)
+		if err != nil {
+			for _, stop := range stopRecorders {
+				stop()
+			}
+			return nil, err
+		}
+		stopRecorders = append(stopRecorders, stop)
+	}
+	coverage := opts.cover || opts.coverProfilePath != "" || opts.coverHTMLPath != ""
+	if coverage {
+		eval.EnableCoverage()
+	}
+	eval.SetProgramArgs(opts.programArgs)
 	eval.SetProgramPath(filename)
+	stopDumps := dumpTasksOnSignal(eval)
+	defer stopDumps()
+	stopShutdown := shutdownOnSignal(eval, opts.gracePeriod)
+	defer stopShutdown()
+	if opts.dumpTasks {
+		defer eval.DumpTasks(os.Stderr)
+	}
 	env := interpreter.NewBaseEnvironment()
 	val, sig, err := eval.Eval(program, env)
-	if err != nil {
-		return nil, err
+	if err == nil && sig != nil {
+		err = fmt.Errorf("break/continue outside loop")
+	}
+	if err == nil && opts.daemon {
+		err = eval.WaitForSchedules()
+	}
+	if err == nil {
+		err = eval.CheckUnhandledTaskFailures()
 	}
-	if sig != nil {
-		return nil, fmt.Errorf("break/continue outside loop")
+	if hookErr := eval.RunExitHooks(); hookErr != nil {
+		if _, ok := hookErr.(*interpreter.ExitError); ok || err == nil {
+			err = hookErr
+		}
+	}
+	for _, stop := range stopRecorders {
+		if stopErr := stop(); stopErr != nil && err == nil {
+			err = stopErr
+		}
+	}
+	if coverage {
+		if coverErr := writeCoverage(eval.Coverage(), opts, os.Stderr); coverErr != nil && err == nil {
+			err = coverErr
+		}
 	}
-	if err := eval.CheckUnhandledTaskFailures(); err != nil {
+	if err != nil {
 		return nil, err
 	}
 	return val, nil
 }
 
+// writeCoverage prints the summary for --cover and writes the LCOV and HTML
+// reports. It runs even when the program failed.
+func writeCoverage(report *interpreter.CoverageReport, opts runOptions, summary io.Writer) error {
+	if opts.cover {
+		if err := report.WriteText(summary); err != nil {
+			return err
+		}
+	}
+	for _, out := range []struct {
+		flag, path string
+		write      func(io.Writer) error
+	}{
+		{"--coverprofile", opts.coverProfilePath, report.WriteLCOV},
+		{"--coverhtml", opts.coverHTMLPath, report.WriteHTML},
+	} {
+		if out.path == "" {
+			continue
+		}
+		f, err := os.Create(out.path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", out.flag, err)
+		}
+		err = out.write(f)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", out.flag, err)
+		}
+	}
+	return nil
+}
+
+// startRecording creates path and starts a trace or profile writing to it;
+// the returned function stops the recording and closes the file.
+func startRecording(flag, path string, start func(io.Writer), stop func() error) (func() error, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", flag, err)
+	}
+	start(f)
+	return func() error {
+		err := stop()
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", flag, err)
+		}
+		return nil
+	}, nil
+}
+
+// serveMetrics serves the program's metrics in the Prometheus text format at
+// /metrics on addr until the returned stop function is called.
+func serveMetrics(eval *interpreter.Evaluator, addr string) (net.Addr, func(), error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("--metrics-addr: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		io.WriteString(w, eval.MetricsText())
+	})
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go server.Serve(ln)
+	return ln.Addr(), func() { server.Close() }, nil
+}
+
+const defaultGracePeriod = 5 * time.Second
+
+var shutdownSignals = map[os.Signal]string{
+	syscall.SIGINT:  "SIGINT",
+	syscall.SIGTERM: "SIGTERM",
+	syscall.SIGHUP:  "SIGHUP",
+}
+
+// shutdownOnSignal handles SIGINT, SIGTERM and SIGHUP. Signals the program
+// subscribed to with onSignal run its handlers. Any other one shuts the
+// program down gracefully: tasks stop at their next safepoint, atExit hooks
+// run, and the exit status is 128+signal. If that takes longer than grace, or
+// a second signal arrives meanwhile, the process exits right away.
+func shutdownOnSignal(eval *interpreter.Evaluator, grace time.Duration) func() {
+	sigCh := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	for sig := range shutdownSignals {
+		signal.Notify(sigCh, sig)
+	}
+	go func() {
+		code := 0
+		var deadline <-chan time.Time
+		for {
+			select {
+			case sig := <-sigCh:
+				if code != 0 {
+					os.Exit(code)
+				}
+				if eval.DeliverSignal(shutdownSignals[sig]) {
+					continue
+				}
+				code = 128 + signalNumber(sig)
+				eval.RequestExit(code)
+				deadline = time.After(grace)
+			case <-deadline:
+				fmt.Fprintf(os.Stderr, "shutdown did not finish within %s, forcing exit\n", grace)
+				os.Exit(code)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(sigCh)
+		close(done)
+	}
+}
+
+func signalNumber(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return int(s)
+	}
+	return 1
+}
+
+// dumpTasksOnSignal prints the task tree on SIGQUIT for as long as the program
+// runs, instead of Go's default goroutine dump and exit.
+func dumpTasksOnSignal(eval *interpreter.Evaluator) func() {
+	sigCh := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(sigCh, syscall.SIGQUIT)
+	go func() {
+		for {
+			select {
+			case <-sigCh:
+				eval.DumpTasks(os.Stderr)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(sigCh)
+		close(done)
+	}
+}
+
 func loomCommand(args []string) int {
 	if len(args) == 0 {
 		return replCommand(nil)
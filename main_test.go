@@ -1,33 +1,43 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 
+	"karl/ast"
 	"karl/interpreter"
 )
 
 func TestParseRunArgsProgramArgsSeparator(t *testing.T) {
-	policy, positional, programArgs, help, err := parseRunArgs([]string{"app.k", "--", "a", "b"})
+	opts, positional, help, err := parseRunArgs([]string{"app.k", "--", "a", "b"})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 	if help {
 		t.Fatalf("expected help=false")
 	}
-	if policy != interpreter.TaskFailurePolicyFailFast {
-		t.Fatalf("expected default policy %q, got %q", interpreter.TaskFailurePolicyFailFast, policy)
+	if opts.taskFailurePolicy != interpreter.TaskFailurePolicyFailFast {
+		t.Fatalf("expected default policy %q, got %q", interpreter.TaskFailurePolicyFailFast, opts.taskFailurePolicy)
 	}
 	if len(positional) != 1 || positional[0] != "app.k" {
 		t.Fatalf("unexpected positional: %#v", positional)
 	}
-	if len(programArgs) != 2 || programArgs[0] != "a" || programArgs[1] != "b" {
-		t.Fatalf("unexpected programArgs: %#v", programArgs)
+	if len(opts.programArgs) != 2 || opts.programArgs[0] != "a" || opts.programArgs[1] != "b" {
+		t.Fatalf("unexpected programArgs: %#v", opts.programArgs)
 	}
 }
 
 func TestParseRunArgsProgramArgsCanLookLikeFlags(t *testing.T) {
-	policy, positional, programArgs, help, err := parseRunArgs([]string{
+	opts, positional, help, err := parseRunArgs([]string{
 		"--task-failure-policy=defer",
 		"app.k",
 		"--",
@@ -40,19 +50,19 @@ func TestParseRunArgsProgramArgsCanLookLikeFlags(t *testing.T) {
 	if help {
 		t.Fatalf("expected help=false")
 	}
-	if policy != interpreter.TaskFailurePolicyDefer {
-		t.Fatalf("expected policy %q, got %q", interpreter.TaskFailurePolicyDefer, policy)
+	if opts.taskFailurePolicy != interpreter.TaskFailurePolicyDefer {
+		t.Fatalf("expected policy %q, got %q", interpreter.TaskFailurePolicyDefer, opts.taskFailurePolicy)
 	}
 	if len(positional) != 1 || positional[0] != "app.k" {
 		t.Fatalf("unexpected positional: %#v", positional)
 	}
-	if len(programArgs) != 2 || programArgs[0] != "-x" || programArgs[1] != "--y" {
-		t.Fatalf("unexpected programArgs: %#v", programArgs)
+	if len(opts.programArgs) != 2 || opts.programArgs[0] != "-x" || opts.programArgs[1] != "--y" {
+		t.Fatalf("unexpected programArgs: %#v", opts.programArgs)
 	}
 }
 
 func TestParseRunArgsRejectsProgramArgsWithoutSeparator(t *testing.T) {
-	_, _, _, _, err := parseRunArgs([]string{"app.k", "a", "b"})
+	_, _, _, err := parseRunArgs([]string{"app.k", "a", "b"})
 	if err == nil {
 		t.Fatalf("expected error")
 	}
@@ -62,21 +72,151 @@ func TestParseRunArgsRejectsProgramArgsWithoutSeparator(t *testing.T) {
 }
 
 func TestParseRunArgsStdinWithProgramArgs(t *testing.T) {
-	policy, positional, programArgs, help, err := parseRunArgs([]string{"-", "--", "foo"})
+	opts, positional, help, err := parseRunArgs([]string{"-", "--", "foo"})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 	if help {
 		t.Fatalf("expected help=false")
 	}
-	if policy != interpreter.TaskFailurePolicyFailFast {
-		t.Fatalf("expected default policy %q, got %q", interpreter.TaskFailurePolicyFailFast, policy)
+	if opts.taskFailurePolicy != interpreter.TaskFailurePolicyFailFast {
+		t.Fatalf("expected default policy %q, got %q", interpreter.TaskFailurePolicyFailFast, opts.taskFailurePolicy)
 	}
 	if len(positional) != 1 || positional[0] != "-" {
 		t.Fatalf("unexpected positional: %#v", positional)
 	}
-	if len(programArgs) != 1 || programArgs[0] != "foo" {
-		t.Fatalf("unexpected programArgs: %#v", programArgs)
+	if len(opts.programArgs) != 1 || opts.programArgs[0] != "foo" {
+		t.Fatalf("unexpected programArgs: %#v", opts.programArgs)
+	}
+}
+
+func TestParseRunArgsSeedEnablesDeterministicMode(t *testing.T) {
+	opts, positional, _, err := parseRunArgs([]string{"--seed=42", "app.k"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !opts.deterministic || opts.seed != 42 {
+		t.Fatalf("expected deterministic seed 42, got %+v", opts)
+	}
+	if len(positional) != 1 || positional[0] != "app.k" {
+		t.Fatalf("unexpected positional: %#v", positional)
+	}
+
+	opts, _, _, err = parseRunArgs([]string{"app.k"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.deterministic {
+		t.Fatalf("expected deterministic mode to be off without --seed")
+	}
+
+	if _, _, _, err := parseRunArgs([]string{"--seed", "abc", "app.k"}); err == nil || !strings.Contains(err.Error(), "invalid --seed") {
+		t.Fatalf("expected invalid seed error, got %v", err)
+	}
+}
+
+func TestParseRunArgsDumpTasks(t *testing.T) {
+	opts, positional, _, err := parseRunArgs([]string{"--dump-tasks", "app.k"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !opts.dumpTasks {
+		t.Fatalf("expected dumpTasks to be set")
+	}
+	if len(positional) != 1 || positional[0] != "app.k" {
+		t.Fatalf("unexpected positional: %#v", positional)
+	}
+}
+
+func TestRunProgramDaemonWaitsForSchedules(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "ticks.txt")
+	source := fmt.Sprintf(`
+every(5, () -> appendFile(%q, "tick\n"), { limit: 3 })
+after(10, () -> appendFile(%q, "once\n"))
+`, out, out)
+	program, err := parseProgram([]byte(source), "daemon.k")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	opts, _, _, err := parseRunArgs([]string{"--daemon", "daemon.k"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !opts.daemon {
+		t.Fatalf("expected daemon to be set")
+	}
+	if _, err := runProgram(program, source, "daemon.k", opts); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if got := strings.Count(string(data), "tick"); got != 3 {
+		t.Fatalf("expected 3 ticks, got %q", data)
+	}
+	if !strings.Contains(string(data), "once") {
+		t.Fatalf("expected after() to run, got %q", data)
+	}
+}
+
+func TestRunCommandUsesExitCode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "exit.k")
+	if err := os.WriteFile(path, []byte(`exit("stopping", 3)`), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if code := runCommand([]string{path}); code != 3 {
+		t.Fatalf("expected exit code 3, got %d", code)
+	}
+}
+
+func TestRunProgramShutsDownGracefullyOnSIGTERM(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals cannot be sent to the current process on windows")
+	}
+	out := filepath.Join(t.TempDir(), "hook.txt")
+	source := fmt.Sprintf(`
+atExit(() -> writeFile(%q, "cleaned up"))
+let worker = spawn(() -> {
+    for true { sleep(5) }
+})()
+wait worker
+`, out)
+	program, err := parseProgram([]byte(source), "graceful.k")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	opts, _, _, err := parseRunArgs([]string{"--grace-period=2s", "graceful.k"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		proc, _ := os.FindProcess(os.Getpid())
+		_ = proc.Signal(syscall.SIGTERM)
+	}()
+	_, err = runProgram(program, source, "graceful.k", opts)
+	exitErr, ok := err.(*interpreter.ExitError)
+	if !ok || exitErr.Code != 143 {
+		t.Fatalf("expected exit code 143, got %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil || string(data) != "cleaned up" {
+		t.Fatalf("expected atExit hook to run, got %q (%v)", data, err)
+	}
+}
+
+func TestParseRunArgsGracePeriod(t *testing.T) {
+	opts, _, _, err := parseRunArgs([]string{"app.k"})
+	if err != nil || opts.gracePeriod != defaultGracePeriod {
+		t.Fatalf("expected default grace period, got %v (%v)", opts.gracePeriod, err)
+	}
+	opts, _, _, err = parseRunArgs([]string{"--grace-period", "250ms", "app.k"})
+	if err != nil || opts.gracePeriod != 250*time.Millisecond {
+		t.Fatalf("expected 250ms grace period, got %v (%v)", opts.gracePeriod, err)
+	}
+	if _, _, _, err := parseRunArgs([]string{"--grace-period=soon", "app.k"}); err == nil || !strings.Contains(err.Error(), "invalid --grace-period") {
+		t.Fatalf("expected invalid grace period error, got %v", err)
 	}
 }
 
@@ -127,3 +267,220 @@ func TestVersionCommandRejectsArgs(t *testing.T) {
 		t.Fatalf("expected argument error, got %q", errOut.String())
 	}
 }
+
+func TestRunProgramAppliesLogFlags(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "app.log")
+	source := fmt.Sprintf(`
+let log = logger({ sink: { file: %q } })
+log.debug("hidden")
+log.warn("disk low", { free: 5 })
+`, out)
+	program, err := parseProgram([]byte(source), "logs.k")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	opts, _, _, err := parseRunArgs([]string{"--log-level=warn", "--log-format", "json", "logs.k"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := runProgram(program, source, "logs.k", opts); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], `"level":"warn","msg":"disk low","free":5}`) {
+		t.Fatalf("expected one JSON warn line, got %q", data)
+	}
+
+	for _, args := range [][]string{{"--log-level=loud", "app.k"}, {"--log-format=xml", "app.k"}} {
+		if _, _, _, err := parseRunArgs(args); err == nil || !strings.Contains(err.Error(), "invalid --log-") {
+			t.Fatalf("%v: expected invalid log flag error, got %v", args, err)
+		}
+	}
+}
+
+func TestServeMetricsExposesMetricsText(t *testing.T) {
+	opts, _, _, err := parseRunArgs([]string{"--metrics-addr", "127.0.0.1:0", "app.k"})
+	if err != nil || opts.metricsAddr != "127.0.0.1:0" {
+		t.Fatalf("expected metrics addr to be parsed, got %q (%v)", opts.metricsAddr, err)
+	}
+	eval := interpreter.NewEvaluator()
+	program, err := parseProgram([]byte(`counter("jobs_total").inc(3)`), "metrics.k")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if _, _, err := eval.Eval(program, interpreter.NewBaseEnvironment()); err != nil {
+		t.Fatalf("eval: %v", err)
+	}
+	addr, stop, err := serveMetrics(eval, opts.metricsAddr)
+	if err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+	defer stop()
+	resp, err := http.Get("http://" + addr.String() + "/metrics")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") || !strings.Contains(string(body), "jobs_total 3\n") {
+		t.Fatalf("unexpected metrics response %q: %s", resp.Header.Get("Content-Type"), body)
+	}
+}
+
+func TestRunProgramWritesTrace(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "trace.json")
+	opts, _, _, err := parseRunArgs([]string{"--trace", out, "app.k"})
+	if err != nil || opts.tracePath != out {
+		t.Fatalf("expected trace path to be parsed, got %q (%v)", opts.tracePath, err)
+	}
+	source := "let work = () -> sleep(1)\nwait & work()\n"
+	program, err := parseProgram([]byte(source), "app.k")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if _, err := runProgram(program, source, "app.k", opts); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	var doc struct {
+		TraceEvents []map[string]interface{} `json:"traceEvents"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("trace is not valid JSON: %v\n%s", err, data)
+	}
+	if !strings.Contains(string(data), `"name":"work (app.k:1)"`) || !strings.Contains(string(data), `"name":"sleep"`) {
+		t.Fatalf("expected work and sleep spans, got:\n%s", data)
+	}
+}
+
+func TestRunProgramWritesProfiles(t *testing.T) {
+	dir := t.TempDir()
+	cpu := filepath.Join(dir, "cpu.pb.gz")
+	alloc := filepath.Join(dir, "alloc.pb.gz")
+	opts, _, _, err := parseRunArgs([]string{"--cpuprofile", cpu, "--allocprofile=" + alloc, "app.k"})
+	if err != nil || opts.cpuProfilePath != cpu || opts.allocProfilePath != alloc {
+		t.Fatalf("expected profile paths to be parsed, got %+v (%v)", opts, err)
+	}
+	source := "let words = [\"a\", \"b\"].map(w -> w + \"!\")\n"
+	program, err := parseProgram([]byte(source), "app.k")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if _, err := runProgram(program, source, "app.k", opts); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	for _, path := range []string{cpu, alloc} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+			t.Fatalf("expected a gzipped profile in %s", path)
+		}
+	}
+}
+
+func TestRunProgramWritesCoverageReports(t *testing.T) {
+	dir := t.TempDir()
+	lcov := filepath.Join(dir, "lcov.info")
+	html := filepath.Join(dir, "cover.html")
+	opts, _, _, err := parseRunArgs([]string{"--coverprofile", lcov, "--coverhtml=" + html, "app.k"})
+	if err != nil || opts.coverProfilePath != lcov || opts.coverHTMLPath != html || opts.cover {
+		t.Fatalf("expected coverage paths to be parsed, got %+v (%v)", opts, err)
+	}
+	source := "let f = (x) -> x * 2\nlet g = (x) -> x + 1\nf(2)\n"
+	program, err := parseProgram([]byte(source), "app.k")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if _, err := runProgram(program, source, "app.k", opts); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	data, err := os.ReadFile(lcov)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !strings.Contains(string(data), "SF:app.k\n") || !strings.Contains(string(data), "DA:1,1\nDA:2,0\nDA:3,1\nLF:3\nLH:2\n") {
+		t.Fatalf("unexpected LCOV:\n%s", data)
+	}
+	if data, err := os.ReadFile(html); err != nil || !strings.Contains(string(data), "<h2 id=\"file0\">app.k</h2>") {
+		t.Fatalf("expected HTML report, got %v:\n%s", err, data)
+	}
+}
+
+func TestRunProgramFromJSONAST(t *testing.T) {
+	opts, positional, _, err := parseRunArgs([]string{"--ast", "app.json"})
+	if err != nil || !opts.ast || len(positional) != 1 {
+		t.Fatalf("expected --ast to be parsed, got %+v %v (%v)", opts, positional, err)
+	}
+	source := "let f = (x) -> x * 2\nmatch f(3) { case 6 -> \"six\" case _ -> \"other\" }\n"
+	parsed, err := parseProgram([]byte(source), "app.k")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	data, err := ast.FormatJSON(parsed)
+	if err != nil {
+		t.Fatalf("format: %v", err)
+	}
+	program, err := decodeProgram([]byte(data))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	val, err := runProgram(program, "", "app.json", opts)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if val.Inspect() != "\"six\"" {
+		t.Fatalf("expected \"six\", got %s", val.Inspect())
+	}
+	if _, err := decodeProgram([]byte(`{"type": "IntegerLiteral", "value": 1}`)); err == nil || !strings.Contains(err.Error(), "expected a Program") {
+		t.Fatalf("expected a Program error, got %v", err)
+	}
+}
+
+func TestMigrateCommand(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "old.k")
+	source := "let id = x -> x\nlet xs = [1, 2]\nlog(id\n(xs)\n[1])\nlet y = id\n(3)\nlet z = xs // comment\n[0]\n"
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out, errOut strings.Builder
+	if code := migrateCommand([]string{path}, &out, &errOut); code != 1 {
+		t.Fatalf("expected exit code 1, got %d (%s)", code, errOut.String())
+	}
+	want := path + ":7:1: ( at the start of a line now begins a new statement; it used to continue line 6 as a call\n" +
+		path + ":9:1: [ at the start of a line now begins a new statement; it used to continue line 8 as an index\n"
+	if out.String() != want {
+		t.Fatalf("expected\n%s\ngot\n%s", want, out.String())
+	}
+
+	out.Reset()
+	if code := migrateCommand([]string{"--write", path}, &out, &errOut); code != 1 {
+		t.Fatalf("expected exit code 1 for the comment, got %d", code)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "let y = id(3)\n") || !strings.Contains(string(data), "xs // comment\n[0]") {
+		t.Fatalf("unexpected rewrite:\n%s", data)
+	}
+
+	fixed := filepath.Join(dir, "new.k")
+	if err := os.WriteFile(fixed, []byte("let y = f(3)\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if code := migrateCommand([]string{fixed}, &out, &errOut); code != 0 || out.Len() != 0 {
+		t.Fatalf("expected a clean file to pass, got %d %q", code, out.String())
+	}
+}
@@ -127,7 +127,7 @@ func (r *Runner) RunInteractive(nb *Notebook, step bool, replMode bool, filename
 			}
 			
 			if len(errs) > 0 {
-				fmt.Printf("Parse error: %v\n", errs)
+				fmt.Printf("Parse error:\n%s\n", parser.FormatParseErrors(errs, input, "<notebook>"))
 				inputBuffer.Reset()
 				multiline = false
 				continue
@@ -170,10 +170,9 @@ func (r *Runner) executeCell(cellIndex int, cell Cell) (CellOutput, error) {
 	p := parser.New(l)
 	program := p.ParseProgram()
 
-	if errs := p.Errors(); len(errs) > 0 {
-		errMsg := fmt.Sprintf("Parse error: %s", errs[0])
+	if errs := p.ErrorsDetailed(); len(errs) > 0 {
 		output.Error = &ExecutionError{
-			Message: errMsg,
+			Message: parser.FormatParseErrors(errs, cell.Source, fmt.Sprintf("<cell %d>", cellIndex)),
 			Type:    "ParseError",
 		}
 		return output, nil
@@ -0,0 +1,84 @@
+package parser
+
+import (
+	"karl/ast"
+	"karl/lexer"
+	"karl/token"
+)
+
+// Newlines and statement boundaries
+//
+// Karl has no required statement terminator, so an expression can run on
+// to the next line. That is what trailing operators and leading `.` chains
+// rely on:
+//
+//	let total = price *
+//	    quantity
+//	let names = users
+//	    .filter(u -> u.active)
+//	    .map(u -> u.name)
+//
+// A bracket at the start of a line is the exception. `(`, `[` and `{` can
+// both begin a new expression and continue the one before as a call, an
+// index or a struct initializer, so outside parentheses and square
+// brackets a newline before one of them ends the expression:
+//
+//	foo
+//	(bar)    // two statements, not foo(bar)
+//
+// Inside parentheses and square brackets newlines never end anything.
+
+// Continuation is a bracket at the start of a line that older versions of
+// the parser read as continuing the expression on the line before it.
+type Continuation struct {
+	// Token is the bracket.
+	Token token.Token
+	// Prev is the last token of the expression it continued.
+	Prev token.Token
+}
+
+// FindContinuations parses input with the old rule, under which a bracket
+// at the start of a line continues the previous expression, and returns the
+// places where that rule applied. Each one means something different now.
+func FindContinuations(input string) []Continuation {
+	p := New(lexer.New(input))
+	p.legacyNewlines = true
+	p.ParseProgram()
+	return p.continuations
+}
+
+// peekEndsExpression reports whether a newline before peekToken, a bracket
+// that could continue the expression ending at curToken, ends it instead.
+// Under the old rule it records the continuation and returns false.
+func (p *Parser) peekEndsExpression() bool {
+	if !p.peekTokenIs(token.LPAREN) && !p.peekTokenIs(token.LBRACKET) && !p.peekTokenIs(token.LBRACE) {
+		return false
+	}
+	if p.peekToken.Line <= ast.TokenSpan(p.curToken).End.Line {
+		return false
+	}
+	switch p.openBracket() {
+	case token.LPAREN, token.LBRACKET:
+		return false
+	}
+	if p.legacyNewlines {
+		p.continuations = append(p.continuations, Continuation{Token: p.peekToken, Prev: p.curToken})
+		return false
+	}
+	return true
+}
+
+// openBracket returns the innermost bracket left open after curToken, or
+// EOF at the top level.
+func (p *Parser) openBracket() token.TokenType {
+	saved := p.brackets
+	p.brackets = append([]token.TokenType(nil), saved...)
+	p.trackBrackets(p.curToken.Type)
+	var open token.TokenType = token.EOF
+	if n := len(p.brackets); n > 0 {
+		open = p.brackets[n-1]
+	}
+	p.brackets = saved
+	p.depth = len(saved)
+	return open
+}
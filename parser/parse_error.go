@@ -4,12 +4,17 @@ import (
 	"fmt"
 	"strings"
 
+	"karl/ast"
 	"karl/token"
 )
 
+// ParseError is one syntax error. Token is where it was detected and Span
+// the source it covers: the offending token, or the whole node for errors
+// such as an invalid assignment target.
 type ParseError struct {
 	Message string
 	Token   token.Token
+	Span    ast.Span
 }
 
 func FormatParseErrors(errs []ParseError, source string, filename string) string {
@@ -40,7 +45,15 @@ func formatParseError(err ParseError, source string, filename string) string {
 	if col > len(lineText)+1 {
 		col = len(lineText) + 1
 	}
-	caret := strings.Repeat(" ", col-1) + "^"
+	width := 1
+	if span := err.Span; span.Start.Line == line && span.End.Line == line && span.End.Column > span.Start.Column {
+		col = span.Start.Column
+		width = span.End.Column - span.Start.Column
+	}
+	if col+width > len(lineText)+1 {
+		width = max(len(lineText)+1-col, 1)
+	}
+	caret := strings.Repeat(" ", col-1) + strings.Repeat("^", width)
 	location := fmt.Sprintf("%d:%d", line, err.Token.Column)
 	if filename != "" {
 		location = fmt.Sprintf("%s:%s", filename, location)
@@ -6,6 +6,7 @@ import (
 	"karl/lexer"
 	"karl/token"
 	"strconv"
+	"strings"
 )
 
 type (
@@ -25,12 +26,24 @@ type Parser struct {
 	infixParseFns  map[token.TokenType]infixParseFn
 
 	allowLambda bool
+
+	// legacyNewlines applies the old rule that a bracket at the start of a
+	// line continues the previous expression; see newlines.go.
+	legacyNewlines bool
+	continuations  []Continuation
+
+	// Error recovery state; see recovery.go.
+	brackets  []token.TokenType
+	depth     int
+	prevLine  int
+	panicking bool
 }
 
 const (
 	_ int = iota
 	LOWEST
 	ASSIGN
+	PIPELINE
 	OR
 	AND
 	EQUALS
@@ -49,6 +62,7 @@ var precedences = map[token.TokenType]int{
 	token.ASTERISK_ASSIGN: ASSIGN,
 	token.SLASH_ASSIGN:    ASSIGN,
 	token.PERCENT_ASSIGN:  ASSIGN,
+	token.PIPELINE:        PIPELINE,
 	token.OR:              OR,
 	token.AND:             AND,
 	token.EQ:              EQUALS,
@@ -91,6 +105,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.AMPERSAND, p.parseSpawnExpression)
 	p.registerPrefix(token.RACE, p.parseRaceExpression)
 	p.registerPrefix(token.PIPE, p.parseReservedPipeExpression)
+	p.registerPrefix(token.ILLEGAL, p.parseIllegal)
 	p.registerPrefix(token.LPAREN, p.parseGroupedOrLambda)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.MATCH, p.parseMatchExpression)
@@ -116,6 +131,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.GE, p.parseInfixExpression)
 	p.registerInfix(token.AND, p.parseInfixExpression)
 	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.PIPELINE, p.parsePipelineExpression)
 	p.registerInfix(token.DOTDOT, p.parseRangeExpression)
 	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
 	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignExpression)
@@ -151,28 +167,68 @@ func (p *Parser) ErrorsDetailed() []ParseError {
 	return p.errors
 }
 
+// addError reports a syntax error at tok. Once an error is reported the
+// parser is out of step with the source, so further errors are dropped
+// until it resynchronizes at a statement or match arm boundary; see
+// recovery.go.
 func (p *Parser) addError(tok token.Token, msg string) {
-	p.errors = append(p.errors, ParseError{Message: msg, Token: tok})
+	if p.panicking {
+		return
+	}
+	p.panicking = true
+	if n := len(p.errors); tok.Type == token.EOF && n > 0 && p.errors[n-1].Token.Type == token.EOF {
+		// Every construct left open at the end reports it; once is enough.
+		return
+	}
+	p.errors = append(p.errors, ParseError{Message: msg, Token: tok, Span: ast.TokenSpan(tok)})
+}
+
+// addNodeError reports an error about a well-formed node, such as an invalid
+// assignment target. Parsing can carry on normally afterwards.
+func (p *Parser) addNodeError(node ast.Node, msg string) {
+	if p.panicking {
+		return
+	}
+	tok := ast.NodeToken(node)
+	if tok == nil {
+		tok = &p.curToken
+	}
+	p.errors = append(p.errors, ParseError{Message: msg, Token: *tok, Span: ast.NodeSpan(node)})
 }
 
 func (p *Parser) nextToken() {
+	p.trackBrackets(p.curToken.Type)
+	p.prevLine = p.curToken.Line
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
 }
 
+// ParseProgram parses the whole input. When there are syntax errors it
+// still returns the statements it could parse; check Errors.
 func (p *Parser) ParseProgram() *ast.Program {
 	program := &ast.Program{}
-	program.Statements = []ast.Statement{}
+	program.Statements = p.parseStatements(false)
+	return program
+}
 
-	for p.curToken.Type != token.EOF {
+// parseStatements parses statements up to EOF or, inside a block, up to the
+// closing brace, recovering from errors at statement boundaries.
+func (p *Parser) parseStatements(inBlock bool) []ast.Statement {
+	statements := []ast.Statement{}
+	outer := p.depth
+	for !p.curTokenIs(token.EOF) && !(inBlock && p.curTokenIs(token.RBRACE)) && p.depth >= outer {
+		start, depth := p.curToken, p.depth
 		stmt := p.parseStatement()
 		if !statementIsNil(stmt) {
-			program.Statements = append(program.Statements, stmt)
+			statements = append(statements, stmt)
+		}
+		if p.panicking {
+			p.synchronize(start, depth, inBlock)
+			continue
 		}
 		p.nextToken()
 	}
-
-	return program
+	return statements
 }
 
 func (p *Parser) parseStatement() ast.Statement {
@@ -223,6 +279,9 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 	stmt.Expression = p.parseExpression(LOWEST)
+	if stmt.Expression == nil {
+		return nil
+	}
 
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
@@ -240,6 +299,9 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	leftExp := prefix()
 
 	for !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
+		if p.peekEndsExpression() {
+			return leftExp
+		}
 		infix := p.infixParseFns[p.peekToken.Type]
 		if infix == nil {
 			return leftExp
@@ -262,7 +324,7 @@ func (p *Parser) parseIdentifier() ast.Expression {
 		return p.finishLambda([]ast.Pattern{param})
 	}
 	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	if p.peekTokenIs(token.LBRACE) && p.braceLooksLikeObject() {
+	if p.peekTokenIs(token.LBRACE) && p.braceLooksLikeObject() && !p.peekEndsExpression() {
 		p.nextToken()
 		obj := p.parseObjectLiteral().(*ast.ObjectLiteral)
 		return &ast.StructInitExpression{Token: ident.Token, TypeName: ident, Value: obj}
@@ -293,7 +355,19 @@ func (p *Parser) parseFloatLiteral() ast.Expression {
 }
 
 func (p *Parser) parseStringLiteral() ast.Expression {
-	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+	return newStringLiteral(p.curToken)
+}
+
+// newStringLiteral builds a string node, recording from the token's source
+// whether it was written raw (r"...") or triple-quoted.
+func newStringLiteral(tok token.Token) *ast.StringLiteral {
+	quoted := strings.TrimPrefix(tok.Source, "r")
+	return &ast.StringLiteral{
+		Token:     tok,
+		Value:     tok.Literal,
+		Raw:       len(quoted) < len(tok.Source),
+		Multiline: strings.HasPrefix(quoted, `"""`),
+	}
 }
 
 func (p *Parser) parseCharLiteral() ast.Expression {
@@ -327,7 +401,7 @@ func (p *Parser) parseImportExpression() ast.Expression {
 	if !p.expectPeek(token.STRING) {
 		return nil
 	}
-	expr.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+	expr.Path = newStringLiteral(p.curToken)
 	return expr
 }
 
@@ -341,7 +415,7 @@ func (p *Parser) parseSpawnExpression() ast.Expression {
 	p.nextToken()
 	task := p.parseExpression(PREFIX)
 	if !p.isCallExpression(task) {
-		p.addError(p.curToken, "spawn target must be a call expression")
+		p.addNodeError(task, "spawn target must be a call expression")
 		return expr
 	}
 	expr.Task = task
@@ -373,7 +447,7 @@ func (p *Parser) parseRaceOrSpawnGroup() []ast.Expression {
 	for {
 		task := p.parseExpression(PREFIX)
 		if !p.isCallExpression(task) {
-			p.addError(p.curToken, "group tasks must be call expressions")
+			p.addNodeError(task, "group tasks must be call expressions")
 		}
 		group = append(group, task)
 
@@ -449,10 +523,12 @@ func (p *Parser) parseMatchExpression() ast.Expression {
 
 	expression.Arms = []ast.MatchArm{}
 	p.nextToken()
-	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+	depth := p.depth
+	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) && p.depth >= depth {
 		if !p.curTokenIs(token.CASE) {
 			p.addError(p.curToken, "expected case in match expression")
-			return expression
+			p.synchronizeArm(depth)
+			continue
 		}
 		arm := ast.MatchArm{Token: p.curToken}
 		p.nextToken()
@@ -468,11 +544,16 @@ func (p *Parser) parseMatchExpression() ast.Expression {
 		}
 
 		if !p.expectPeek(token.ARROW) {
-			return expression
+			p.synchronizeArm(depth)
+			continue
 		}
 		p.nextToken()
 		arm.Body = p.parseExpression(LOWEST)
 		expression.Arms = append(expression.Arms, arm)
+		if p.panicking {
+			p.synchronizeArm(depth)
+			continue
+		}
 
 		if p.peekTokenIs(token.SEMICOLON) {
 			p.nextToken()
@@ -652,16 +733,9 @@ func (p *Parser) parseBraceExpression() ast.Expression {
 
 func (p *Parser) parseBlockExpression() *ast.BlockExpression {
 	block := &ast.BlockExpression{Token: p.curToken}
-	block.Statements = []ast.Statement{}
 
 	p.nextToken()
-	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
-		stmt := p.parseStatement()
-		if !statementIsNil(stmt) {
-			block.Statements = append(block.Statements, stmt)
-		}
-		p.nextToken()
-	}
+	block.Statements = p.parseStatements(true)
 
 	return block
 }
@@ -723,9 +797,38 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return expression
 }
 
+// parsePipelineExpression parses `x |> f`. The right side is kept as written;
+// the evaluator applies whatever it yields to the left value, so a call with
+// placeholders such as `f(_, 2)` receives the value in the placeholder's slot.
+func (p *Parser) parsePipelineExpression(left ast.Expression) ast.Expression {
+	expression := &ast.InfixExpression{Token: p.curToken, Operator: p.curToken.Literal, Left: left}
+	if p.prefixParseFns[p.peekToken.Type] == nil {
+		p.addError(p.curToken, "expected a function after '|>'")
+		return expression
+	}
+	p.nextToken()
+	expression.Right = p.parseExpression(PIPELINE)
+	if !canPipeInto(expression.Right) {
+		p.addNodeError(expression.Right, "right side of '|>' must be a function or a call")
+	}
+	return expression
+}
+
+// canPipeInto reports whether e could evaluate to something callable; literals
+// never can, so piping into one is reported while parsing.
+func canPipeInto(e ast.Expression) bool {
+	switch e.(type) {
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.CharLiteral,
+		*ast.BooleanLiteral, *ast.NullLiteral, *ast.ArrayLiteral, *ast.ObjectLiteral,
+		*ast.StructInitExpression, *ast.RangeExpression, *ast.Placeholder:
+		return false
+	}
+	return true
+}
+
 func (p *Parser) parseAssignExpression(left ast.Expression) ast.Expression {
 	if !isAssignable(left) {
-		p.addError(p.curToken, "invalid assignment target")
+		p.addNodeError(left, "invalid assignment target")
 	}
 	expression := &ast.AssignExpression{Token: p.curToken, Operator: p.curToken.Literal, Left: left}
 	precedence := p.curPrecedence()
@@ -739,10 +842,10 @@ func (p *Parser) parseRangeExpression(left ast.Expression) ast.Expression {
 	p.nextToken()
 	expression.End = p.parseExpression(RANGE)
 	if _, ok := left.(*ast.FloatLiteral); ok {
-		p.addError(left.(*ast.FloatLiteral).Token, "float ranges are not allowed")
+		p.addNodeError(left, "float ranges are not allowed")
 	}
 	if _, ok := expression.End.(*ast.FloatLiteral); ok {
-		p.addError(expression.End.(*ast.FloatLiteral).Token, "float ranges are not allowed")
+		p.addNodeError(expression.End, "float ranges are not allowed")
 	}
 	if p.peekTokenIs(token.STEP) || (p.peekTokenIs(token.IDENT) && p.peekToken.Literal == "step") {
 		p.nextToken()
@@ -857,7 +960,10 @@ func (p *Parser) parsePatternFromToken(tok token.Token) ast.Pattern {
 		return &ast.Identifier{Token: tok, Value: tok.Literal}
 	case token.INT:
 		p.curToken = tok
-		lit := p.parseIntegerLiteral().(*ast.IntegerLiteral)
+		lit, ok := p.parseIntegerLiteral().(*ast.IntegerLiteral)
+		if !ok {
+			return nil
+		}
 		if p.peekTokenIs(token.DOTDOT) {
 			p.nextToken()
 			p.nextToken()
@@ -867,7 +973,10 @@ func (p *Parser) parsePatternFromToken(tok token.Token) ast.Pattern {
 		return lit
 	case token.FLOAT:
 		p.curToken = tok
-		lit := p.parseFloatLiteral().(*ast.FloatLiteral)
+		lit, ok := p.parseFloatLiteral().(*ast.FloatLiteral)
+		if !ok {
+			return nil
+		}
 		if p.peekTokenIs(token.DOTDOT) {
 			p.nextToken()
 			p.nextToken()
@@ -876,7 +985,7 @@ func (p *Parser) parsePatternFromToken(tok token.Token) ast.Pattern {
 		}
 		return lit
 	case token.STRING:
-		lit := &ast.StringLiteral{Token: tok, Value: tok.Literal}
+		lit := newStringLiteral(tok)
 		if p.peekTokenIs(token.DOTDOT) {
 			p.nextToken()
 			p.nextToken()
@@ -1153,6 +1262,22 @@ func (p *Parser) peekError(t token.TokenType) {
 	p.addError(p.peekToken, msg)
 }
 
+// parseIllegal reports a token the lexer could not make sense of. A literal
+// ending in a quote is the opening delimiter of a string that was never
+// closed.
+func (p *Parser) parseIllegal() ast.Expression {
+	literal := p.curToken.Literal
+	switch {
+	case strings.HasSuffix(literal, `"""`):
+		p.addError(p.curToken, `unterminated string: missing closing """`)
+	case strings.HasSuffix(literal, `"`):
+		p.addError(p.curToken, `unterminated string: missing closing "`)
+	default:
+		p.noPrefixParseFnError(p.curToken.Type)
+	}
+	return nil
+}
+
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
 	msg := fmt.Sprintf("no prefix parse function for %s found", t)
 	p.addError(p.curToken, msg)
@@ -0,0 +1,91 @@
+package parser
+
+import "karl/token"
+
+// Error recovery
+//
+// After a syntax error the parser is in panic mode: addError drops further
+// errors, since they are usually knock-on effects of the first one. The
+// statement loop then skips tokens up to the next synchronization point and
+// carries on, so independent errors are each reported once and the program
+// still gets a best-effort AST of the statements that did parse.
+//
+// nextToken tracks the brackets open at curToken, which lets the parser skip
+// whole bracketed regions and recognize boundaries at the depth where the
+// broken statement started.
+
+var closingBrackets = map[token.TokenType]token.TokenType{
+	token.RPAREN:   token.LPAREN,
+	token.RBRACE:   token.LBRACE,
+	token.RBRACKET: token.LBRACKET,
+}
+
+// trackBrackets updates the open bracket stack as the parser moves past a
+// token of type t. A closing bracket also closes any brackets left open
+// inside it; one with no matching opener is stray and ignored.
+func (p *Parser) trackBrackets(t token.TokenType) {
+	switch t {
+	case token.LPAREN, token.LBRACE, token.LBRACKET:
+		p.brackets = append(p.brackets, t)
+	case token.RPAREN, token.RBRACE, token.RBRACKET:
+		open := closingBrackets[t]
+		for i := len(p.brackets) - 1; i >= 0; i-- {
+			if p.brackets[i] == open {
+				p.brackets = p.brackets[:i]
+				break
+			}
+		}
+	}
+	p.depth = len(p.brackets)
+}
+
+// synchronize skips the rest of the statement that began at start, at bracket
+// depth depth. It stops before the next statement: after a semicolon, at let,
+// at a token that begins a new line and can begin an expression, at the brace
+// closing the enclosing block, or at EOF. A let that begins a line no further
+// right than start also ends the statement when brackets inside it were left
+// open, as in
+//
+//	let x = (1 + 2
+//	let y = 3
+func (p *Parser) synchronize(start token.Token, depth int, inBlock bool) {
+	if p.curToken == start {
+		// The statement's first token was the problem; skip it so the
+		// loop makes progress.
+		p.nextToken()
+	}
+	for !p.curTokenIs(token.EOF) && p.depth >= depth {
+		if p.depth > depth && p.curTokenIs(token.LET) &&
+			p.curToken.Line > p.prevLine && p.curToken.Column <= start.Column {
+			p.brackets = p.brackets[:depth]
+			p.depth = depth
+		}
+		if p.depth == depth {
+			switch {
+			case p.curTokenIs(token.SEMICOLON):
+				p.nextToken()
+				p.panicking = false
+				return
+			case p.curTokenIs(token.RBRACE) && inBlock,
+				p.curTokenIs(token.LET),
+				p.curToken.Line > p.prevLine && p.prefixParseFns[p.curToken.Type] != nil:
+				p.panicking = false
+				return
+			}
+		}
+		p.nextToken()
+	}
+	p.panicking = false
+}
+
+// synchronizeArm skips to the next case of the match expression whose arms
+// are at bracket depth depth, or to its closing brace.
+func (p *Parser) synchronizeArm(depth int) {
+	for !p.curTokenIs(token.EOF) && p.depth >= depth {
+		if p.depth == depth && (p.curTokenIs(token.CASE) || p.curTokenIs(token.RBRACE)) {
+			break
+		}
+		p.nextToken()
+	}
+	p.panicking = false
+}
@@ -186,6 +186,13 @@ func start(in io.Reader, out io.Writer, opts startOptions) {
 		eval.SetSourceAndFilename(input, "<repl>")
 
 		val, sig, err := eval.Eval(program, env)
+		if exitErr, ok := err.(*interpreter.ExitError); ok {
+			// exit() ends the session rather than the process hosting it.
+			if exitErr.Message != "" {
+				fmt.Fprintf(sessionOut, "%s\n", exitErr.Message)
+			}
+			return
+		}
 		if err != nil {
 			fmt.Fprintf(sessionOut, "Error: %s\n", interpreter.FormatRuntimeError(err, input, "<repl>"))
 			if isFatalREPLError(err) {
@@ -311,6 +318,12 @@ func hasUnclosedDelimiters(input string) bool {
 			bracketDepth++
 		case token.RBRACKET:
 			bracketDepth--
+		case token.STRING:
+			// Keep reading lines until a triple-quoted string closes.
+			src := strings.TrimPrefix(tok.Source, "r")
+			if strings.HasPrefix(src, `"""`) && (len(src) < 6 || !strings.HasSuffix(src, `"""`)) {
+				return true
+			}
 		case token.EOF:
 			return parenDepth > 0 || braceDepth > 0 || bracketDepth > 0
 		}
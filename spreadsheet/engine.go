@@ -2,6 +2,7 @@ package spreadsheet
 
 import (
 	"fmt"
+	"karl/ast"
 	"karl/interpreter"
 	"karl/lexer"
 	"karl/parser"
@@ -263,17 +264,44 @@ func (s *Sheet) propagateUpdates(cell *Cell, visited map[CellID]bool) {
 }
 
 var cellIDRegex = regexp.MustCompile(`[A-Z]+[0-9]+`)
+var cellIDPattern = regexp.MustCompile(`^[A-Z]+[0-9]+$`)
 
+// extractDependencies returns the cells a formula reads: the identifiers that
+// name a cell, in order of first use. Cell names inside strings or after a dot
+// are not references. Formulas that do not parse fall back to a textual scan.
 func extractDependencies(code string) []CellID {
-    matches := cellIDRegex.FindAllString(code, -1)
-    unique := make(map[CellID]bool)
-    var deps []CellID
-    for _, m := range matches {
-        id := CellID(m)
-        if !unique[id] {
-            unique[id] = true
-            deps = append(deps, id)
-        }
-    }
-    return deps
+	p := parser.New(lexer.New(strings.TrimPrefix(code, "=")))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return uniqueCellIDs(cellIDRegex.FindAllString(code, -1))
+	}
+	var names []string
+	var visit func(ast.Node) bool
+	visit = func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.Identifier:
+			if cellIDPattern.MatchString(n.Value) {
+				names = append(names, n.Value)
+			}
+		case *ast.MemberExpression:
+			ast.Inspect(n.Object, visit)
+			return false
+		}
+		return true
+	}
+	ast.Inspect(program, visit)
+	return uniqueCellIDs(names)
+}
+
+func uniqueCellIDs(names []string) []CellID {
+	unique := make(map[CellID]bool)
+	var deps []CellID
+	for _, m := range names {
+		id := CellID(m)
+		if !unique[id] {
+			unique[id] = true
+			deps = append(deps, id)
+		}
+	}
+	return deps
 }
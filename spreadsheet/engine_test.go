@@ -72,3 +72,18 @@ func TestChainedDependencies(t *testing.T) {
 		t.Errorf("Expected C1 to update to 6, got %v", c1.Value)
 	}
 }
+
+func TestDependenciesIgnoreStringsAndMembers(t *testing.T) {
+	s := NewSheet()
+
+	mustSetCell(t, s, "A1", "1")
+	mustSetCell(t, s, "B1", `= A1 + 1 + len("C1") + { Z9: 0 }.Z9`)
+
+	b1 := s.GetCell("B1")
+	if len(b1.Dependencies) != 1 || b1.Dependencies[0] != "A1" {
+		t.Fatalf("Expected B1 to depend on A1 only, got %v", b1.Dependencies)
+	}
+	if val, ok := b1.Value.(*interpreter.Integer); !ok || val.Value != 4 {
+		t.Errorf("Expected B1 to be 4, got %v (error %v)", b1.Value, b1.Error)
+	}
+}
@@ -0,0 +1,132 @@
+package tests
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"karl/ast"
+	"karl/interpreter"
+)
+
+func roundTripJSON(t *testing.T, program *ast.Program) (*ast.Program, string) {
+	t.Helper()
+	out, err := ast.FormatJSON(program)
+	if err != nil {
+		t.Fatalf("format: %v", err)
+	}
+	node, err := ast.FromJSON([]byte(out))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	decoded, ok := node.(*ast.Program)
+	if !ok {
+		t.Fatalf("expected *ast.Program, got %T", node)
+	}
+	return decoded, out
+}
+
+func TestASTJSONRoundTripsExamples(t *testing.T) {
+	for _, path := range listKarlFiles(t, filepath.Join("..", "examples")) {
+		path := path
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			program := parseFile(t, path)
+			decoded, out := roundTripJSON(t, program)
+			again, err := ast.FormatJSON(decoded)
+			if err != nil {
+				t.Fatalf("format decoded: %v", err)
+			}
+			if again != out {
+				t.Fatalf("JSON differs after a round trip")
+			}
+			if ast.Format(decoded) != ast.Format(program) {
+				t.Fatalf("pretty form differs after a round trip")
+			}
+		})
+	}
+}
+
+func TestASTJSONProgramRunsLikeSource(t *testing.T) {
+	input := `let add = (a, b) -> a + b
+let xs = for i < 5 with i = 0, acc = [] { acc += [add(i, 1)]; i++ } then acc
+let shape = match { kind: "circle", r: 2 } {
+    case { kind: "circle", r } -> r * r
+    case _ -> 0
+}
+let big = 9007199254740993
+let rest = { ...{ a: 1 }, b: 2 }
+let t = & add(1, 2)
+let q = from x in xs where x > 2 orderby -x select x
+let result = [xs, shape, big, rest.a + rest.b, wait t, q, xs[1..3], 'c', 2.5, null == null]
+result`
+	program := parseProgram(t, input)
+	decoded, _ := roundTripJSON(t, program)
+
+	run := func(program *ast.Program) string {
+		eval := interpreter.NewEvaluator()
+		val, _, err := eval.Eval(program, interpreter.NewBaseEnvironment())
+		if err != nil {
+			t.Fatalf("eval: %v", err)
+		}
+		return val.Inspect()
+	}
+	want := run(parseProgram(t, input))
+	if got := run(decoded); got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+	if !strings.Contains(want, "9007199254740993") {
+		t.Fatalf("large integer lost precision: %s", want)
+	}
+}
+
+func TestASTJSONWithoutPositions(t *testing.T) {
+	src := `{"type": "Program", "statements": [
+		{"type": "LetStatement", "name": {"type": "Identifier", "value": "x"},
+		 "value": {"type": "InfixExpression", "operator": "*",
+		           "left": {"type": "IntegerLiteral", "value": 6},
+		           "right": {"type": "IntegerLiteral", "value": 7}}},
+		{"type": "ExpressionStatement", "expression": {"type": "Identifier", "value": "x"}}
+	]}`
+	node, err := ast.FromJSON([]byte(src))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	val, _, err := interpreter.NewEvaluator().Eval(node, interpreter.NewBaseEnvironment())
+	if err != nil {
+		t.Fatalf("eval: %v", err)
+	}
+	assertInteger(t, val, 42)
+}
+
+func TestASTJSONPositionsSurvive(t *testing.T) {
+	program := parseProgram(t, "let a = 1\nlet b = a +\n  2")
+	decoded, _ := roundTripJSON(t, program)
+	want, got := ast.NodeSpan(program.Statements[1]), ast.NodeSpan(decoded.Statements[1])
+	if got != want {
+		t.Fatalf("expected span %+v, got %+v", want, got)
+	}
+}
+
+func TestASTJSONErrors(t *testing.T) {
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{`{"type": "Program", "statements": [{"type": "Nope"}]}`, `$.statements[0]: unknown node type "Nope"`},
+		{`{"type": "LetStatement", "name": {"type": "InfixExpression", "operator": "+"}}`, `$.name: *ast.InfixExpression is not a pattern`},
+		{`{"type": "IntegerLiteral", "value": 1.5}`, `"value" must be an integer`},
+		{`{"type": "ArrayLiteral", "elements": [null]}`, `$.elements[0]: must not be null`},
+		{`{"type": "Identifier", "value": "x", "pos": {"line": "one"}}`, `$.pos: "line" must be a number`},
+		{`{"value": 1}`, `"type" must be a string`},
+		{`[]`, `a node must be an object`},
+		{`{"type": "NullLiteral"} {}`, `unexpected data`},
+		{`{"type": "IntegerLiteral", "value": 1, "literal": "0x2"}`, `"literal" "0x2" does not match "value"`},
+		{`{"type": "StringLiteral", "value": "x", "raw": "yes"}`, `"raw" must be a boolean`},
+	}
+	for _, tc := range cases {
+		_, err := ast.FromJSON([]byte(tc.src))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tc.src, tc.want, err)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package tests
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"karl/ast"
+)
+
+// walkSource exercises every expression and pattern type the parser
+// produces (CallPattern has no syntax yet).
+const walkSource = `let {a, b: [c, ...rest]} = obj
+let (x, y) = pair
+let f = (n, _) -> n + 1
+let r = match v {
+    case (z, w) if z > 0 -> z
+    case 1..5 -> -v
+    case 'c' -> "s"
+    case true -> 1.5
+    case null -> ()
+    case _ -> wait & f(1)
+}
+let p = Point { x: 1, y }
+let q = from row in rows where row.ok orderby row.n select { n: row.n }
+let s = items[1..2][0]
+for i < 10 with i = 0, j = 0 { if i == 3 { continue } else if i == 4 { break i } i++ } then i
+let t = !& { f(1), f(2) }
+let u = (fail("x") ? 0)
+let m = import "lib.k"
+x += f(_, 2)
+`
+
+func TestInspectVisitsEveryNodeType(t *testing.T) {
+	program := parseProgram(t, walkSource)
+	seen := map[string]bool{}
+	ast.Inspect(program, func(n ast.Node) bool {
+		seen[strings.TrimPrefix(fmt.Sprintf("%T", n), "*ast.")] = true
+		return true
+	})
+	want := []string{
+		"Program", "LetStatement", "ExpressionStatement", "Identifier", "Placeholder",
+		"IntegerLiteral", "FloatLiteral", "StringLiteral", "CharLiteral", "BooleanLiteral",
+		"NullLiteral", "UnitLiteral", "PrefixExpression", "InfixExpression", "AssignExpression",
+		"PostfixExpression", "AwaitExpression", "ImportExpression", "IfExpression",
+		"BlockExpression", "MatchExpression", "ForExpression", "LambdaExpression",
+		"CallExpression", "RecoverExpression", "MemberExpression", "IndexExpression",
+		"SliceExpression", "ObjectLiteral", "StructInitExpression",
+		"QueryExpression", "RaceExpression", "SpawnExpression", "BreakExpression",
+		"ContinueExpression", "WildcardPattern", "RangePattern", "ObjectPattern",
+		"ArrayPattern", "TuplePattern",
+	}
+	for _, name := range want {
+		if !seen[name] {
+			t.Errorf("Inspect did not visit a %s", name)
+		}
+	}
+}
+
+func TestWalkHandlesExamples(t *testing.T) {
+	for _, path := range listKarlFiles(t, filepath.Join("..", "examples")) {
+		program := parseFile(t, path)
+		nodes := 0
+		ast.Inspect(program, func(ast.Node) bool {
+			nodes++
+			return true
+		})
+		if nodes < 2 {
+			t.Errorf("%s: visited only %d nodes", path, nodes)
+		}
+	}
+}
+
+type depthVisitor struct {
+	depth, max *int
+}
+
+func (v depthVisitor) Visit(node ast.Node) ast.Visitor {
+	if node == nil {
+		*v.depth--
+		return nil
+	}
+	*v.depth++
+	if *v.depth > *v.max {
+		*v.max = *v.depth
+	}
+	return v
+}
+
+func TestWalkCallsVisitNilAfterChildren(t *testing.T) {
+	program := parseProgram(t, "let x = f(1 + 2)")
+	depth, max := 0, 0
+	ast.Walk(depthVisitor{&depth, &max}, program)
+	// Program > Let > Call > Infix > Integer
+	if depth != 0 || max != 5 {
+		t.Fatalf("expected balanced walk of depth 5, got depth %d max %d", depth, max)
+	}
+}
+
+func TestInspectPrunesSubtrees(t *testing.T) {
+	program := parseProgram(t, "let f = x -> y + z\nlet g = w")
+	var idents []string
+	ast.Inspect(program, func(n ast.Node) bool {
+		if _, ok := n.(*ast.LambdaExpression); ok {
+			return false
+		}
+		if id, ok := n.(*ast.Identifier); ok {
+			idents = append(idents, id.Value)
+		}
+		return true
+	})
+	if strings.Join(idents, ",") != "f,g,w" {
+		t.Fatalf("expected f,g,w, got %v", idents)
+	}
+}
+
+func TestRewriteReplacesNodes(t *testing.T) {
+	program := parseProgram(t, "let x = [1 + 2, 3 * (4 + 5)]\nlog(x)\nlog(\"drop\")")
+	ast.Rewrite(program, func(n ast.Node) ast.Node {
+		switch n := n.(type) {
+		case *ast.InfixExpression:
+			l, lok := n.Left.(*ast.IntegerLiteral)
+			r, rok := n.Right.(*ast.IntegerLiteral)
+			if !lok || !rok {
+				return n
+			}
+			switch n.Operator {
+			case "+":
+				return &ast.IntegerLiteral{Token: n.Token, Value: l.Value + r.Value}
+			case "*":
+				return &ast.IntegerLiteral{Token: n.Token, Value: l.Value * r.Value}
+			}
+		case *ast.ExpressionStatement:
+			if call, ok := n.Expression.(*ast.CallExpression); ok && len(call.Arguments) == 1 {
+				if _, ok := call.Arguments[0].(*ast.StringLiteral); ok {
+					return nil
+				}
+			}
+		}
+		return n
+	})
+	if len(program.Statements) != 2 {
+		t.Fatalf("expected the string log to be dropped, got %d statements", len(program.Statements))
+	}
+	arr := program.Statements[0].(*ast.LetStatement).Value.(*ast.ArrayLiteral)
+	for i, want := range []int64{3, 27} {
+		lit, ok := arr.Elements[i].(*ast.IntegerLiteral)
+		if !ok || lit.Value != want {
+			t.Fatalf("element %d: expected %d, got %s", i, want, ast.Format(arr.Elements[i]))
+		}
+	}
+}
+
+func TestRewritePanicsOnMisplacedNode(t *testing.T) {
+	program := parseProgram(t, "let x = 1")
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(string), "cannot replace") {
+			t.Fatalf("expected a panic about the replacement, got %v", r)
+		}
+	}()
+	ast.Rewrite(program, func(n ast.Node) ast.Node {
+		if _, ok := n.(*ast.IntegerLiteral); ok {
+			return &ast.LetStatement{}
+		}
+		return n
+	})
+}
+
+func TestNodeSpan(t *testing.T) {
+	source := "let a = 1\nlet total = foo(a, \"bc\")\n  + bar"
+	program := parseProgram(t, source)
+	value := program.Statements[1].(*ast.LetStatement).Value
+	span := ast.NodeSpan(value)
+	if got := source[span.Start.Offset:span.End.Offset]; got != "foo(a, \"bc\")\n  + bar" {
+		t.Fatalf("unexpected span text %q", got)
+	}
+	if span.Start.Line != 2 || span.Start.Column != 13 || span.End.Line != 3 || span.End.Column != 8 {
+		t.Fatalf("unexpected span %+v", span)
+	}
+	start := ast.NodeStart(program)
+	if start.Line != 1 || start.Column != 1 || start.Offset != 0 {
+		t.Fatalf("unexpected program start %+v", start)
+	}
+	if ast.NodeEnd(program).Offset != len(source) {
+		t.Fatalf("expected program to end at %d, got %+v", len(source), ast.NodeEnd(program))
+	}
+	if span := ast.NodeSpan(&ast.Identifier{Value: "x"}); span.Start.IsValid() {
+		t.Fatalf("expected no span for a synthesized node, got %+v", span)
+	}
+}
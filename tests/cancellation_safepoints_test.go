@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"testing"
+
+	"karl/interpreter"
+)
+
+func TestRaceLoserInTightLoopStops(t *testing.T) {
+	input := `
+let state = { n: 0 }
+let busy = () -> { for true { state.n += 1 } }
+let slow = () -> { sleep(20); "slow" }
+let winner = wait !& { busy(), slow() }
+let seen = state.n
+sleep(50)
+let out = [winner, seen == state.n]
+out
+`
+	expected := &Array{Elements: []Value{&String{Value: "slow"}, &Boolean{Value: true}}}
+	for seed := int64(0); seed < 5; seed++ {
+		assertEquivalent(t, mustEvalDeterministic(t, input, seed), expected)
+	}
+}
+
+func TestCanceledTaskStopsAtFunctionCalls(t *testing.T) {
+	input := `
+let state = { calls: 0 }
+let tick = () -> { state.calls += 1 }
+let spin = () -> {
+    for true {
+        tick() ? { () }
+    }
+}
+let task = & spin()
+sleep(20)
+task.cancel()
+let kind = (wait task) ? { error.kind }
+let seen = state.calls
+sleep(50)
+let out = [kind, seen == state.calls]
+out
+`
+	expected := &Array{Elements: []Value{&String{Value: "canceled"}, &Boolean{Value: true}}}
+	for seed := int64(0); seed < 5; seed++ {
+		assertEquivalent(t, mustEvalDeterministic(t, input, seed), expected)
+	}
+}
+
+func TestCanceledTaskStopsBetweenQueryRows(t *testing.T) {
+	input := `
+let state = { rows: 0 }
+let rows = 1..100000
+let scan = () -> from r in rows where { state.rows += 1; true } select r
+let task = & scan()
+sleep(1)
+task.cancel()
+let kind = (wait task) ? { error.kind }
+let out = [kind, state.rows < 100000]
+out
+`
+	expected := &Array{Elements: []Value{&String{Value: "canceled"}, &Boolean{Value: true}}}
+	assertEquivalent(t, mustEvalDeterministic(t, input, 1), expected)
+}
+
+func TestFatalTaskFailureStopsBusyMainLoop(t *testing.T) {
+	input := `
+let boom = () -> { sleep(5); fail("boom") }
+& boom()
+for true with n = 0 { n += 1 }
+`
+	_, err := evalDeterministic(t, input, 2)
+	if _, ok := err.(*interpreter.UnhandledTaskError); !ok {
+		t.Fatalf("expected UnhandledTaskError, got %T (%v)", err, err)
+	}
+}
@@ -0,0 +1,257 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+)
+
+const drainHelper = `
+let drain = (c) -> {
+    for true with got = [] {
+        let [v, done] = c.recv()
+        if done { break got }
+        got.push(v)
+    } then got
+}
+`
+
+func TestChannelNonBlockingOpsAndQueries(t *testing.T) {
+	input := `
+let ch = buffered(2)
+let a = ch.trySend(1)
+let b = trySend(ch, 2)
+let full = ch.trySend(3)
+let sizes = [ch.len(), ch.cap()]
+let [v, ok] = ch.tryRecv()
+let [_, okAgain] = tryRecv(ch)
+let [_, okEmpty] = ch.tryRecv()
+let wasClosed = ch.closed
+ch.close()
+let out = [a, b, full, sizes, v, ok, okAgain, okEmpty, wasClosed, ch.closed, ch.len()]
+out
+`
+	for _, seed := range []int64{-1, 1} {
+		var val Value
+		if seed < 0 {
+			val = mustEval(t, input)
+		} else {
+			val = mustEvalDeterministic(t, input, seed)
+		}
+		if got := val.Inspect(); got != "[true, true, false, [2, 2], 1, true, true, false, false, true, 0]" {
+			t.Fatalf("seed %d: unexpected result %s", seed, got)
+		}
+	}
+}
+
+func TestRendezvousTryOpsNeedAPartner(t *testing.T) {
+	input := `
+let ch = channel()
+let lonely = ch.trySend(1)
+let [_, got] = ch.tryRecv()
+let receiver = & ch.recv()
+sleep(10)
+let handed = ch.trySend(42)
+let [v, done] = wait receiver
+let out = [lonely, got, ch.len(), ch.cap(), handed, v, done]
+out
+`
+	for seed := int64(0); seed < 5; seed++ {
+		val := mustEvalDeterministic(t, input, seed)
+		if got := val.Inspect(); got != "[false, false, 0, 0, true, 42, false]" {
+			t.Fatalf("seed %d: unexpected result %s", seed, got)
+		}
+	}
+}
+
+func TestClosedChannelDrainsBufferedValues(t *testing.T) {
+	input := drainHelper + `
+let ch = buffered(3)
+ch.send(1)
+ch.send(2)
+ch.done()
+drain(ch)
+`
+	assertEquivalent(t, mustEval(t, input), &Array{Elements: []Value{
+		&Integer{Value: 1}, &Integer{Value: 2},
+	}})
+
+	_, err := evalInput(t, "let ch = buffered(1)\nch.close()\nch.send(1)")
+	if err == nil || !strings.Contains(err.Error(), "send on closed channel") {
+		t.Fatalf("expected send on closed channel error, got %v", err)
+	}
+}
+
+func TestClosingWakesBlockedSenderWithError(t *testing.T) {
+	input := `
+let ch = channel()
+let sender = & ch.send(1)
+sleep(20)
+ch.done()
+wait sender
+`
+	_, err := evalInput(t, input)
+	if err == nil || !strings.Contains(err.Error(), "send on closed channel") {
+		t.Fatalf("expected send on closed channel error, got %v", err)
+	}
+	_, err = evalDeterministic(t, input, 3)
+	if err == nil || !strings.Contains(err.Error(), "send on closed channel") {
+		t.Fatalf("expected send on closed channel error in deterministic mode, got %v", err)
+	}
+}
+
+func TestRecvTimeoutOnVirtualClock(t *testing.T) {
+	input := `
+let ch = channel()
+let t0 = now()
+let timedOut = ch.recv({ timeout: 250 }) ? { error.kind + ": " + error.message }
+let waited = now() - t0
+let sendLate = () -> {
+    sleep(100)
+    ch.send("late")
+}
+let late = & sendLate()
+let [v, done] = ch.recv({ timeout: "1s" })
+let out = [timedOut, waited, v, done, now() - t0]
+out
+`
+	val := mustEvalDeterministic(t, input, 1)
+	if got := val.Inspect(); got != `["recv: recv timed out after 250ms", 250, "late", false, 350]` {
+		t.Fatalf("unexpected result %s", got)
+	}
+}
+
+func TestRecvTimeoutRealClock(t *testing.T) {
+	input := `
+let ch = buffered(1)
+let timedOut = ch.recv({ timeout: 20 }) ? { error.kind }
+ch.send(7)
+let [v, _] = recv(ch, { timeout: 1000 })
+let out = [timedOut, v]
+out
+`
+	if got := mustEval(t, input).Inspect(); got != `["recv", 7]` {
+		t.Fatalf("unexpected result %s", got)
+	}
+
+	_, err := evalInput(t, "channel().recv({ delay: 1 })")
+	if err == nil || !strings.Contains(err.Error(), "recv unknown option: delay") {
+		t.Fatalf("expected unknown option error, got %v", err)
+	}
+}
+
+func TestBroadcastDeliversEveryMessageToEverySubscriber(t *testing.T) {
+	input := drainHelper + `
+let b = broadcast()
+let subs = [b.subscribe(), b.subscribe(), b.subscribe()]
+let readers = subs.map(s -> & drain(s))
+for i < 4 with i = 1 {
+    b.send(i)
+    i++
+}
+b.close()
+let late = b.subscribe()
+let [_, lateDone] = late.recv()
+let got = readers.map(r -> wait r)
+let out = [got, b.closed, lateDone]
+out
+`
+	want := "[[[1, 2, 3], [1, 2, 3], [1, 2, 3]], true, true]"
+	if got := mustEval(t, input).Inspect(); got != want {
+		t.Fatalf("unexpected result %s", got)
+	}
+	for seed := int64(0); seed < 5; seed++ {
+		if got := mustEvalDeterministic(t, input, seed).Inspect(); got != want {
+			t.Fatalf("seed %d: unexpected result %s", seed, got)
+		}
+	}
+}
+
+func TestBroadcastDropsClosedSubscriptions(t *testing.T) {
+	input := drainHelper + `
+let b = broadcast(4)
+let keep = b.subscribe()
+let leave = b.subscribe()
+b.send("a")
+leave.done()
+b.send("b")
+let count = b.subscribers
+b.done()
+let out = [drain(keep), count]
+out
+`
+	if got := mustEval(t, input).Inspect(); got != `[["a", "b"], 1]` {
+		t.Fatalf("unexpected result %s", got)
+	}
+}
+
+func TestMergeForwardsAllInputsAndCloses(t *testing.T) {
+	input := drainHelper + `
+let a = buffered(3)
+let b = channel()
+let c = buffered(1)
+let produce = () -> {
+    b.send(10)
+    b.send(20)
+    b.done()
+}
+let producer = & produce()
+a.send(1)
+a.send(2)
+a.done()
+c.done()
+let got = drain(merge(a, b, c))
+wait producer
+let empty = drain(merge([]))
+let out = [got.sort((x, y) -> x - y), empty]
+out
+`
+	want := "[[1, 2, 10, 20], []]"
+	if got := mustEval(t, input).Inspect(); got != want {
+		t.Fatalf("unexpected result %s", got)
+	}
+	for seed := int64(0); seed < 5; seed++ {
+		if got := mustEvalDeterministic(t, input, seed).Inspect(); got != want {
+			t.Fatalf("seed %d: unexpected result %s", seed, got)
+		}
+	}
+}
+
+func TestFanOutSendsEachValueToOneOutput(t *testing.T) {
+	input := drainHelper + `
+let src = buffered(10)
+for i < 10 with i = 0 {
+    src.send(i)
+    i++
+}
+src.done()
+let outs = fanOut(src, 3)
+let parts = outs.map(o -> & drain(o)).map(r -> wait r)
+let all = parts.reduce((acc, p) -> acc + p, [])
+let out = [parts.length, all.sort((x, y) -> x - y)]
+out
+`
+	want := "[3, [0, 1, 2, 3, 4, 5, 6, 7, 8, 9]]"
+	if got := mustEval(t, input).Inspect(); got != want {
+		t.Fatalf("unexpected result %s", got)
+	}
+	for seed := int64(0); seed < 5; seed++ {
+		if got := mustEvalDeterministic(t, input, seed).Inspect(); got != want {
+			t.Fatalf("seed %d: unexpected result %s", seed, got)
+		}
+	}
+}
+
+func TestForwardersStopWithTheirParentTask(t *testing.T) {
+	input := `
+let src = channel()
+let own = () -> {
+    let merged = merge(src)
+    merged.recv()
+}
+let owner = & own()
+sleep(10)
+owner.cancel()
+(wait owner) ? error.kind
+`
+	assertString(t, mustEvalDeterministic(t, input, 2), "canceled")
+}
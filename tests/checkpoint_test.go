@@ -0,0 +1,186 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"karl/interpreter"
+)
+
+func TestCheckpointRoundTripsValuesLosslessly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	write := fmt.Sprintf(`
+let state = checkpoint(%q)
+let m = map()
+m.set("a", 1)
+m.set(2, 'x')
+m.set(true, [1.5])
+let s = set()
+s.add('c')
+s.add("c")
+s.add(3)
+state.put("value", {
+    int: 9007199254740993,
+    float: 0.1,
+    char: 'k',
+    str: "k",
+    flag: false,
+    nothing: null,
+    unit: (),
+    list: [1, [2, 3]],
+    m: m,
+    s: s,
+})
+`, path)
+	mustEval(t, write)
+
+	read := fmt.Sprintf(`
+let v = checkpoint(%q).get("value")
+let out = [
+    v.int,
+    v.float,
+    v.char == 'k',
+    v.str == "k",
+    v.flag,
+    v.nothing,
+    v.unit,
+    v.list,
+    v.m.get(2) == 'x',
+    v.m.get(true),
+    v.m.get("a"),
+    v.s.has('c'),
+    v.s.has("c"),
+    v.s.size,
+]
+out
+`, path)
+	val := mustEval(t, read)
+	assertEquivalent(t, val, &Array{Elements: []Value{
+		&Integer{Value: 9007199254740993},
+		&Float{Value: 0.1},
+		&Boolean{Value: true},
+		&Boolean{Value: true},
+		&Boolean{Value: false},
+		NullValue,
+		interpreter.UnitValue,
+		&Array{Elements: []Value{&Integer{Value: 1}, &Array{Elements: []Value{&Integer{Value: 2}, &Integer{Value: 3}}}}},
+		&Boolean{Value: true},
+		&Array{Elements: []Value{&Float{Value: 1.5}}},
+		&Integer{Value: 1},
+		&Boolean{Value: true},
+		&Boolean{Value: true},
+		&Integer{Value: 3},
+	}})
+}
+
+func TestCheckpointGetReturnsCopies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	input := fmt.Sprintf(`
+let state = checkpoint(%q)
+let items = [1]
+state.put("items", items)
+items.push(2)
+let first = state.get("items")
+first.push(3)
+let out = [state.get("items"), state.get("missing"), state.get("missing", 7), state.has("items")]
+out
+`, path)
+	assertEquivalent(t, mustEval(t, input), &Array{Elements: []Value{
+		&Array{Elements: []Value{&Integer{Value: 1}}},
+		NullValue,
+		&Integer{Value: 7},
+		&Boolean{Value: true},
+	}})
+}
+
+func TestCheckpointStepSkipsRecordedStepsOnResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.json")
+	program := func(crash bool) string {
+		return fmt.Sprintf(`
+let state = checkpoint(%q)
+let ran = []
+let a = state.step("extract", () -> { ran.push("extract"); [1, 2, 3] })
+let b = state.step("transform", () -> {
+    ran.push("transform")
+    if %t { fail("crash") }
+    a.map((n) -> n * 10)
+})
+let out = [a, b, ran]
+out
+`, path, crash)
+	}
+
+	_, err := evalInput(t, program(true))
+	if err == nil || !strings.Contains(err.Error(), "crash") {
+		t.Fatalf("expected crash, got %v", err)
+	}
+
+	val := mustEval(t, program(false))
+	assertEquivalent(t, val, &Array{Elements: []Value{
+		&Array{Elements: []Value{&Integer{Value: 1}, &Integer{Value: 2}, &Integer{Value: 3}}},
+		&Array{Elements: []Value{&Integer{Value: 10}, &Integer{Value: 20}, &Integer{Value: 30}}},
+		&Array{Elements: []Value{&String{Value: "transform"}}},
+	}})
+
+	val = mustEval(t, program(false))
+	assertEquivalent(t, val.(*Array).Elements[2], &Array{Elements: []Value{}})
+}
+
+func TestCheckpointWritesAtomically(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+	input := fmt.Sprintf(`
+let state = checkpoint(%q)
+for i < 20 with i = 0 {
+    state.put("k" + str(i), i)
+    i++
+}
+state.delete("k0")
+`, path)
+	mustEval(t, input)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "state.json" {
+		names := []string{}
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Fatalf("expected only the checkpoint file, got %v", names)
+	}
+	val := mustEval(t, fmt.Sprintf(`let s = checkpoint(%q); [s.has("k0"), s.get("k19")]`, path))
+	assertEquivalent(t, val, &Array{Elements: []Value{&Boolean{Value: false}, &Integer{Value: 19}}})
+}
+
+func TestCheckpointRejectsUnserializableValues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	_, err := evalInput(t, fmt.Sprintf(`checkpoint(%q).put("fn", () -> 1)`, path))
+	if err == nil || !strings.Contains(err.Error(), "checkpoint cannot store FUNCTION") {
+		t.Fatalf("expected unserializable error, got %v", err)
+	}
+	_, err = evalInput(t, fmt.Sprintf(`
+let loop = []
+loop.push(loop)
+checkpoint(%q).put("loop", loop)
+`, path))
+	if err == nil || !strings.Contains(err.Error(), "cyclic") {
+		t.Fatalf("expected cyclic value error, got %v", err)
+	}
+}
+
+func TestCheckpointCorruptFileIsRecoverable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	val := mustEval(t, fmt.Sprintf(`checkpoint(%q) ? { error.kind }`, path))
+	assertString(t, val, "checkpoint")
+	_, err := evalInput(t, fmt.Sprintf(`checkpoint(%q)`, path))
+	if _, ok := err.(*interpreter.RecoverableError); !ok {
+		t.Fatalf("expected recoverable error, got %T", err)
+	}
+}
@@ -0,0 +1,125 @@
+package tests
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestWithContextScopesValues(t *testing.T) {
+	input := `
+let before = ctx("requestId")
+let inner = withContext({ requestId: "r-1", user: "ann" }, () -> {
+    let nested = withContext({ user: "bob", tenant: null }, () -> [ctx("requestId"), ctx("user")])
+    let all = ctx()
+    let out = [nested, ctx("user"), all.requestId, all.user]
+    out
+})
+let out = [before, inner, ctx("requestId")]
+out
+`
+	want := `[null, [["r-1", "bob"], "ann", "r-1", "ann"], null]`
+	if got := mustEval(t, input).Inspect(); got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
+
+func TestSpawnedTasksInheritContext(t *testing.T) {
+	input := `
+let lookup = () -> ctx("requestId")
+let fanOutLookups = () -> {
+    let child = & lookup()
+    let grandchild = & (() -> wait (& lookup()))()
+    let out = [wait child, wait grandchild]
+    out
+}
+let handle = withContext({ requestId: "abc" }, () -> & fanOutLookups())
+let out = [wait handle, handle.context, ctx("requestId")]
+out
+`
+	want := `[["abc", "abc"], {requestId: "abc"}, null]`
+	if got := mustEval(t, input).Inspect(); got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+	if got := mustEvalDeterministic(t, input, 4).Inspect(); got != want {
+		t.Fatalf("deterministic: expected %s, got %s", want, got)
+	}
+}
+
+func TestContextRejectsBadArguments(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{`withContext("id", () -> 1)`, "withContext expects values object"},
+		{`withContext({ a: 1 }, 2)`, "withContext expects function"},
+		{`ctx(1)`, "ctx expects string key"},
+		{`logContext(["a", 1])`, "logContext expects string keys"},
+	}
+	for _, tc := range cases {
+		_, err := evalInput(t, tc.input)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%s: expected %q error, got %v", tc.input, tc.want, err)
+		}
+	}
+}
+
+func captureStdout(t *testing.T, run func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	run()
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("read stdout: %v", err)
+	}
+	return string(out)
+}
+
+func TestLogAppendsSelectedContextKeys(t *testing.T) {
+	input := `
+logContext("requestId", "user")
+log("outside")
+withContext({ requestId: "r-7", secret: "x" }, () -> {
+    log("handling", 42)
+    wait (& log("in task"))
+})
+logContext()
+withContext({ requestId: "r-8" }, () -> log("plain"))
+`
+	out := captureStdout(t, func() { mustEval(t, input) })
+	want := "outside\nhandling 42 requestId=r-7\nin task requestId=r-7\nplain\n"
+	if out != want {
+		t.Fatalf("expected log output %q, got %q", want, out)
+	}
+}
+
+func TestHTTPForwardsContextHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s|%s|%s", r.Header.Get("X-Request-Id"), r.Header.Get("X-User"), r.Header.Get("X-Tenant"))
+	}))
+	defer server.Close()
+
+	input := fmt.Sprintf(`
+let forward = { requestId: "X-Request-Id", user: "X-User", tenant: "X-Tenant" }
+let call = (headers) -> http({ url: %q, contextHeaders: forward, headers: headers }).body
+withContext({ requestId: "r-9", user: "ann" }, () -> {
+    let out = [call({}), call(set(map(), "X-User", "override")), http({ url: %q }).body]
+    out
+})
+`, server.URL, server.URL)
+	want := `["r-9|ann|", "r-9|override|", "||"]`
+	if got := mustEval(t, input).Inspect(); got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
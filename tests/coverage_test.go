@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"karl/interpreter"
+	"karl/lexer"
+	"karl/parser"
+)
+
+func runWithCoverage(t *testing.T, dir, input string) *interpreter.CoverageReport {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		t.Fatalf("parse errors: %v", errs)
+	}
+	eval := interpreter.NewEvaluatorWithSourceFilenameAndRoot(input, filepath.Join(dir, "main.k"), dir)
+	eval.EnableCoverage()
+	if _, _, err := eval.Eval(program, interpreter.NewBaseEnvironment()); err != nil {
+		t.Fatalf("eval error: %v", err)
+	}
+	return eval.Coverage()
+}
+
+func TestCoverageCountsStatementsArmsImportsAndTasks(t *testing.T) {
+	dir := t.TempDir()
+	module := `let classify = (n) -> match n {
+    case 0 -> "zero"
+    case 1 -> "one"
+    case _ -> "many"
+}
+let unused = () -> {
+    log("never")
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "lib.k"), []byte(module), 0o644); err != nil {
+		t.Fatalf("write module: %v", err)
+	}
+	input := `let makeLib = import "./lib.k"
+let lib = makeLib()
+let work = (n) -> {
+    let label = lib.classify(n)
+    label
+}
+let t = & work(1)
+let a = wait t
+let b = lib.classify(5)
+let skipped = (x) -> x + 1
+`
+	report := runWithCoverage(t, dir, input)
+	if len(report.Files) != 2 {
+		t.Fatalf("expected main and module, got %+v", report.Files)
+	}
+	lib, main := report.Files[0], report.Files[1]
+	if !strings.HasSuffix(lib.Name, "lib.k") || !strings.HasSuffix(main.Name, "main.k") {
+		t.Fatalf("unexpected files %q %q", lib.Name, main.Name)
+	}
+
+	// The task ran lines 4-5 of main; the lambda body on line 10 never ran.
+	for line, want := range map[int]int64{1: 1, 4: 1, 5: 1, 7: 1, 10: 0} {
+		if got := main.Lines[line].Count; got != want {
+			t.Fatalf("main.k line %d: expected count %d, got %d (%+v)", line, want, got, main.Lines)
+		}
+	}
+	if covered, total := main.StatementCounts(); covered != 9 || total != 10 {
+		t.Fatalf("main.k: expected 9/10 statements, got %d/%d", covered, total)
+	}
+
+	if len(lib.Matches) != 1 || len(lib.Matches[0].Arms) != 3 {
+		t.Fatalf("expected one match with three arms, got %+v", lib.Matches)
+	}
+	arms := lib.Matches[0].Arms
+	if arms[0].Count != 0 || arms[1].Count != 1 || arms[2].Count != 1 || arms[1].Line != 3 {
+		t.Fatalf("unexpected arm counts %+v", arms)
+	}
+	if got := lib.Lines[7].Count; got != 0 {
+		t.Fatalf("expected unused body to be uncovered, got %d", got)
+	}
+
+	var text, lcov, html strings.Builder
+	if err := report.WriteText(&text); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+	if err := report.WriteLCOV(&lcov); err != nil {
+		t.Fatalf("WriteLCOV: %v", err)
+	}
+	if err := report.WriteHTML(&html); err != nil {
+		t.Fatalf("WriteHTML: %v", err)
+	}
+	if !strings.Contains(text.String(), "9/10") || !strings.Contains(text.String(), "2/3") {
+		t.Fatalf("unexpected summary:\n%s", text.String())
+	}
+	for _, want := range []string{"SF:" + lib.Name + "\n", "BRDA:1,0,0,0\nBRDA:1,0,1,1\nBRDA:1,0,2,1\nBRF:3\nBRH:2\n", "DA:10,0\n", "end_of_record\n"} {
+		if !strings.Contains(lcov.String(), want) {
+			t.Fatalf("expected LCOV to contain %q, got:\n%s", want, lcov.String())
+		}
+	}
+	for _, want := range []string{`<tr class="uncovered" title="0/0 statements, 0/1 match arms"><td class="num">2</td>`, `<td class="code">let skipped = (x) -&gt; x + 1</td>`} {
+		if !strings.Contains(html.String(), want) {
+			t.Fatalf("expected HTML to contain %q, got:\n%s", want, html.String())
+		}
+	}
+}
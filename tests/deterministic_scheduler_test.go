@@ -0,0 +1,182 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"karl/interpreter"
+)
+
+func evalDeterministic(t *testing.T, input string, seed int64) (Value, error) {
+	t.Helper()
+	return evalWithConfiguredEvaluator(t, input, func(e *interpreter.Evaluator) {
+		e.SetDeterministic(seed)
+	})
+}
+
+const interleavingProgram = `
+let order = []
+let worker = (name) -> {
+    for i < 3 with i = 0 {
+        order.push(name + str(i))
+        sleep(0)
+        i++
+    } then name
+}
+wait & { worker("a"), worker("b"), worker("c") }
+order
+`
+
+func TestDeterministicSameSeedReplaysInterleaving(t *testing.T) {
+	for seed := int64(0); seed < 5; seed++ {
+		first, err := evalDeterministic(t, interleavingProgram, seed)
+		if err != nil {
+			t.Fatalf("seed %d: unexpected error: %v", seed, err)
+		}
+		for run := 0; run < 3; run++ {
+			again, err := evalDeterministic(t, interleavingProgram, seed)
+			if err != nil {
+				t.Fatalf("seed %d: unexpected error: %v", seed, err)
+			}
+			if again.Inspect() != first.Inspect() {
+				t.Fatalf("seed %d: expected replay %s, got %s", seed, first.Inspect(), again.Inspect())
+			}
+		}
+	}
+}
+
+func TestDeterministicSeedsExploreDifferentInterleavings(t *testing.T) {
+	seen := map[string]bool{}
+	for seed := int64(0); seed < 20; seed++ {
+		val, err := evalDeterministic(t, interleavingProgram, seed)
+		if err != nil {
+			t.Fatalf("seed %d: unexpected error: %v", seed, err)
+		}
+		seen[strings.Join(stringsFromArray(t, val), ",")] = true
+	}
+	if len(seen) < 2 {
+		t.Fatalf("expected different seeds to produce different interleavings, got %v", seen)
+	}
+}
+
+func TestDeterministicSleepAdvancesVirtualClock(t *testing.T) {
+	input := `
+let t0 = now()
+sleep(60000)
+let slow = () -> { sleep(5000); "slow" }
+let fast = () -> { sleep(10); "fast" }
+let winner = wait !& { slow(), fast() }
+let out = [now() - t0, winner]
+out
+`
+	start := time.Now()
+	val, err := evalDeterministic(t, input, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected virtual sleeps to return immediately, took %s", elapsed)
+	}
+	assertEquivalent(t, val, &Array{Elements: []Value{
+		&Integer{Value: 60010},
+		&String{Value: "fast"},
+	}})
+}
+
+func TestDeterministicRendezvousDeliversInOrder(t *testing.T) {
+	input := `
+let ch = channel()
+let producer = () -> { for i < 5 with i = 0 { ch.send(i); i++ } then ch.done() }
+& producer()
+for true with got = [] {
+    let [v, done] = ch.recv()
+    if done { break got }
+    got.push(v)
+} then got
+`
+	for seed := int64(0); seed < 10; seed++ {
+		val := mustEvalDeterministic(t, input, seed)
+		assertEquivalent(t, val, &Array{Elements: []Value{
+			&Integer{Value: 0}, &Integer{Value: 1}, &Integer{Value: 2}, &Integer{Value: 3}, &Integer{Value: 4},
+		}})
+	}
+}
+
+func TestDeterministicCancellationOfBlockedTasks(t *testing.T) {
+	input := `
+let state = { hits: 0 }
+let long = () -> { sleep(200); state.hits += 1 }
+let t = & long()
+sleep(20)
+t.cancel()
+let out = (wait t) ? { error.kind }
+
+let ch = channel()
+let sender = & (() -> { ch.send("hi"); "sent" })()
+sleep(20)
+sender.cancel()
+let sendOut = (wait sender) ? { error.kind }
+
+sleep(500)
+let result = [out, sendOut, state.hits]
+result
+`
+	for seed := int64(0); seed < 10; seed++ {
+		val := mustEvalDeterministic(t, input, seed)
+		assertEquivalent(t, val, &Array{Elements: []Value{
+			&String{Value: "canceled"},
+			&String{Value: "canceled"},
+			&Integer{Value: 0},
+		}})
+	}
+}
+
+func TestDeterministicDeadlockIsReported(t *testing.T) {
+	input := `
+let ch = channel()
+let t = & (() -> ch.recv())()
+wait t
+`
+	_, err := evalDeterministic(t, input, 3)
+	if err == nil {
+		t.Fatalf("expected deadlock error")
+	}
+	if !strings.Contains(err.Error(), "deadlock") {
+		t.Fatalf("expected deadlock error, got: %v", err)
+	}
+}
+
+func TestDeterministicRandomNumbersFollowSeed(t *testing.T) {
+	input := `[rand(), randInt(1, 100), randFloat(0, 1)]`
+	first := mustEvalDeterministic(t, input, 42)
+	second := mustEvalDeterministic(t, input, 42)
+	if first.Inspect() != second.Inspect() {
+		t.Fatalf("expected same random values for same seed, got %s and %s", first.Inspect(), second.Inspect())
+	}
+}
+
+func TestDeterministicFailFastUnobservedTask(t *testing.T) {
+	input := `
+let boom = () -> { sleep(5); fail("boom") }
+& boom()
+sleep(100)
+"unreachable"
+`
+	_, err := evalDeterministic(t, input, 1)
+	if err == nil {
+		t.Fatalf("expected fail-fast error")
+	}
+	if _, ok := err.(*interpreter.UnhandledTaskError); !ok {
+		t.Fatalf("expected UnhandledTaskError, got %T (%v)", err, err)
+	}
+}
+
+func mustEvalDeterministic(t *testing.T, input string, seed int64) Value {
+	t.Helper()
+	val, err := evalDeterministic(t, input, seed)
+	if err != nil {
+		t.Fatalf("seed %d: unexpected error: %v", seed, err)
+	}
+	return val
+}
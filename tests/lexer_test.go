@@ -214,3 +214,102 @@ let c2 = '\u0041'
 		}
 	}
 }
+
+func TestNumberLiterals(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedType token.TokenType
+		literal      string
+	}{
+		{"0xFF", token.INT, "0xFF"},
+		{"0Xdead_beef", token.INT, "0Xdead_beef"},
+		{"0b1010", token.INT, "0b1010"},
+		{"0b12", token.INT, "0b12"},
+		{"1_000_000", token.INT, "1_000_000"},
+		{"1.5", token.FLOAT, "1.5"},
+		{"1_000.000_1", token.FLOAT, "1_000.000_1"},
+		{"1e9", token.FLOAT, "1e9"},
+		{"2.5E-3", token.FLOAT, "2.5E-3"},
+		{"6.02e+23", token.FLOAT, "6.02e+23"},
+		{"1..5", token.INT, "1"},
+		{"3e", token.INT, "3"},
+		{"4e+x", token.INT, "4"},
+	}
+	for _, tt := range tests {
+		tok := lexer.New(tt.input).NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.literal {
+			t.Errorf("%q: expected %s %q, got %s %q", tt.input, tt.expectedType, tt.literal, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestStringLiterals(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		literal string
+	}{
+		{"raw", `r"C:\path\n"`, `C:\path\n`},
+		{"raw_empty", `r""`, ""},
+		{"triple_inline", `"""say "hi" \t"""`, "say \"hi\" \t"},
+		{"triple_dedent", "\"\"\"\n    SELECT *\n      FROM t\n    \"\"\"", "SELECT *\n  FROM t"},
+		{"triple_closing_sets_indent", "\"\"\"\n    a\n      b\n  \"\"\"", "  a\n    b"},
+		{"triple_blank_lines", "\"\"\"\n  a\n\n   \n  b\n  \"\"\"", "a\n\n\nb"},
+		{"triple_crlf", "\"\"\"\r\n  a\r\n  b\r\n  \"\"\"", "a\nb"},
+		{"triple_escapes_after_dedent", "\"\"\"\n  a\\n  b\n  \"\"\"", "a\n  b"},
+		{"triple_escaped_quotes", `"""a\"""b"""`, `a"""b`},
+		{"raw_triple", "r\"\"\"\n  {\"k\": \"\\n\"}\n  \"\"\"", `{"k": "\n"}`},
+		{"empty_then_quote", `""`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			tok := l.NextToken()
+			if tok.Type != token.STRING || tok.Literal != tt.literal {
+				t.Fatalf("expected STRING %q, got %s %q", tt.literal, tok.Type, tok.Literal)
+			}
+			if tok.Source != tt.input {
+				t.Fatalf("expected source %q, got %q", tt.input, tok.Source)
+			}
+			if next := l.NextToken(); next.Type != token.EOF {
+				t.Fatalf("expected EOF after the string, got %s %q", next.Type, next.Literal)
+			}
+		})
+	}
+
+	l := lexer.New("r \"x\" r2 \"y\"")
+	for _, want := range []token.TokenType{token.IDENT, token.STRING, token.IDENT, token.STRING} {
+		if tok := l.NextToken(); tok.Type != want {
+			t.Fatalf("expected %s, got %s %q", want, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestUnterminatedStrings(t *testing.T) {
+	tests := []struct {
+		input  string
+		opener string
+		column int
+	}{
+		{"let s = \"abc", `"`, 9},
+		{"let s = r\"abc\nlog(s)", `r"`, 9},
+		{"let s = \"\"\"\n  abc\nlog(s)\nlog(\"done\")\n", `"""`, 9},
+		{"let s = r\"\"\"\n  abc\"\"\n", `r"""`, 9},
+		{"x = \"\"\"", `"""`, 5},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		var tok token.Token
+		for tok = l.NextToken(); tok.Type != token.ILLEGAL; tok = l.NextToken() {
+			if tok.Type == token.EOF {
+				t.Fatalf("%q: expected an ILLEGAL token", tt.input)
+			}
+		}
+		if tok.Literal != tt.opener || tok.Line != 1 || tok.Column != tt.column {
+			t.Fatalf("%q: expected ILLEGAL %q at 1:%d, got %q at %d:%d", tt.input, tt.opener, tt.column, tok.Literal, tok.Line, tok.Column)
+		}
+		if next := l.NextToken(); next.Type != token.EOF {
+			t.Fatalf("%q: expected EOF after the string, got %s %q", tt.input, next.Type, next.Literal)
+		}
+	}
+}
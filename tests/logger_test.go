@@ -0,0 +1,151 @@
+package tests
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"karl/interpreter"
+)
+
+func evalWithLogOutput(t *testing.T, input string, configure func(*interpreter.Evaluator)) (Value, string) {
+	t.Helper()
+	var out strings.Builder
+	val, err := evalWithConfiguredEvaluator(t, input, func(e *interpreter.Evaluator) {
+		e.SetDeterministic(1)
+		e.SetLogOutput(&out)
+		if configure != nil {
+			configure(e)
+		}
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return val, out.String()
+}
+
+func TestLoggerTextEncoderAndThreshold(t *testing.T) {
+	input := `
+let log = logger()
+log.debug("not shown")
+log.info("service started", { port: 8080, name: "api server" })
+sleep(1500)
+log.warn("slow", { ms: 1.5, tags: ["a", "b"] })
+log.error("boom")
+let out = [log.level, log.format, log.enabled("debug"), log.enabled("error")]
+out
+`
+	val, out := evalWithLogOutput(t, input, nil)
+	want := `time=1970-01-01T00:00:00.000Z level=info msg="service started" name="api server" port=8080
+time=1970-01-01T00:00:01.500Z level=warn msg=slow ms=1.5 tags="[\"a\", \"b\"]"
+time=1970-01-01T00:00:01.500Z level=error msg=boom
+`
+	if out != want {
+		t.Fatalf("expected log output:\n%s\ngot:\n%s", want, out)
+	}
+	if got := val.Inspect(); got != `["info", "text", false, true]` {
+		t.Fatalf("unexpected logger properties %s", got)
+	}
+}
+
+func TestLoggerJSONLinesWithFieldsAndContext(t *testing.T) {
+	input := `
+logContext("requestId")
+let base = logger({ level: "debug", fields: { service: "billing" } })
+let reqLog = base.child({ component: "http" })
+withContext({ requestId: "r-1", user: "ann" }, () -> {
+    reqLog.debug("request", { path: "/pay", ok: true, service: "override", fn: () -> 1 })
+})
+`
+	_, out := evalWithLogOutput(t, input, func(e *interpreter.Evaluator) {
+		if err := e.SetLogFormat("json"); err != nil {
+			t.Fatalf("SetLogFormat: %v", err)
+		}
+	})
+	want := `{"time":"1970-01-01T00:00:00.000Z","level":"debug","msg":"request","component":"http","fn":"<function>","ok":true,"path":"/pay","requestId":"r-1","service":"override"}` + "\n"
+	if out != want {
+		t.Fatalf("expected %s, got %s", want, out)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &decoded); err != nil {
+		t.Fatalf("expected valid JSON line: %v", err)
+	}
+}
+
+func TestLoggerEmbeddingLevel(t *testing.T) {
+	_, out := evalWithLogOutput(t, `logger().info("quiet")
+logger().error("loud")`, func(e *interpreter.Evaluator) {
+		if err := e.SetLogLevel("error"); err != nil {
+			t.Fatalf("SetLogLevel: %v", err)
+		}
+	})
+	if strings.Contains(out, "quiet") || !strings.Contains(out, "msg=loud") {
+		t.Fatalf("expected only the error line, got %q", out)
+	}
+	e := interpreter.NewEvaluator()
+	if err := e.SetLogLevel("verbose"); err == nil {
+		t.Fatalf("expected invalid log level error")
+	}
+	if err := e.SetLogFormat("xml"); err == nil {
+		t.Fatalf("expected invalid log format error")
+	}
+}
+
+func TestLoggerChannelSink(t *testing.T) {
+	input := `
+let records = buffered(4)
+let log = logger({ sink: records, format: "json" })
+log.info("hello", { n: 1 })
+records.done()
+let [rec, _] = records.recv()
+let out = [rec.level, rec.msg, rec.fields, rec.line]
+out
+`
+	val := mustEvalDeterministic(t, input, 1)
+	want := `["info", "hello", {n: 1}, "{\"time\":\"1970-01-01T00:00:00.000Z\",\"level\":\"info\",\"msg\":\"hello\",\"n\":1}"]`
+	if got := val.Inspect(); got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
+
+func TestLoggerFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	input := `
+let log = logger({ sink: { file: "` + path + `" } })
+log.info("one")
+log.info("two")
+`
+	mustEvalDeterministic(t, input, 1)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if got := strings.Count(string(data), "\n"); got != 2 || !strings.Contains(string(data), "msg=two") {
+		t.Fatalf("expected two appended lines, got %q", data)
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing", "app.log")
+	val := mustEval(t, `logger({ sink: { file: "`+missing+`" } }).info("x") ? error.kind`)
+	assertString(t, val, "logger")
+}
+
+func TestLoggerRejectsBadOptions(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{`logger({ level: "loud" })`, "logger level must be"},
+		{`logger({ format: "xml" })`, "logger format must be"},
+		{`logger({ sink: "syslog" })`, "logger sink must be"},
+		{`logger({ colour: true })`, "logger unknown option: colour"},
+		{`logger().info("x", 1)`, "info fields must be an object"},
+	}
+	for _, tc := range cases {
+		_, err := evalInput(t, tc.input)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%s: expected %q error, got %v", tc.input, tc.want, err)
+		}
+	}
+}
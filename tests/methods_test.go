@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEvalMethodsBindSelf(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected int64
+	}{
+		{"let c = { n: 1, get: self -> self.n }; c.get()", 1},
+		{"let c = { n: 0, bump: (self, by) -> { self.n += by; self } }; c.bump(2).bump(3).n", 5},
+		{"let c = { n: 7, get: self -> self.n }; let g = c.get; c.n = 8; g()", 8},
+		{"let a = { n: 1, get: self -> self.n }; let b = { n: 2, get: a.get }; b.get()", 1},
+		{"let c = { n: 4, add: (self, x) -> self.n + x }; 3 |> c.add", 7},
+		{"let c = { n: 4, add: (self, x) -> self.n + x }; [1, 2].map(c.add).sum()", 11},
+	}
+	for _, tc := range cases {
+		assertInteger(t, mustEval(t, tc.input), tc.expected)
+	}
+}
+
+func TestEvalBoundMethodEquality(t *testing.T) {
+	input := `let P = { m: self -> 1, f: x -> x }
+let a = new(P)
+let b = new(P)
+let g = a.m
+[a.m == a.m, g == a.m, a.m eqv a.m, a.m != b.m, a.m == P.m, a.f == b.f]`
+	arr, ok := mustEval(t, input).(*Array)
+	if !ok || arr.Inspect() != "[true, true, true, true, false, true]" {
+		t.Fatalf("expected [true, true, true, true, false, true], got %v", arr)
+	}
+
+	// A handler registered by reading obj.handle can be found again the same way.
+	input = `let obj = { hits: 0, handle: self -> { self.hits += 1 } }
+let handlers = [obj.handle, x -> x]
+let rest = handlers.filter(h -> h != obj.handle)
+rest.length`
+	assertInteger(t, mustEval(t, input), 1)
+}
+
+func TestEvalPlainObjectLambdasKeepArity(t *testing.T) {
+	assertInteger(t, mustEval(t, "let o = { f: x -> x + 1 }; o.f(1)"), 2)
+	assertInteger(t, mustEval(t, "let o = { f: () -> 3 }; o.f()"), 3)
+	// Indexing reads the raw function, receiver included in the arguments.
+	assertInteger(t, mustEval(t, `let o = { n: 5, get: self -> self.n }; o["get"](o)`), 5)
+}
+
+func TestEvalNewPrototype(t *testing.T) {
+	input := `let Shape = {
+    area: self -> self.w * self.h,
+    describe: self -> self.kind + " " + str(self.area()),
+    kind: "shape",
+}
+let Square = new(Shape, { kind: "square", area: self -> self.w * self.w })
+let rect = new(Shape, { w: 2, h: 3 })
+let sq = new(Square, { w: 4 })
+rect.describe() + ", " + sq.describe()`
+	assertString(t, mustEval(t, input), "shape 6, square 16")
+
+	// Prototypes are shared: later additions are visible, writes stay own.
+	input = `let Proto = { size: 1 }
+let a = new(Proto)
+let b = new(Proto)
+Proto.double = self -> self.size * 2
+a.size = 10
+a.double() + b.double()`
+	assertInteger(t, mustEval(t, input), 22)
+
+	// Inherited fields can be updated in place; the write shadows the default.
+	input = `let Counter = {
+    n: 0,
+    bump: (self, by) -> { self.n += by; self },
+    tick: self -> { self.n++; self },
+}
+let a = new(Counter).bump(2).tick()
+let b = new(Counter).tick()
+[a.n, b.n, Counter.n]`
+	arr, ok := mustEval(t, input).(*Array)
+	if !ok || arr.Inspect() != "[3, 1, 0]" {
+		t.Fatalf("expected [3, 1, 0], got %v", arr)
+	}
+
+	// Only own properties count as data.
+	input = `let p = new({ hidden: 1 }, { shown: 2 }); [len(p), len({ ...p }), encodeJson(p) == "{\"shown\":2}"]`
+	arr, ok = mustEval(t, input).(*Array)
+	if !ok || arr.Inspect() != "[1, 1, true]" {
+		t.Fatalf("expected [1, 1, true], got %v", arr)
+	}
+}
+
+func TestEvalMethodErrors(t *testing.T) {
+	cases := []struct {
+		input string
+		msg   string
+	}{
+		{"let c = { get: self -> 1 }; c.get(2)", "wrong number of arguments"},
+		{"let p = new({}, {}); p.missing", "missing property: missing"},
+		{"new(1)", "new expects prototype object"},
+		{"new({}, 2)", "new expects fields object"},
+		{`let o = { get: self -> 1 }; o["get"]()`, "wrong number of arguments"},
+	}
+	for _, tc := range cases {
+		_, err := evalInput(t, tc.input)
+		if err == nil || !strings.Contains(err.Error(), tc.msg) {
+			t.Fatalf("%q: expected error containing %q, got %v", tc.input, tc.msg, err)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"karl/interpreter"
+)
+
+func TestMetricsValuesAndExposition(t *testing.T) {
+	input := `
+let hits = counter("app_hits_total", { route: "/a" })
+hits.inc()
+hits.inc(2)
+counter("app_hits_total", { route: "/a" }).inc()
+counter("app_hits_total", { route: "/b" }).inc()
+let queue = gauge("app_queue_depth")
+queue.set(10)
+queue.dec(3)
+queue.inc(0.5)
+let latency = histogram("app_latency_seconds", [0.1, 0.5, 1])
+latency.observe(0.05)
+latency.observe(0.5)
+latency.observe(3)
+let out = [hits.value, queue.value, latency.count, latency.sum, hits.labels, metricsText()]
+out
+`
+	val := mustEval(t, input)
+	arr, ok := val.(*Array)
+	if !ok || len(arr.Elements) != 6 {
+		t.Fatalf("unexpected result %s", val.Inspect())
+	}
+	if got := (&Array{Elements: arr.Elements[:5]}).Inspect(); got != `[4, 7.5, 3, 3.55, {route: "/a"}]` {
+		t.Fatalf("unexpected metric values %s", got)
+	}
+	text := arr.Elements[5].(*String).Value
+	for _, want := range []string{
+		"# TYPE app_hits_total counter\napp_hits_total{route=\"/a\"} 4\napp_hits_total{route=\"/b\"} 1\n",
+		"# TYPE app_queue_depth gauge\napp_queue_depth 7.5\n",
+		"# TYPE app_latency_seconds histogram\n" +
+			"app_latency_seconds_bucket{le=\"0.1\"} 1\n" +
+			"app_latency_seconds_bucket{le=\"0.5\"} 2\n" +
+			"app_latency_seconds_bucket{le=\"1\"} 2\n" +
+			"app_latency_seconds_bucket{le=\"+Inf\"} 3\n" +
+			"app_latency_seconds_sum 3.55\n" +
+			"app_latency_seconds_count 3\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("expected exposition to contain\n%s\ngot:\n%s", want, text)
+		}
+	}
+}
+
+func TestMetricsRejectMisuse(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{`counter("bad name")`, "invalid metric name"},
+		{`counter("ok_total", { __name: 1 })`, "invalid label name"},
+		{`counter("c_total").inc(-1)`, "counter c_total cannot decrease"},
+		{`counter("m")
+gauge("m")`, "metric m is already registered as a counter"},
+		{`histogram("h", [1, 2])
+histogram("h", [1, 3])`, "histogram h is already registered with different buckets"},
+		{`histogram("h", [2, 1])`, "histogram buckets must be increasing"},
+		{`counter("c_total").set(1)`, "unknown counter member: set"},
+		{`histogram("h", null, { le: "x" })`, "cannot use label \"le\""},
+	}
+	for _, tc := range cases {
+		_, err := evalInput(t, tc.input)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%s: expected %q error, got %v", tc.input, tc.want, err)
+		}
+	}
+}
+
+func TestRuntimeMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	input := fmt.Sprintf(`
+let ok = () -> 1
+let boom = () -> fail("boom")
+let slow = () -> sleep(10000)
+let a = wait & ok()
+let b = (wait & boom()) ? 0
+let s = & slow()
+s.cancel()
+let c = (wait s) ? 0
+let d = decodeJson("{") ? 0
+let e = readFile("/definitely/not/here") ? 0
+let f = readFile("/definitely/not/here/either") ? 0
+http({ url: %q })
+http({ method: "post", url: %q })
+`, server.URL, server.URL+"/missing")
+	var eval *interpreter.Evaluator
+	_, err := evalWithConfiguredEvaluator(t, input, func(e *interpreter.Evaluator) { eval = e })
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	text := eval.MetricsText()
+	for _, want := range []string{
+		"karl_tasks_spawned_total 3\n",
+		"karl_tasks_failed_total 1\n",
+		"karl_tasks_canceled_total 1\n",
+		`karl_recoverable_errors_total{kind="fail"} 1` + "\n",
+		`karl_recoverable_errors_total{kind="decodeJson"} 1` + "\n",
+		`karl_recoverable_errors_total{kind="readFile"} 2` + "\n",
+		`karl_http_requests_total{code="200",method="GET"} 1` + "\n",
+		`karl_http_requests_total{code="404",method="POST"} 1` + "\n",
+		`karl_http_request_duration_seconds_count{method="GET"} 1` + "\n",
+		"# HELP karl_tasks_spawned_total ",
+	} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("expected metrics to contain %q, got:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, `kind="canceled"`) {
+		t.Fatalf("cancellation should not count as a recoverable error:\n%s", text)
+	}
+}
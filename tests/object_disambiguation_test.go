@@ -1,9 +1,11 @@
 package tests
 
 import (
+	"fmt"
 	"karl/ast"
 	"karl/lexer"
 	"karl/parser"
+	"strings"
 	"testing"
 )
 
@@ -71,3 +73,68 @@ func TestObjectDisambiguation(t *testing.T) {
 		})
 	}
 }
+
+func TestNewlineStatementBoundaries(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"call_on_same_line", "foo(bar)", []string{"CallExpression"}},
+		{"paren_on_next_line", "foo\n(bar)", []string{"Identifier", "Identifier"}},
+		{"bracket_on_next_line", "x\n[1]", []string{"Identifier", "ArrayLiteral"}},
+		{"object_then_paren", "let o = { x: 1 }\n(o)", []string{"LetStatement", "Identifier"}},
+		{"block_then_bracket", "let b = { x }\n[b]", []string{"LetStatement", "ArrayLiteral"}},
+		{"call_result_then_paren", "f(1)\n(2)", []string{"CallExpression", "IntegerLiteral"}},
+		{"struct_init_on_next_line", "let p = Point\n{ x: 1 }", []string{"LetStatement", "ObjectLiteral"}},
+		{"struct_init_same_line", "let p = Point { x: 1 }", []string{"LetStatement"}},
+		{"trailing_operator", "let a = 1 +\n(2)", []string{"LetStatement"}},
+		{"trailing_comma_in_args", "foo(1,\n(2))", []string{"CallExpression"}},
+		{"inside_parens", "(foo\n(bar))", []string{"CallExpression"}},
+		{"inside_array", "[foo\n[0]]", []string{"ArrayLiteral"}},
+		{"leading_dot_chain", "xs\n  .map(x -> x)\n  .filter(x -> x)", []string{"CallExpression"}},
+		{"leading_operator", "let a = 1\n  + 2", []string{"LetStatement"}},
+		{"inside_block", "let f = () -> {\n  foo\n  (bar)\n}", []string{"LetStatement"}},
+		{"multiline_string_then_index", "let s = \"a\nb\"[0]", []string{"LetStatement"}},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			p := parser.New(lexer.New(tc.input))
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+			var got []string
+			for _, stmt := range program.Statements {
+				var node ast.Node = stmt
+				if es, ok := stmt.(*ast.ExpressionStatement); ok {
+					node = es.Expression
+				}
+				got = append(got, strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast."))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Fatalf("expected %v, got %v\n%s", tc.want, got, ast.Format(program))
+			}
+		})
+	}
+
+	p := parser.New(lexer.New("let f = () -> {\n  foo\n  (bar)\n}"))
+	body := p.ParseProgram().Statements[0].(*ast.LetStatement).Value.(*ast.LambdaExpression).Body.(*ast.BlockExpression)
+	if len(body.Statements) != 2 {
+		t.Fatalf("expected two statements in the block, got %s", ast.Format(body))
+	}
+}
+
+func TestFindContinuations(t *testing.T) {
+	input := "foo\n(bar)\nxs\n  [0]\nlet p = Point\n{ x: 1 }\nlog(a\n(b))\nlet ok = f(1)\nlet q = \"a\nb\"\n[1]"
+	var got []string
+	for _, c := range parser.FindContinuations(input) {
+		got = append(got, fmt.Sprintf("%s@%d:%d after %s@%d", c.Token.Literal, c.Token.Line, c.Token.Column, c.Prev.Literal, c.Prev.Line))
+	}
+	want := []string{"(@2:1 after foo@1", "[@4:3 after xs@3", "{@6:1 after Point@5", "[@12:1 after a\nb@10"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+	if conts := parser.FindContinuations("foo(bar)\nlet x = [1][0]"); len(conts) != 0 {
+		t.Fatalf("expected no continuations, got %v", conts)
+	}
+}
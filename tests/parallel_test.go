@@ -0,0 +1,188 @@
+package tests
+
+import (
+	"testing"
+
+	"karl/interpreter"
+)
+
+func TestParallelMapBoundsConcurrencyAndKeepsInputOrder(t *testing.T) {
+	input := `
+let state = { inflight: 0, peak: 0 }
+let work = (n) -> {
+    state.inflight += 1
+    if state.inflight > state.peak { state.peak = state.inflight }
+    sleep(50 - n * 5)
+    state.inflight -= 1
+    n * n
+}
+let squares = wait parallelMap([1, 2, 3, 4, 5, 6, 7, 8], work, { concurrency: 3 })
+let out = [squares, state.peak]
+out
+`
+	for seed := int64(0); seed < 5; seed++ {
+		assertEquivalent(t, mustEvalDeterministic(t, input, seed), &Array{Elements: []Value{
+			&Array{Elements: []Value{
+				&Integer{Value: 1}, &Integer{Value: 4}, &Integer{Value: 9}, &Integer{Value: 16},
+				&Integer{Value: 25}, &Integer{Value: 36}, &Integer{Value: 49}, &Integer{Value: 64},
+			}},
+			&Integer{Value: 3},
+		}})
+	}
+}
+
+func TestParallelMapUnorderedReturnsCompletionOrder(t *testing.T) {
+	input := `
+let work = (n) -> { sleep(n); n }
+wait parallelMap([30, 10, 20], work, { concurrency: 3, ordered: false })
+`
+	assertEquivalent(t, mustEvalDeterministic(t, input, 1), &Array{Elements: []Value{
+		&Integer{Value: 10}, &Integer{Value: 20}, &Integer{Value: 30},
+	}})
+}
+
+func TestParallelMapFailsFastAndStopsRemainingItems(t *testing.T) {
+	input := `
+let state = { started: 0 }
+let work = (n) -> {
+    state.started += 1
+    sleep(10)
+    if n == 2 { fail("bad item") }
+    n
+}
+let message = (wait parallelMap([1, 2, 3, 4, 5, 6], work, { concurrency: 2 })) ? { error.message }
+sleep(100)
+let out = [message, state.started]
+out
+`
+	assertEquivalent(t, mustEvalDeterministic(t, input, 1), &Array{Elements: []Value{
+		&String{Value: "bad item"},
+		&Integer{Value: 2},
+	}})
+}
+
+func TestParallelMapCancelStopsWorkers(t *testing.T) {
+	input := `
+let state = { finished: 0 }
+let work = (n) -> { sleep(100); state.finished += 1; n }
+let task = parallelMap([1, 2, 3, 4], work, { concurrency: 2 })
+sleep(10)
+task.cancel()
+let kind = (wait task) ? { error.kind }
+sleep(500)
+let out = [kind, state.finished]
+out
+`
+	assertEquivalent(t, mustEvalDeterministic(t, input, 2), &Array{Elements: []Value{
+		&String{Value: "canceled"},
+		&Integer{Value: 0},
+	}})
+}
+
+func TestParallelMapWithoutSharedStateIsRaceFree(t *testing.T) {
+	input := `
+let items = 1..200
+let doubled = wait parallelMap(items, (n) -> n * 2, { concurrency: 4 })
+doubled.reduce((acc, n) -> acc + n, 0)
+`
+	assertInteger(t, mustEval(t, input), 40200)
+}
+
+func TestPoolLimitsRunningTasks(t *testing.T) {
+	input := `
+let state = { inflight: 0, peak: 0 }
+let work = (n) -> {
+    state.inflight += 1
+    if state.inflight > state.peak { state.peak = state.inflight }
+    sleep(10)
+    state.inflight -= 1
+    n + 100
+}
+let workers = pool(2)
+let tasks = [1, 2, 3, 4, 5].map((n) -> workers.submit(work, n))
+let results = tasks.map((t) -> wait t)
+let out = [results, state.peak]
+out
+`
+	for seed := int64(0); seed < 5; seed++ {
+		assertEquivalent(t, mustEvalDeterministic(t, input, seed), &Array{Elements: []Value{
+			&Array{Elements: []Value{
+				&Integer{Value: 101}, &Integer{Value: 102}, &Integer{Value: 103}, &Integer{Value: 104}, &Integer{Value: 105},
+			}},
+			&Integer{Value: 2},
+		}})
+	}
+}
+
+func TestPoolCanceledQueuedTaskNeverRuns(t *testing.T) {
+	input := `
+let state = { ran: [] }
+let work = (name) -> { state.ran.push(name); sleep(10); name }
+let workers = pool(1)
+let a = workers.submit(work, "a")
+let b = workers.submit(work, "b")
+let c = workers.submit(work, "c")
+b.cancel()
+let kind = (wait b) ? { error.kind }
+let out = [wait a, kind, wait c, state.ran]
+out
+`
+	assertEquivalent(t, mustEvalDeterministic(t, input, 1), &Array{Elements: []Value{
+		&String{Value: "a"},
+		&String{Value: "canceled"},
+		&String{Value: "c"},
+		&Array{Elements: []Value{&String{Value: "a"}, &String{Value: "c"}}},
+	}})
+}
+
+func TestPoolCancelStopsRunningAndQueuedTasks(t *testing.T) {
+	input := `
+let state = { finished: 0 }
+let work = () -> { sleep(50); state.finished += 1 }
+let workers = pool(2)
+let tasks = [1, 2, 3, 4].map((_) -> workers.submit(work))
+sleep(10)
+workers.cancel()
+let kinds = tasks.map((t) -> (wait t) ? { error.kind })
+sleep(200)
+let out = [kinds, state.finished]
+out
+`
+	canceled := &String{Value: "canceled"}
+	assertEquivalent(t, mustEvalDeterministic(t, input, 1), &Array{Elements: []Value{
+		&Array{Elements: []Value{canceled, canceled, canceled, canceled}},
+		&Integer{Value: 0},
+	}})
+}
+
+func TestPoolTasksAreCanceledWithTheirParent(t *testing.T) {
+	input := `
+let state = { finished: 0 }
+let workers = pool(1)
+let work = () -> { sleep(50); state.finished += 1 }
+let owner = & (() -> {
+    let a = workers.submit(work)
+    let b = workers.submit(work)
+    wait a
+    wait b
+})()
+sleep(10)
+owner.cancel()
+sleep(200)
+state.finished
+`
+	assertInteger(t, mustEvalDeterministic(t, input, 1), 0)
+}
+
+func TestPoolUnobservedFailureFollowsFailFast(t *testing.T) {
+	input := `
+let workers = pool(1)
+workers.submit(() -> fail("boom"))
+sleep(100)
+"unreachable"
+`
+	_, err := evalDeterministic(t, input, 1)
+	if _, ok := err.(*interpreter.UnhandledTaskError); !ok {
+		t.Fatalf("expected UnhandledTaskError, got %T (%v)", err, err)
+	}
+}
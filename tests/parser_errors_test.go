@@ -1,6 +1,8 @@
 package tests
 
 import (
+	"fmt"
+	"karl/ast"
 	"karl/lexer"
 	"karl/parser"
 	"strings"
@@ -52,3 +54,120 @@ func TestParserErrors(t *testing.T) {
 		})
 	}
 }
+
+func parseWithErrors(t *testing.T, input string) (*ast.Program, []parser.ParseError) {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	return program, p.ErrorsDetailed()
+}
+
+func TestParserReportsEachErrorOnce(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		lines []int
+	}{
+		{"statements", "let a = 1 +\nlet b = 2\nlet c = )\nlet d = 4", []int{2, 3}},
+		{"call_arguments", "log(1 2)\nlog(3 4)\nlog(5)", []int{1, 2}},
+		{"block", "let f = () -> {\n  let x = foo(1 2)\n  let y = ]\n  x + y\n}\nlet g = [1 2]", []int{2, 3, 6}},
+		{"match_arms", "match v {\n  case 1 -> )\n  case 2 2 -> b\n  case _ -> c\n}\nlet after = (", []int{2, 3, 6}},
+		{"semicolons", "let a = ); let b = 2; let c = ]", []int{1, 1}},
+		{"unclosed_paren", "let x = (1 + 2\nlet y = 3 +\nlet z = 1", []int{2, 3}},
+		{"unclosed_at_eof", "if { ( [", []int{1}},
+		{"node_errors", "let x = 1.0..2.0\n1 = 2\n& foo", []int{1, 1, 2, 3}},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			_, errs := parseWithErrors(t, tc.input)
+			var lines []int
+			for _, err := range errs {
+				lines = append(lines, err.Token.Line)
+			}
+			if fmt.Sprint(lines) != fmt.Sprint(tc.lines) {
+				t.Fatalf("expected errors on lines %v, got %v:\n%s", tc.lines, lines, parser.FormatParseErrors(errs, tc.input, ""))
+			}
+		})
+	}
+}
+
+func TestParserReturnsPartialAST(t *testing.T) {
+	input := `let a = 1
+let b = (2 +
+let c = { x: 1 }
+let f = () -> {
+  let y = ]
+  a + c.x
+}
+let m = match a {
+  case 1 -> )
+  case _ -> "other"
+}
+log(f(), m)`
+	program, errs := parseWithErrors(t, input)
+	if len(errs) != 3 {
+		t.Fatalf("expected 3 errors, got %d:\n%s", len(errs), parser.FormatParseErrors(errs, input, ""))
+	}
+	var names []string
+	for _, stmt := range program.Statements {
+		if let, ok := stmt.(*ast.LetStatement); ok {
+			names = append(names, let.Name.(*ast.Identifier).Value)
+		}
+	}
+	if strings.Join(names, ",") != "a,b,c,f,m" || len(program.Statements) != 6 {
+		t.Fatalf("expected every statement to survive, got %v\n%s", names, ast.Format(program))
+	}
+	lambda := program.Statements[3].(*ast.LetStatement).Value.(*ast.LambdaExpression)
+	if body := lambda.Body.(*ast.BlockExpression); len(body.Statements) != 2 {
+		t.Fatalf("expected the block to keep both statements, got %s", ast.Format(body))
+	}
+	match := program.Statements[4].(*ast.LetStatement).Value.(*ast.MatchExpression)
+	if len(match.Arms) != 2 {
+		t.Fatalf("expected both match arms, got %d", len(match.Arms))
+	}
+}
+
+func TestParserErrorSpans(t *testing.T) {
+	input := "let s = \"abc\" \"def\"\nfoo.bar = 1\na + b = 2\n"
+	_, errs := parseWithErrors(t, input)
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got %v", errs)
+	}
+	// "abc" "def" is two statements; the assignment target on line 3 is the error.
+	span := errs[0].Span
+	if got := input[span.Start.Offset:span.End.Offset]; got != "a + b" {
+		t.Fatalf("expected span over a + b, got %q (%+v)", got, span)
+	}
+
+	input = "let x = [1, 2 3]"
+	_, errs = parseWithErrors(t, input)
+	if len(errs) != 1 || input[errs[0].Span.Start.Offset:errs[0].Span.End.Offset] != "3" {
+		t.Fatalf("expected one error on 3, got %+v", errs)
+	}
+
+	input = "let r = 1..2.50"
+	_, errs = parseWithErrors(t, input)
+	out := parser.FormatParseErrors(errs, input, "r.k")
+	if !strings.Contains(out, "at r.k:1:12") || !strings.HasSuffix(out, "|            ^^^^") {
+		t.Fatalf("expected the float to be underlined, got:\n%s", out)
+	}
+}
+
+func TestParserRecoveryTerminates(t *testing.T) {
+	inputs := []string{
+		"}}} ))) ]]] let x = 1",
+		"match { case case -> -> }",
+		"((((((((",
+		"let let let = = =",
+		"{ case } case ) match x { ) } ]",
+		"for for { match } { case",
+		"let f = x -> { match x { case 1 -> { ( } } }",
+	}
+	for _, input := range inputs {
+		_, errs := parseWithErrors(t, input)
+		if len(errs) == 0 {
+			t.Errorf("%q: expected parse errors", input)
+		}
+	}
+}
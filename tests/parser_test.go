@@ -4,6 +4,7 @@ import (
 	"karl/ast"
 	"karl/lexer"
 	"karl/parser"
+	"strings"
 	"testing"
 )
 
@@ -76,3 +77,86 @@ let fastest = !& { taskA(), taskB() }`
 		t.Fatalf("expected RaceExpression, got %T", stmt1.Value)
 	}
 }
+
+func TestLiteralForms(t *testing.T) {
+	input := "let n = [0xFF, 0b1010, 1_000, 1.5e3, 2_0.5]\n" +
+		"let s = [r\"a\\b\", \"\"\"\n    x\n    \"\"\", r\"\"\"\\d+\"\"\"]"
+	program := parseProgram(t, input)
+	nums := program.Statements[0].(*ast.LetStatement).Value.(*ast.ArrayLiteral).Elements
+	for i, want := range []int64{255, 10, 1000} {
+		if lit := nums[i].(*ast.IntegerLiteral); lit.Value != want {
+			t.Errorf("element %d: expected %d, got %d", i, want, lit.Value)
+		}
+	}
+	for i, want := range []float64{1500, 20.5} {
+		if lit := nums[3+i].(*ast.FloatLiteral); lit.Value != want {
+			t.Errorf("element %d: expected %g, got %g", 3+i, want, lit.Value)
+		}
+	}
+
+	strs := program.Statements[1].(*ast.LetStatement).Value.(*ast.ArrayLiteral).Elements
+	want := []ast.StringLiteral{
+		{Value: `a\b`, Raw: true},
+		{Value: "x", Multiline: true},
+		{Value: `\d+`, Raw: true, Multiline: true},
+	}
+	for i, w := range want {
+		lit := strs[i].(*ast.StringLiteral)
+		if lit.Value != w.Value || lit.Raw != w.Raw || lit.Multiline != w.Multiline {
+			t.Errorf("string %d: expected %+v, got %q raw=%t multiline=%t", i, w, lit.Value, lit.Raw, lit.Multiline)
+		}
+	}
+	span := ast.NodeSpan(strs[1])
+	if got := input[span.Start.Offset:span.End.Offset]; got != "\"\"\"\n    x\n    \"\"\"" {
+		t.Fatalf("unexpected span of the triple-quoted string: %q", got)
+	}
+
+	formatted := ast.Format(program)
+	for _, s := range []string{"Integer(255, 0xFF)", "Float(1500, 1.5e3)", `String("a\\b", raw)`, `String("x", multiline)`, `String("\\d+", raw multiline)`} {
+		if !strings.Contains(formatted, s) {
+			t.Errorf("expected %s in\n%s", s, formatted)
+		}
+	}
+	decoded, out := roundTripJSON(t, program)
+	if !strings.Contains(out, `"literal": "0xFF"`) || !strings.Contains(out, `"raw": true`) || !strings.Contains(out, `"multiline": true`) {
+		t.Fatalf("expected literal forms in JSON:\n%s", out)
+	}
+	if ast.Format(decoded) != formatted {
+		t.Fatalf("literal forms lost in a JSON round trip")
+	}
+}
+
+func TestBadNumberLiterals(t *testing.T) {
+	for _, input := range []string{"0x", "0b102", "1__000", "1_", "0xFFFF_FFFF_FFFF_FFFF", "1_e5", "match x { case 0b2 -> 1 }"} {
+		p := parser.New(lexer.New(input))
+		p.ParseProgram()
+		errs := p.Errors()
+		if len(errs) == 0 || !strings.Contains(errs[0], "could not parse") {
+			t.Errorf("%q: expected a number parse error, got %v", input, errs)
+		}
+	}
+}
+
+func TestUnterminatedStringErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		span  string
+		msg   string
+	}{
+		{"let s = \"\"\"\n  abc\nlog(s)\nlog(\"done\")\n", `"""`, `unterminated string: missing closing """`},
+		{"log(1)\nlet p = r\"abc\n", `r"`, `unterminated string: missing closing "`},
+		{"let p = \"abc", `"`, `unterminated string: missing closing "`},
+	}
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		p.ParseProgram()
+		errs := p.ErrorsDetailed()
+		if len(errs) != 1 {
+			t.Fatalf("%q: expected one error, got %v", tt.input, p.Errors())
+		}
+		span := errs[0].Span
+		if errs[0].Message != tt.msg || tt.input[span.Start.Offset:span.End.Offset] != tt.span {
+			t.Fatalf("%q: expected %q over %q, got %+v", tt.input, tt.msg, tt.span, errs[0])
+		}
+	}
+}
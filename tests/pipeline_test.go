@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"karl/ast"
+	"karl/interpreter"
+	"karl/lexer"
+	"karl/parser"
+)
+
+func TestPipelineParsing(t *testing.T) {
+	input := "let y = a || b |> f |> g(_, 1 + 2)"
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	// Left-associative, looser than || and arithmetic, tighter than =.
+	outer, ok := program.Statements[0].(*ast.LetStatement).Value.(*ast.InfixExpression)
+	if !ok || outer.Operator != "|>" {
+		t.Fatalf("expected |> at the top, got %T", program.Statements[0].(*ast.LetStatement).Value)
+	}
+	if call, ok := outer.Right.(*ast.CallExpression); !ok || len(call.Arguments) != 2 {
+		t.Fatalf("expected g(_, 1 + 2) on the right, got %T", outer.Right)
+	}
+	inner, ok := outer.Left.(*ast.InfixExpression)
+	if !ok || inner.Operator != "|>" {
+		t.Fatalf("expected nested |> on the left, got %T", outer.Left)
+	}
+	if or, ok := inner.Left.(*ast.InfixExpression); !ok || or.Operator != "||" {
+		t.Fatalf("expected a || b piped, got %T", inner.Left)
+	}
+
+	// A line-leading |> continues the expression.
+	program, errs := parseWithErrors(t, "let r = xs\n  |> map(_, f)\n  |> sum\nr")
+	if len(errs) != 0 || len(program.Statements) != 2 {
+		t.Fatalf("expected two statements, got %d (%v)", len(program.Statements), errs)
+	}
+}
+
+func TestPipelineParseErrors(t *testing.T) {
+	cases := []struct {
+		input string
+		span  string
+		msg   string
+	}{
+		{"x |> ", "|>", "expected a function after '|>'"},
+		{"x |> )", "|>", "expected a function after '|>'"},
+		{"x |> 5", "5", "right side of '|>' must be a function or a call"},
+		{"x |> \"name\"", "\"name\"", "right side of '|>' must be a function or a call"},
+		{"x |> null", "null", "right side of '|>' must be a function or a call"},
+	}
+	for _, tc := range cases {
+		_, errs := parseWithErrors(t, tc.input)
+		if len(errs) == 0 {
+			t.Fatalf("%q: expected a parse error", tc.input)
+		}
+		span := errs[0].Span
+		if errs[0].Message != tc.msg || tc.input[span.Start.Offset:span.End.Offset] != tc.span {
+			t.Fatalf("%q: expected %q over %q, got %+v", tc.input, tc.msg, tc.span, errs[0])
+		}
+	}
+}
+
+func TestEvalPipeline(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected int64
+	}{
+		{"let double = x -> x * 2; 4 |> double", 8},
+		{"let double = x -> x * 2; 4 |> double |> double", 16},
+		{"let sub = (a, b) -> a - b; 10 |> sub(_, 3)", 7},
+		{"let sub = (a, b) -> a - b; 10 |> sub(3, _)", -7},
+		{"let sub = a -> b -> a - b; 10 |> sub(3)", -7},
+		{"[1, 2, 3, 4] |> filter(_, x -> x % 2 == 0) |> map(_, x -> x * 10) |> sum", 60},
+		{"\"a,b,c\" |> split(_, \",\") |> len", 3},
+		{"let m = { inc: x -> x + 1 }; 1 |> m.inc", 2},
+		{"let add = (a, b) -> a + b; let add5 = add(5, _); 1 |> add5", 6},
+		{"let xs = [3]; xs |> (v -> v[0] + 1)", 4},
+		{"let y = 2 |> (x -> x + 1); y", 3},
+	}
+	for _, tc := range cases {
+		assertInteger(t, mustEval(t, tc.input), tc.expected)
+	}
+}
+
+func TestEvalPipelineEvaluatesLeftFirst(t *testing.T) {
+	input := `let order = ""
+let note = (label, v) -> { order += label; v }
+note("left,", 1) |> note("right", x -> x)
+order`
+	assertString(t, mustEval(t, input), "left,right")
+}
+
+func TestEvalPipelineModuleFunction(t *testing.T) {
+	dir := t.TempDir()
+	modulePath := filepath.Join(dir, "text.k")
+	if err := os.WriteFile(modulePath, []byte(`let shout = s -> s + "!"`), 0o644); err != nil {
+		t.Fatalf("write module: %v", err)
+	}
+
+	input := `let text = (import "text.k")(); "hi" |> text.shout |> text.shout`
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	eval := interpreter.NewEvaluatorWithSourceFilenameAndRoot(input, "<stdin>", dir)
+	val, _, err := eval.Eval(program, interpreter.NewBaseEnvironment())
+	if err != nil {
+		t.Fatalf("eval error: %v", err)
+	}
+	assertString(t, val, "hi!!")
+}
+
+func TestEvalPipelineErrors(t *testing.T) {
+	cases := []struct {
+		input  string
+		column int
+		msg    string
+	}{
+		{"let n = 3\n1 |> n", 6, "right side of '|>' is INTEGER, not a function"},
+		{"let inc = x -> x + 1\n1 |> inc == 2", 6, "right side of '|>' is BOOLEAN, not a function"},
+		{"let n = 3\n1 |> -n * 2", 6, "right side of '|>' is INTEGER, not a function"},
+		{"let f = (a, b) -> a + b\n1 |> f(2, 3)", 6, "mark where the piped value goes with '_'"},
+		{"let f = (a, b) -> a + b\n1 |> f", 6, "wrong number of arguments"},
+		{"let f = (a, b, c) -> a\n1 |> f(_, 2, _)", 7, "not enough arguments for partial"},
+	}
+	for _, tc := range cases {
+		_, err := evalInput(t, tc.input)
+		re, ok := err.(*interpreter.RuntimeError)
+		if !ok {
+			t.Fatalf("%q: expected RuntimeError, got %T (%v)", tc.input, err, err)
+		}
+		if !strings.Contains(re.Message, tc.msg) {
+			t.Fatalf("%q: expected error containing %q, got %q", tc.input, tc.msg, re.Message)
+		}
+		if re.Token == nil || re.Token.Line != 2 || re.Token.Column != tc.column {
+			t.Fatalf("%q: expected error at 2:%d, got %+v", tc.input, tc.column, re.Token)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package tests
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"karl/interpreter"
+)
+
+// pprofProfile is the part of a decoded profile.proto the tests look at.
+type pprofProfile struct {
+	sampleTypes []string
+	samples     []pprofSample
+}
+
+type pprofSample struct {
+	// stack is leaf first, as "function file:line".
+	stack  []string
+	values []int64
+}
+
+func decodePprof(t *testing.T, data []byte) pprofProfile {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("profile is not gzipped: %v", err)
+	}
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("read profile: %v", err)
+	}
+	type function struct{ name, file int64 }
+	type line struct{ function, line int64 }
+	var (
+		strs      []string
+		types     [][]byte
+		samples   [][]byte
+		functions = map[int64]function{}
+		locations = map[int64]line{}
+	)
+	for _, f := range protoFields(t, raw) {
+		switch f.num {
+		case 1:
+			types = append(types, f.data)
+		case 2:
+			samples = append(samples, f.data)
+		case 4:
+			var id int64
+			var l line
+			for _, lf := range protoFields(t, f.data) {
+				switch lf.num {
+				case 1:
+					id = int64(lf.value)
+				case 4:
+					for _, ff := range protoFields(t, lf.data) {
+						if ff.num == 1 {
+							l.function = int64(ff.value)
+						} else if ff.num == 2 {
+							l.line = int64(ff.value)
+						}
+					}
+				}
+			}
+			locations[id] = l
+		case 5:
+			var id int64
+			var fn function
+			for _, ff := range protoFields(t, f.data) {
+				switch ff.num {
+				case 1:
+					id = int64(ff.value)
+				case 2:
+					fn.name = int64(ff.value)
+				case 4:
+					fn.file = int64(ff.value)
+				}
+			}
+			functions[id] = fn
+		case 6:
+			strs = append(strs, string(f.data))
+		}
+	}
+	var p pprofProfile
+	for _, vt := range types {
+		for _, f := range protoFields(t, vt) {
+			if f.num == 1 {
+				p.sampleTypes = append(p.sampleTypes, strs[f.value])
+			}
+		}
+	}
+	for _, s := range samples {
+		var sample pprofSample
+		for _, f := range protoFields(t, s) {
+			for _, v := range packedVarints(f.data) {
+				switch f.num {
+				case 1:
+					loc := locations[int64(v)]
+					fn := functions[loc.function]
+					sample.stack = append(sample.stack, strs[fn.name]+" "+strs[fn.file]+":"+strconv.FormatInt(loc.line, 10))
+				case 2:
+					sample.values = append(sample.values, int64(v))
+				}
+			}
+		}
+		p.samples = append(p.samples, sample)
+	}
+	return p
+}
+
+type protoField struct {
+	num   int
+	value uint64
+	data  []byte
+}
+
+func protoFields(t *testing.T, buf []byte) []protoField {
+	t.Helper()
+	var out []protoField
+	for len(buf) > 0 {
+		key, n := readVarint(buf)
+		buf = buf[n:]
+		f := protoField{num: int(key >> 3)}
+		switch key & 7 {
+		case 0:
+			f.value, n = readVarint(buf)
+			buf = buf[n:]
+		case 2:
+			size, n := readVarint(buf)
+			buf = buf[n:]
+			f.data = buf[:size]
+			buf = buf[size:]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+func readVarint(buf []byte) (uint64, int) {
+	var v uint64
+	for i, b := range buf {
+		v |= uint64(b&0x7f) << (7 * i)
+		if b < 0x80 {
+			return v, i + 1
+		}
+	}
+	return v, len(buf)
+}
+
+func packedVarints(buf []byte) []uint64 {
+	var out []uint64
+	for len(buf) > 0 {
+		v, n := readVarint(buf)
+		out = append(out, v)
+		buf = buf[n:]
+	}
+	return out
+}
+
+func TestAllocProfileAttributesValuesToSourceLines(t *testing.T) {
+	input := `let build = (n) -> [1, 2, 3].map(x -> "item " + str(x * n))
+let items = build(4)
+let obj = { items: items }
+obj
+`
+	var buf bytes.Buffer
+	var eval *interpreter.Evaluator
+	_, err := evalWithConfiguredEvaluator(t, input, func(e *interpreter.Evaluator) {
+		eval = e
+		e.StartAllocProfile(&buf)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := eval.StopAllocProfile(); err != nil {
+		t.Fatalf("StopAllocProfile: %v", err)
+	}
+	p := decodePprof(t, buf.Bytes())
+	if len(p.sampleTypes) != 2 || p.sampleTypes[0] != "alloc_objects" || p.sampleTypes[1] != "alloc_space" {
+		t.Fatalf("unexpected sample types %v", p.sampleTypes)
+	}
+	counts := map[string]int64{}
+	for _, s := range p.samples {
+		key := ""
+		for _, frame := range s.stack {
+			key += frame + ";"
+		}
+		counts[key] += s.values[0]
+	}
+	want := map[string]int64{
+		// "item " literal, str() and + for each of the three elements.
+		"[lambda] <test>:1;build <test>:1;[toplevel] <test>:2;": 9,
+		// The array literal and the result of map.
+		"build <test>:1;[toplevel] <test>:2;": 2,
+		"[toplevel] <test>:3;":                1,
+	}
+	for key, n := range want {
+		if counts[key] != n {
+			t.Fatalf("expected %d allocations at %s, got %v", n, key, counts)
+		}
+	}
+}
+
+// The stacks themselves are checked by an internal test that can wait for
+// the sampler; this one covers the pprof encoding, whatever was sampled.
+func TestCPUProfileWritesPprof(t *testing.T) {
+	input := `
+let fib = (n) -> if n <= 1 { n } else { fib(n - 1) + fib(n - 2) }
+let worker = () -> fib(18)
+let t = & worker()
+let r = fib(18)
+wait t
+`
+	var buf bytes.Buffer
+	var eval *interpreter.Evaluator
+	_, err := evalWithConfiguredEvaluator(t, input, func(e *interpreter.Evaluator) {
+		eval = e
+		e.StartCPUProfile(&buf)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := eval.StopCPUProfile(); err != nil {
+		t.Fatalf("StopCPUProfile: %v", err)
+	}
+	p := decodePprof(t, buf.Bytes())
+	if len(p.sampleTypes) != 2 || p.sampleTypes[0] != "samples" || p.sampleTypes[1] != "cpu" {
+		t.Fatalf("unexpected sample types %v", p.sampleTypes)
+	}
+	for _, s := range p.samples {
+		root := s.stack[len(s.stack)-1]
+		if root != "worker <test>:3" && !strings.HasPrefix(root, "[toplevel] <test>:") {
+			t.Fatalf("unexpected root frame %q in %v", root, s.stack)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+)
+
+func intArray(values ...int64) *Array {
+	out := &Array{}
+	for _, v := range values {
+		out.Elements = append(out.Elements, &Integer{Value: v})
+	}
+	return out
+}
+
+func TestEveryRunsOnVirtualClockUntilLimit(t *testing.T) {
+	input := `
+let starts = []
+let handle = every(100, () -> starts.push(now()), { limit: 3 })
+let runs = wait handle
+let out = [runs, starts, handle.stats().next]
+out
+`
+	for seed := int64(1); seed <= 5; seed++ {
+		val := mustEvalDeterministic(t, input, seed)
+		assertEquivalent(t, val, &Array{Elements: []Value{
+			&Integer{Value: 3},
+			intArray(100, 200, 300),
+			NullValue,
+		}})
+	}
+}
+
+func TestAfterReturnsCallbackResult(t *testing.T) {
+	input := `
+let handle = after("50ms", () -> now() + 1)
+let out = [wait handle, handle.task.status()]
+out
+`
+	val := mustEvalDeterministic(t, input, 7)
+	assertEquivalent(t, val, &Array{Elements: []Value{
+		&Integer{Value: 51},
+		&String{Value: "done"},
+	}})
+}
+
+func TestScheduleOverlapPolicies(t *testing.T) {
+	program := func(policy string) string {
+		return `
+let starts = []
+let handle = every(10, () -> {
+    starts.push(now())
+    sleep(25)
+}, { overlap: "` + policy + `", limit: 3 })
+wait handle
+let out = [starts, handle.stats().skipped]
+out
+`
+	}
+	cases := []struct {
+		policy  string
+		starts  *Array
+		skipped int64
+	}{
+		{"skip", intArray(10, 40, 70), 4},
+		{"queue", intArray(10, 35, 60), 0},
+		{"allow", intArray(10, 20, 30), 0},
+	}
+	for _, tc := range cases {
+		val := mustEvalDeterministic(t, program(tc.policy), 3)
+		assertEquivalent(t, val, &Array{Elements: []Value{tc.starts, &Integer{Value: tc.skipped}}})
+	}
+}
+
+func TestCronFiresOnMatchingMinutes(t *testing.T) {
+	input := `
+let starts = []
+let handle = cron("*/5 * * * *", () -> starts.push(floor(now() / 60000)), { timezone: "UTC", limit: 3 })
+wait handle
+starts
+`
+	val := mustEvalDeterministic(t, input, 1)
+	assertEquivalent(t, val, intArray(5, 10, 15))
+}
+
+func TestCronRejectsInvalidExpressions(t *testing.T) {
+	for _, expr := range []string{"* * * *", "61 * * * *", "*/0 * * * *", "5-1 * * * *"} {
+		_, err := evalInput(t, `cron("`+expr+`", () -> 1)`)
+		if err == nil || !strings.Contains(err.Error(), "cron") {
+			t.Fatalf("%q: expected cron error, got %v", expr, err)
+		}
+	}
+}
+
+func TestScheduleCancelStopsRuns(t *testing.T) {
+	input := `
+let count = atomic(0)
+let handle = every(10, () -> count.add(1))
+sleep(35)
+handle.cancel()
+sleep(100)
+let status = (wait handle) ? error.kind
+let out = [count.get(), status, handle.task.status(), handle.stats().next]
+out
+`
+	val := mustEvalDeterministic(t, input, 2)
+	assertEquivalent(t, val, &Array{Elements: []Value{
+		&Integer{Value: 3},
+		&String{Value: "canceled"},
+		&String{Value: "canceled"},
+		NullValue,
+	}})
+}
+
+func TestScheduleIsCanceledWithItsParentTask(t *testing.T) {
+	input := `
+let count = atomic(0)
+let owner = spawn("owner", () -> {
+    every(10, () -> count.add(1))
+    sleep(1000)
+})()
+sleep(25)
+owner.cancel()
+sleep(100)
+let names = tasks().filter((t) -> t.status == "pending").map((t) -> t.name)
+let out = [count.get(), names]
+out
+`
+	val := mustEvalDeterministic(t, input, 4)
+	assertEquivalent(t, val, &Array{Elements: []Value{
+		&Integer{Value: 2},
+		&Array{Elements: []Value{}},
+	}})
+}
+
+func TestScheduleRunFailureFailsHandle(t *testing.T) {
+	input := `
+let handle = every(10, () -> fail("boom"))
+let msg = (wait handle) ? error.message
+msg
+`
+	val := mustEvalDeterministic(t, input, 1)
+	assertString(t, val, "boom")
+
+	_, err := evalDeterministic(t, `
+every(10, () -> fail("unobserved"))
+sleep(50)
+`, 1)
+	if err == nil || !strings.Contains(err.Error(), "unobserved") {
+		t.Fatalf("expected fail-fast failure, got %v", err)
+	}
+}
+
+func TestScheduleRealClock(t *testing.T) {
+	val := mustEval(t, `
+let handle = every(5, () -> 1, { limit: 2 })
+wait handle
+`)
+	assertInteger(t, val, 2)
+}
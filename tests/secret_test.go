@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"karl/interpreter"
+)
+
+func TestSecretIsMaskedEverywhere(t *testing.T) {
+	t.Setenv("KARL_TEST_TOKEN", "s3cr3t-token")
+	input := `
+let token = secret(env("KARL_TEST_TOKEN"))
+log("token", token, { token: token })
+logger().info("auth", { token: token })
+let out = [token, str(token), encodeJson({ token: token }), "Bearer " + token, secret(token) == token]
+out
+`
+	var logs strings.Builder
+	var val Value
+	stdout := captureStdout(t, func() {
+		var err error
+		val, err = evalWithConfiguredEvaluator(t, input, func(e *interpreter.Evaluator) {
+			e.SetLogOutput(&logs)
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+	if got := val.Inspect(); got != `[***, "***", "{\"token\":\"***\"}", ***, true]` {
+		t.Fatalf("unexpected result %s", got)
+	}
+	for _, out := range []string{stdout, logs.String()} {
+		if strings.Contains(out, "s3cr3t") || !strings.Contains(out, "***") {
+			t.Fatalf("expected masked output, got %q", out)
+		}
+	}
+}
+
+func TestSecretUnwrapsOnlyInHTTPHeadersAndBody(t *testing.T) {
+	var gotAuth, gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		data := make([]byte, r.ContentLength)
+		r.Body.Read(data)
+		gotBody = string(data)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("file-token-123\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	input := fmt.Sprintf(`
+let token = secretFile(%q)
+let res = http({
+    method: "POST",
+    url: %q,
+    headers: { Authorization: "Bearer " + token },
+    body: token,
+})
+res.status
+`, path, server.URL)
+	assertInteger(t, mustEval(t, input), 200)
+	if gotAuth != "Bearer file-token-123" || gotBody != "file-token-123" {
+		t.Fatalf("expected unwrapped secret in request, got header %q body %q", gotAuth, gotBody)
+	}
+
+	_, err := evalInput(t, fmt.Sprintf(`http({ url: %q + secretFile(%q) })`, server.URL, path))
+	if err == nil || !strings.Contains(err.Error(), "http url must be string") {
+		t.Fatalf("expected secrets to be rejected as url, got %v", err)
+	}
+}
+
+func TestSecretRedactsErrorMessages(t *testing.T) {
+	t.Setenv("KARL_TEST_TOKEN", "s3cr3t-token")
+	input := `
+let raw = env("KARL_TEST_TOKEN")
+let token = secret(raw)
+let a = readFile("/missing/" + raw) ? error.message
+let b = (fail("rejected " + raw)) ? error.message
+let c = (fail("rejected " + token)) ? error.message
+let d = (wait & fail(raw)) ? error.message
+let e = secret(env("KARL_TEST_MISSING")) ? error.kind
+let out = [a, b, c, d, e]
+out
+`
+	val := mustEval(t, input)
+	if got := val.Inspect(); got != `["readFile error: open /missing/***: no such file or directory", "rejected ***", "rejected ***", "***", "secret"]` {
+		t.Fatalf("unexpected result %s", got)
+	}
+
+	_, err := evalInput(t, `fail(secret("hunter2"))`)
+	if err == nil || err.Error() != "***" {
+		t.Fatalf("expected masked failure, got %v", err)
+	}
+}
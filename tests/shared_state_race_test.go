@@ -0,0 +1,159 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+)
+
+// These tests run tasks on real goroutines; `go test -race` checks that
+// sharing Karl collections between them is free of Go data races.
+
+func TestConcurrentCollectionMutationIsSafe(t *testing.T) {
+	input := `
+let shared = { n: 0, items: [], m: map(), s: set() }
+let work = (id) -> {
+    for i < 200 with i = 0 {
+        shared.n += 1
+        shared.items.push(i)
+        shared.m.set(str(id) + ":" + str(i), i)
+        shared.s.add(i)
+        shared[str(id)] = i
+        i++
+    }
+}
+wait & { work(1), work(2), work(3), work(4) }
+let out = [shared.n, shared.items.length, shared.m.keys().length, shared.s.size]
+out
+`
+	assertEquivalent(t, mustEval(t, input), &Array{Elements: []Value{
+		&Integer{Value: 800}, &Integer{Value: 800}, &Integer{Value: 800}, &Integer{Value: 200},
+	}})
+}
+
+func TestBusyTaskDoesNotStarveOthers(t *testing.T) {
+	input := `
+let state = { spins: 0 }
+let spin = () -> { for true { state.spins += 1 } }
+let spinner = & spin()
+sleep(5)
+spinner.cancel()
+(wait spinner) ? { error.kind }
+`
+	assertString(t, mustEval(t, input), "canceled")
+}
+
+func TestMutexMakesReadModifyWriteAtomic(t *testing.T) {
+	input := `
+let state = { n: 0 }
+let m = mutex()
+let bump = () -> {
+    for i < 20 with i = 0 {
+        m.withLock(() -> {
+            let seen = state.n
+            sleep(0)
+            state.n = seen + 1
+        })
+        i++
+    }
+}
+wait & { bump(), bump(), bump() }
+state.n
+`
+	assertInteger(t, mustEval(t, input), 60)
+}
+
+func TestMutexLockUnlockAndTryLock(t *testing.T) {
+	input := `
+let m = mutex()
+m.lock()
+let busy = m.tryLock()
+m.unlock()
+let free = m.tryLock()
+m.unlock()
+let out = [busy, free]
+out
+`
+	assertEquivalent(t, mustEval(t, input), &Array{Elements: []Value{
+		&Boolean{Value: false}, &Boolean{Value: true},
+	}})
+}
+
+func TestMutexUnlockWithoutLockFails(t *testing.T) {
+	_, err := evalInput(t, `mutex().unlock()`)
+	if err == nil || !strings.Contains(err.Error(), "unlock of unlocked mutex") {
+		t.Fatalf("expected unlock error, got %v", err)
+	}
+}
+
+func TestMutexWithReleasesOnError(t *testing.T) {
+	input := `
+let m = mutex()
+let kind = m.withLock(() -> fail("inside")) ? { error.message }
+let out = [kind, m.tryLock()]
+out
+`
+	assertEquivalent(t, mustEval(t, input), &Array{Elements: []Value{
+		&String{Value: "inside"}, &Boolean{Value: true},
+	}})
+}
+
+func TestWaitingForMutexIsCancelable(t *testing.T) {
+	input := `
+let m = mutex()
+m.lock()
+let waiter = & m.lock()
+sleep(5)
+waiter.cancel()
+(wait waiter) ? { error.kind }
+`
+	assertString(t, mustEval(t, input), "canceled")
+}
+
+func TestSemaphoreBoundsConcurrency(t *testing.T) {
+	input := `
+let state = { inflight: 0, peak: 0 }
+let sem = semaphore(2)
+let work = () -> sem.withPermit(() -> {
+    state.inflight += 1
+    if state.inflight > state.peak { state.peak = state.inflight }
+    sleep(5)
+    state.inflight -= 1
+})
+wait & { work(), work(), work(), work(), work() }
+let out = [state.peak, sem.available]
+out
+`
+	assertEquivalent(t, mustEval(t, input), &Array{Elements: []Value{
+		&Integer{Value: 2}, &Integer{Value: 2},
+	}})
+	for seed := int64(0); seed < 5; seed++ {
+		assertEquivalent(t, mustEvalDeterministic(t, input, seed), &Array{Elements: []Value{
+			&Integer{Value: 2}, &Integer{Value: 2},
+		}})
+	}
+}
+
+func TestSemaphoreReleaseWithoutAcquireFails(t *testing.T) {
+	_, err := evalInput(t, `semaphore(1).release()`)
+	if err == nil {
+		t.Fatalf("expected release error")
+	}
+}
+
+func TestAtomicOperations(t *testing.T) {
+	input := `
+let counter = atomic(0)
+let bump = () -> { for i < 100 with i = 0 { counter.add(1); i++ } }
+wait & { bump(), bump(), bump() }
+let slow = () -> counter.update((n) -> { sleep(1); n + 1000 })
+wait & { slow(), slow() }
+let swapped = counter.compareAndSet(2300, 7)
+let missed = counter.compareAndSet(2300, 8)
+let old = counter.swap(9)
+let out = [swapped, missed, old, counter.get()]
+out
+`
+	assertEquivalent(t, mustEval(t, input), &Array{Elements: []Value{
+		&Boolean{Value: true}, &Boolean{Value: false}, &Integer{Value: 7}, &Integer{Value: 9},
+	}})
+}
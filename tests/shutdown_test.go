@@ -0,0 +1,183 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"karl/interpreter"
+	"karl/lexer"
+	"karl/parser"
+)
+
+type shutdownRun struct {
+	eval *interpreter.Evaluator
+	env  *interpreter.Environment
+	val  Value
+	err  error
+}
+
+func runForShutdown(t *testing.T, input string, during func(*interpreter.Evaluator)) shutdownRun {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		t.Fatalf("parse errors: %v", errs)
+	}
+	eval := interpreter.NewEvaluatorWithSourceAndFilename(input, "<test>")
+	env := interpreter.NewBaseEnvironment()
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		if during != nil {
+			time.Sleep(20 * time.Millisecond)
+			during(eval)
+		}
+	}()
+	val, _, err := eval.Eval(program, env)
+	<-finished
+	return shutdownRun{eval: eval, env: env, val: val, err: err}
+}
+
+func expectExit(t *testing.T, err error, code int, message string) {
+	t.Helper()
+	exitErr, ok := err.(*interpreter.ExitError)
+	if !ok {
+		t.Fatalf("expected ExitError, got %T (%v)", err, err)
+	}
+	if exitErr.Code != code || exitErr.Message != message {
+		t.Fatalf("expected exit %d %q, got %d %q", code, message, exitErr.Code, exitErr.Message)
+	}
+}
+
+func TestExitSetsExitCode(t *testing.T) {
+	cases := []struct {
+		input   string
+		code    int
+		message string
+	}{
+		{`exit()`, 0, ""},
+		{`exit(3)`, 3, ""},
+		{`exit("bye")`, 1, "bye"},
+		{`exit("bye", 4)`, 4, "bye"},
+		{`exit("done", 0)`, 0, "done"},
+		{`exit("not caught", 2) ? 1`, 2, "not caught"},
+	}
+	for _, tc := range cases {
+		_, err := evalInput(t, tc.input)
+		expectExit(t, err, tc.code, tc.message)
+	}
+
+	_, err := evalInput(t, `exit(256)`)
+	if err == nil || !strings.Contains(err.Error(), "exit code must be between 0 and 255") {
+		t.Fatalf("expected exit code range error, got %v", err)
+	}
+}
+
+func TestExitFromTaskStopsWholeProgram(t *testing.T) {
+	for _, policy := range []string{interpreter.TaskFailurePolicyFailFast, interpreter.TaskFailurePolicyDefer} {
+		_, err := evalWithConfiguredEvaluator(t, `
+spawn(() -> exit("from task", 5))()
+sleep(10000)
+"unreachable"
+`, func(e *interpreter.Evaluator) {
+			if err := e.SetTaskFailurePolicy(policy); err != nil {
+				t.Fatalf("policy: %v", err)
+			}
+		})
+		expectExit(t, err, 5, "from task")
+	}
+}
+
+func TestAtExitHooksRunInReverseOrderAfterTasksAreCanceled(t *testing.T) {
+	run := runForShutdown(t, `
+let order = []
+atExit(() -> order.push("first"))
+atExit(() -> order.push("second"))
+let worker = spawn(() -> sleep(10000))()
+order
+`, nil)
+	if run.err != nil {
+		t.Fatalf("unexpected error: %v", run.err)
+	}
+	if err := run.eval.RunExitHooks(); err != nil {
+		t.Fatalf("hooks: %v", err)
+	}
+	assertEquivalent(t, run.val, &Array{Elements: []Value{
+		&String{Value: "second"},
+		&String{Value: "first"},
+	}})
+	worker, _ := run.env.Get("worker")
+	if !strings.Contains(worker.Inspect(), "canceled") {
+		t.Fatalf("expected worker to be canceled, got %s", worker.Inspect())
+	}
+	if err := run.eval.RunExitHooks(); err != nil {
+		t.Fatalf("hooks must only run once: %v", err)
+	}
+}
+
+func TestAtExitHooksRunAfterExitFromTask(t *testing.T) {
+	run := runForShutdown(t, `
+let order = []
+atExit(() -> {
+    sleep(1)
+    order.push("cleanup")
+})
+spawn(() -> exit(6))()
+sleep(10000)
+`, nil)
+	expectExit(t, run.err, 6, "")
+	if err := run.eval.RunExitHooks(); err != nil {
+		t.Fatalf("hooks: %v", err)
+	}
+	order, _ := run.env.Get("order")
+	assertEquivalent(t, order, &Array{Elements: []Value{&String{Value: "cleanup"}}})
+}
+
+func TestAtExitHookCanOverrideExitCode(t *testing.T) {
+	run := runForShutdown(t, `
+atExit(() -> exit("cleanup failed", 9))
+atExit(() -> fail("ignored after exit"))
+1
+`, nil)
+	if run.err != nil {
+		t.Fatalf("unexpected error: %v", run.err)
+	}
+	expectExit(t, run.eval.RunExitHooks(), 9, "cleanup failed")
+}
+
+func TestOnSignalRunsRegisteredHandlers(t *testing.T) {
+	var delivered, unhandled bool
+	run := runForShutdown(t, `
+let ch = channel()
+onSignal("SIGTERM", () -> ch.send("terminating"))
+ch.recv()[0]
+`, func(e *interpreter.Evaluator) {
+		unhandled = e.DeliverSignal("SIGHUP")
+		delivered = e.DeliverSignal("SIGTERM")
+	})
+	if run.err != nil {
+		t.Fatalf("unexpected error: %v", run.err)
+	}
+	assertString(t, run.val, "terminating")
+	if !delivered || unhandled {
+		t.Fatalf("expected only SIGTERM to have handlers, got SIGTERM=%v SIGHUP=%v", delivered, unhandled)
+	}
+
+	_, err := evalInput(t, `onSignal("SIGKILL", () -> 1)`)
+	if err == nil || !strings.Contains(err.Error(), "onSignal expects") {
+		t.Fatalf("expected unsupported signal error, got %v", err)
+	}
+}
+
+func TestRequestExitStopsBlockedProgram(t *testing.T) {
+	run := runForShutdown(t, `
+let worker = spawn(() -> {
+    for true { sleep(1) }
+})()
+wait worker
+`, func(e *interpreter.Evaluator) {
+		e.RequestExit(143)
+	})
+	expectExit(t, run.err, 143, "")
+}
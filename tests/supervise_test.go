@@ -0,0 +1,187 @@
+package tests
+
+import (
+	"testing"
+
+	"karl/interpreter"
+)
+
+func TestSuperviseOneForOneRestartsOnlyFailedChild(t *testing.T) {
+	input := `
+let state = { flaky: 0, steady: 0 }
+let flaky = () -> {
+    state.flaky += 1
+    if state.flaky < 3 { fail("flaky") }
+    "ok"
+}
+let steady = () -> { state.steady += 1; sleep(5); 42 }
+let sup = supervise({ strategy: "one-for-one", maxRestarts: 5, window: 1000 }, [flaky, steady])
+let out = [wait sup, state.flaky, state.steady]
+out
+`
+	for seed := int64(0); seed < 5; seed++ {
+		assertEquivalent(t, mustEvalDeterministic(t, input, seed), &Array{Elements: []Value{
+			&Array{Elements: []Value{&String{Value: "ok"}, &Integer{Value: 42}}},
+			&Integer{Value: 3},
+			&Integer{Value: 1},
+		}})
+	}
+}
+
+func TestSuperviseOneForAllRestartsEveryChild(t *testing.T) {
+	input := `
+let state = { flaky: 0, steady: 0 }
+let flaky = () -> {
+    state.flaky += 1
+    sleep(10)
+    if state.flaky < 2 { fail("flaky") }
+    "ok"
+}
+let steady = () -> { state.steady += 1; sleep(50); 42 }
+let sup = supervise({ strategy: "one-for-all", maxRestarts: 5, window: 1000 }, [flaky, steady])
+let out = [wait sup, state.flaky, state.steady]
+out
+`
+	for seed := int64(0); seed < 5; seed++ {
+		assertEquivalent(t, mustEvalDeterministic(t, input, seed), &Array{Elements: []Value{
+			&Array{Elements: []Value{&String{Value: "ok"}, &Integer{Value: 42}}},
+			&Integer{Value: 2},
+			&Integer{Value: 2},
+		}})
+	}
+}
+
+func TestSuperviseEscalatesWhenRestartBudgetIsExhausted(t *testing.T) {
+	input := `
+let state = { attempts: 0 }
+let broken = () -> { state.attempts += 1; fail("broken") }
+let sup = supervise({ maxRestarts: 2, window: 1000 }, [broken])
+let kind = (wait sup) ? { error.kind }
+let out = [kind, state.attempts]
+out
+`
+	assertEquivalent(t, mustEvalDeterministic(t, input, 1), &Array{Elements: []Value{
+		&String{Value: "supervisor"},
+		&Integer{Value: 3},
+	}})
+}
+
+func TestSuperviseRestartWindowForgetsOldRestarts(t *testing.T) {
+	input := `
+let state = { attempts: 0 }
+let slowFail = () -> {
+    state.attempts += 1
+    sleep(100)
+    if state.attempts < 5 { fail("again") }
+    "done"
+}
+let sup = supervise({ maxRestarts: 1, window: 50 }, [slowFail])
+let out = [wait sup, state.attempts]
+out
+`
+	assertEquivalent(t, mustEvalDeterministic(t, input, 1), &Array{Elements: []Value{
+		&Array{Elements: []Value{&String{Value: "done"}}},
+		&Integer{Value: 5},
+	}})
+}
+
+func TestSuperviseBackoffUsesClock(t *testing.T) {
+	input := `
+let state = { attempts: 0 }
+let flaky = () -> {
+    state.attempts += 1
+    if state.attempts < 4 { fail("flaky") }
+    "ok"
+}
+let t0 = now()
+let sup = supervise({ maxRestarts: 5, window: 10000, backoff: { initial: 100, factor: 2, max: 300 } }, [flaky])
+let result = wait sup
+let out = [result, now() - t0]
+out
+`
+	// Restarts wait 100ms, 200ms and then the 300ms cap.
+	assertEquivalent(t, mustEvalDeterministic(t, input, 1), &Array{Elements: []Value{
+		&Array{Elements: []Value{&String{Value: "ok"}}},
+		&Integer{Value: 600},
+	}})
+}
+
+func TestSuperviseReportsEventsOnChannel(t *testing.T) {
+	input := `
+let events = buffered(10)
+let state = { attempts: 0 }
+let flaky = () -> {
+    state.attempts += 1
+    if state.attempts < 2 { fail("flaky") }
+    "ok"
+}
+wait supervise({ events: events }, [flaky])
+events.done()
+for true with seen = [] {
+    let [ev, done] = events.recv()
+    if done { break seen }
+    seen.push(ev.event + ":" + str(ev.child) + ":" + str(ev.restarts))
+} then seen
+`
+	assertEquivalent(t, mustEval(t, input), &Array{Elements: []Value{
+		&String{Value: "failed:0:0"},
+		&String{Value: "restarted:0:1"},
+		&String{Value: "completed:0:1"},
+	}})
+}
+
+func TestSuperviseEscalatedEventCarriesError(t *testing.T) {
+	input := `
+let log = []
+let sup = supervise({ maxRestarts: 0, onEvent: (ev) -> log.push(ev) }, [() -> fail("nope")])
+let outcome = (wait sup) ? { error.kind }
+let out = [outcome, log.length, log[1].event, log[1].error.message]
+out
+`
+	assertEquivalent(t, mustEval(t, input), &Array{Elements: []Value{
+		&String{Value: "supervisor"},
+		&Integer{Value: 2},
+		&String{Value: "escalated"},
+		&String{Value: "nope"},
+	}})
+}
+
+func TestSuperviseUnobservedEscalationFailsFast(t *testing.T) {
+	input := `
+supervise({ maxRestarts: 1 }, [() -> fail("broken")])
+sleep(1000)
+"unreachable"
+`
+	_, err := evalDeterministic(t, input, 1)
+	if _, ok := err.(*interpreter.UnhandledTaskError); !ok {
+		t.Fatalf("expected UnhandledTaskError, got %T (%v)", err, err)
+	}
+}
+
+func TestSuperviseCancelStopsChildren(t *testing.T) {
+	input := `
+let state = { ticks: 0 }
+let worker = () -> { for true { state.ticks += 1; sleep(10) } }
+let sup = supervise({}, [worker, worker])
+sleep(55)
+sup.cancel()
+let kind = (wait sup) ? { error.kind }
+let seen = state.ticks
+sleep(100)
+let out = [kind, seen == state.ticks]
+out
+`
+	for seed := int64(0); seed < 5; seed++ {
+		assertEquivalent(t, mustEvalDeterministic(t, input, seed), &Array{Elements: []Value{
+			&String{Value: "canceled"},
+			&Boolean{Value: true},
+		}})
+	}
+}
+
+func TestSuperviseRejectsUnknownStrategy(t *testing.T) {
+	_, err := evalInput(t, `supervise({ strategy: "rest-for-one" }, [])`)
+	if err == nil {
+		t.Fatalf("expected error for unknown strategy")
+	}
+}
@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"runtime"
+	"testing"
+
+	"karl/interpreter"
+)
+
+// Finished tasks must not stay reachable from the runtime: a session that
+// keeps spawning short tasks has to run in constant memory.
+func TestMillionShortTasksRunInBoundedMemory(t *testing.T) {
+	if testing.Short() {
+		t.Skip("soak test")
+	}
+	const budget = 32 << 20
+
+	var before runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	input := `
+let observed = 0
+for i < 1000000 with i = 0 {
+    let task = spawn(() -> i)()
+    if i % 10 == 0 {
+        observed += wait task
+    }
+    i++
+}
+// Unawaited tasks finish on their own schedule; poll, with a bound, rather
+// than guess how long that takes on a loaded machine.
+for tasks().length > 0 && polls < 10000 with polls = 0 {
+    sleep(1)
+    polls++
+}
+let out = [observed, tasks().length]
+out
+`
+	var eval *interpreter.Evaluator
+	val, err := evalWithConfiguredEvaluator(t, input, func(e *interpreter.Evaluator) { eval = e })
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertEquivalent(t, val, &Array{Elements: []Value{
+		&Integer{Value: 49999500000},
+		&Integer{Value: 0},
+	}})
+
+	var after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	if grown := int64(after.HeapAlloc) - int64(before.HeapAlloc); grown > budget {
+		t.Fatalf("heap grew by %d MiB after a million tasks, budget is %d MiB", grown>>20, budget>>20)
+	}
+	// The evaluator, and with it the runtime, is still in use here.
+	runtime.KeepAlive(eval)
+}
+
+func TestReleasedTasksKeepFailureReporting(t *testing.T) {
+	input := `
+let failing = (msg) -> { let obj = {}; obj.missing }
+let id = (x) -> x
+let late = & failing("awaited after it failed")
+& failing("never awaited")
+for i < 1000 with i = 0 {
+    wait & id(i)
+    i++
+}
+for tasks().length > 0 && polls < 10000 with polls = 0 {
+    sleep(1)
+    polls++
+}
+let recovered = (wait late) ? { "recovered" }
+let out = [recovered, late.status(), tasks().length]
+out
+`
+	val, eval, err := evalWithPolicy(t, input, interpreter.TaskFailurePolicyDefer)
+	if err != nil {
+		t.Fatalf("unexpected eval error: %v", err)
+	}
+	assertEquivalent(t, val, &Array{Elements: []Value{
+		&String{Value: "recovered"},
+		&String{Value: "failed"},
+		&Integer{Value: 0},
+	}})
+	err = eval.CheckUnhandledTaskFailures()
+	ute, ok := err.(*interpreter.UnhandledTaskError)
+	if !ok || len(ute.Messages) != 1 {
+		t.Fatalf("expected exactly one unhandled failure, got %v", err)
+	}
+}
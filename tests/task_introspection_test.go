@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"karl/interpreter"
+)
+
+func TestSpawnWithNameAndTaskStatus(t *testing.T) {
+	input := `
+let work = (ms) -> { sleep(ms); ms }
+let named = spawn("fetch", work, 10)
+let before = named.status()
+wait named
+let failing = & (() -> { sleep(5); fail("boom") })()
+let failed = (wait failing) ? { failing.status() }
+let slow = & work(1000)
+slow.cancel()
+let out = [named.name, named.id > 0, before, named.status(), failed, slow.status(), slow.name]
+out
+`
+	assertEquivalent(t, mustEvalDeterministic(t, input, 1), &Array{Elements: []Value{
+		&String{Value: "fetch"},
+		&Boolean{Value: true},
+		&String{Value: "pending"},
+		&String{Value: "done"},
+		&String{Value: "failed"},
+		&String{Value: "canceled"},
+		NullValue,
+	}})
+}
+
+func TestTaskInspectShowsIdNameAndStatus(t *testing.T) {
+	input := `
+let t = spawn("worker", (x) -> x, 1)
+wait t
+str(t)
+`
+	val := mustEvalDeterministic(t, input, 1)
+	str, ok := val.(*String)
+	if !ok || !strings.HasPrefix(str.Value, "<task #") || !strings.HasSuffix(str.Value, " worker done>") {
+		t.Fatalf("unexpected task inspect: %s", val.Inspect())
+	}
+}
+
+func TestTasksListsLiveTasksWithParents(t *testing.T) {
+	input := `
+let child = () -> sleep(100)
+let parent = () -> {
+    let inner = spawn("inner", child)()
+    wait inner
+}
+let outer = spawn("outer", parent)()
+sleep(10)
+let live = tasks().map((t) -> [t.name, t.status, t.parent])
+let ids = [outer.id]
+wait outer
+let out = [live, tasks().length, ids]
+out
+`
+	val := mustEvalDeterministic(t, input, 1)
+	arr := val.(*Array)
+	live := arr.Elements[0].(*Array)
+	if len(live.Elements) != 2 {
+		t.Fatalf("expected two live tasks, got %s", live.Inspect())
+	}
+	outerID := arr.Elements[2].(*Array).Elements[0]
+	assertEquivalent(t, live.Elements[0], &Array{Elements: []Value{&String{Value: "outer"}, &String{Value: "pending"}, NullValue}})
+	assertEquivalent(t, live.Elements[1], &Array{Elements: []Value{&String{Value: "inner"}, &String{Value: "pending"}, outerID}})
+	assertInteger(t, arr.Elements[1], 0)
+}
+
+func TestTasksReportCurrentPosition(t *testing.T) {
+	input := `
+let work = () -> {
+    let x = 1
+    sleep(100)
+}
+let t = spawn("work", work)()
+sleep(10)
+tasks()[0].position
+`
+	assertString(t, mustEvalDeterministic(t, input, 1), "<test>:4:5")
+}
+
+func TestCurrentTask(t *testing.T) {
+	input := `
+let top = currentTask()
+let t = & (() -> currentTask())()
+let inner = wait t
+let out = [top, inner == t]
+out
+`
+	assertEquivalent(t, mustEval(t, input), &Array{Elements: []Value{
+		NullValue,
+		&Boolean{Value: true},
+	}})
+}
+
+func TestDumpTasksPrintsTreeWithPositions(t *testing.T) {
+	input := `
+let child = () -> {
+    sleep(1000)
+}
+let parent = () -> {
+    let c = spawn("child", child)()
+    wait c
+}
+spawn("parent", parent)()
+sleep(10)
+"main done"
+`
+	var eval *interpreter.Evaluator
+	_, err := evalWithConfiguredEvaluator(t, input, func(e *interpreter.Evaluator) {
+		e.SetDeterministic(1)
+		eval = e
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var out strings.Builder
+	eval.DumpTasks(&out)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected main plus two tasks, got:\n%s", out.String())
+	}
+	if lines[0] != "main at <test>:11:1" {
+		t.Fatalf("unexpected main line: %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "  <task #") || !strings.HasSuffix(lines[1], " parent pending> at <test>:7:5") {
+		t.Fatalf("unexpected parent line: %q", lines[1])
+	}
+	if !strings.HasPrefix(lines[2], "    <task #") || !strings.HasSuffix(lines[2], " child pending> at <test>:3:5") {
+		t.Fatalf("unexpected child line: %q", lines[2])
+	}
+}
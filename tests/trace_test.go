@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"karl/interpreter"
+)
+
+type traceEvent struct {
+	Name string                 `json:"name"`
+	Cat  string                 `json:"cat"`
+	Ph   string                 `json:"ph"`
+	Ts   float64                `json:"ts"`
+	Tid  uint64                 `json:"tid"`
+	ID   uint64                 `json:"id"`
+	Args map[string]interface{} `json:"args"`
+}
+
+func TestTraceRecordsTasksChannelsAndCalls(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	input := fmt.Sprintf(`
+let ch = channel()
+let produce = () -> {
+    sleep(5)
+    send(ch, 1)
+}
+let slow = () -> sleep(10000)
+let p = & produce()
+let [v, done] = recv(ch)
+wait p
+let s = & slow()
+s.cancel()
+let c = (wait s) ? 0
+http({ url: %q })
+v
+`, server.URL)
+	var buf bytes.Buffer
+	var eval *interpreter.Evaluator
+	val, err := evalWithConfiguredEvaluator(t, input, func(e *interpreter.Evaluator) {
+		eval = e
+		e.StartTrace(&buf)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertInteger(t, val, 1)
+	if err := eval.StopTrace(); err != nil {
+		t.Fatalf("StopTrace: %v", err)
+	}
+
+	var doc struct {
+		TraceEvents []traceEvent `json:"traceEvents"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("trace is not valid JSON: %v\n%s", err, buf.String())
+	}
+	find := func(name, ph string, tid uint64) *traceEvent {
+		for i := range doc.TraceEvents {
+			ev := &doc.TraceEvents[i]
+			if ev.Name == name && ev.Ph == ph && (tid == 0 || ev.Tid == tid) {
+				return ev
+			}
+		}
+		t.Fatalf("missing %s event %q (tid %d) in:\n%s", ph, name, tid, buf.String())
+		return nil
+	}
+
+	producer := uint64(find("spawn", "i", 0).Args["task"].(float64))
+	if name := find("thread_name", "M", producer).Args["name"]; name != fmt.Sprintf("task #%d", producer) {
+		t.Fatalf("unexpected thread name %v", name)
+	}
+	find("sleep", "B", producer)
+	find("send", "B", producer)
+	find("complete", "i", producer)
+	if ev := find(fmt.Sprintf("task #%d", producer), "e", producer); ev.ID != producer || ev.Args["status"] != "complete" {
+		t.Fatalf("unexpected task end %+v", ev)
+	}
+	find("produce (<test>:3)", "B", producer)
+	find("recv", "B", 0)
+	find("wait", "B", 0)
+	find("cancel", "i", 0)
+	find("canceled", "i", 0)
+	if ev := find("http", "B", 0); ev.Args["method"] != "GET" || ev.Args["url"] != server.URL {
+		t.Fatalf("unexpected http span %+v", ev)
+	}
+
+	depth := map[uint64]int{}
+	for _, ev := range doc.TraceEvents {
+		switch ev.Ph {
+		case "B":
+			depth[ev.Tid]++
+		case "E":
+			depth[ev.Tid]--
+			if depth[ev.Tid] < 0 {
+				t.Fatalf("span ended twice on thread %d", ev.Tid)
+			}
+		}
+	}
+	for tid, d := range depth {
+		if d != 0 {
+			t.Fatalf("thread %d has %d unterminated spans", tid, d)
+		}
+	}
+}
+
+func TestTraceNamesLambdas(t *testing.T) {
+	var buf bytes.Buffer
+	var eval *interpreter.Evaluator
+	_, err := evalWithConfiguredEvaluator(t, `[1, 2].map(x -> x + 1)`, func(e *interpreter.Evaluator) {
+		eval = e
+		e.StartTrace(&buf)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := eval.StopTrace(); err != nil {
+		t.Fatalf("StopTrace: %v", err)
+	}
+	if got := strings.Count(buf.String(), `"name":"\u003clambda\u003e (\u003ctest\u003e:1)","cat":"function","ph":"B"`); got != 2 {
+		t.Fatalf("expected 2 lambda spans, got %d:\n%s", got, buf.String())
+	}
+}
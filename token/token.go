@@ -8,6 +8,9 @@ type Token struct {
 	Line    int
 	Column  int
 	Offset  int
+	// Source is the literal as written, for STRING and CHAR tokens whose
+	// Literal holds the value: quotes, escapes, raw prefix and all.
+	Source string
 }
 
 const (
@@ -60,6 +63,7 @@ const (
 	QUESTION  = "?"
 	AMPERSAND = "&"
 	PIPE      = "|"
+	PIPELINE  = "|>"
 
 	LPAREN   = "("
 	RPAREN   = ")"
@@ -67,6 +67,12 @@ func runProgram(program *ast.Program, source string, filename string) error {
 	env := interpreter.NewBaseEnvironment()
 
 	val, sig, err := eval.Eval(program, env)
+	if exitErr, ok := err.(*interpreter.ExitError); ok {
+		if exitErr.Code == 0 {
+			return nil
+		}
+		return exitErr
+	}
 	if err != nil {
 		if ute, ok := err.(*interpreter.UnhandledTaskError); ok {
 			return ute